@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -22,6 +24,9 @@ import (
 	"github.com/go-git/go-billy/v5/osfs"
 	billyUtils "github.com/go-git/go-billy/v5/util"
 	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type (
@@ -37,6 +42,8 @@ type (
 		Annotations     map[string]string
 		Include         string
 		Exclude         string
+		// SyncPolicy overrides the sync policy of the project for this app, if not nil
+		SyncPolicy *SyncPolicyOptions
 	}
 
 	AppDeleteOptions struct {
@@ -49,6 +56,14 @@ type (
 	AppListOptions struct {
 		CloneOpts   *git.CloneOptions
 		ProjectName string
+		Out         io.Writer
+	}
+
+	AppImportOptions struct {
+		CloneOpts   *git.CloneOptions
+		ProjectName string
+		Selector    string
+		KubeFactory kube.Factory
 	}
 )
 
@@ -66,6 +81,7 @@ func NewAppCommand() *cobra.Command {
 	cmd.AddCommand(NewAppCreateCommand())
 	cmd.AddCommand(NewAppListCommand())
 	cmd.AddCommand(NewAppDeleteCommand())
+	cmd.AddCommand(NewAppImportCommand())
 
 	return cmd
 }
@@ -75,6 +91,7 @@ func NewAppCreateCommand() *cobra.Command {
 		cloneOpts     *git.CloneOptions
 		appsCloneOpts *git.CloneOptions
 		appOpts       *application.CreateOptions
+		syncOpts      *SyncPolicyOptions
 		projectName   string
 		timeout       time.Duration
 		f             kube.Factory
@@ -116,6 +133,15 @@ func NewAppCreateCommand() *cobra.Command {
 # Wait until the application is Synced in the cluster:
 
   <BIN> app create <new_app_name> --app github.com/some_org/some_repo/manifests --project project_name --wait-timeout 2m --context my_context 
+
+# Commit the built manifests of the application, and pull all of its images from a registry mirror,
+# for a disconnected cluster:
+
+  <BIN> app create <new_app_name> --app github.com/some_org/some_repo/manifests --project project_name --airgap --image-registry-mirror registry.local
+
+# Override the sync policy of the project, to sync the application manually, with server side apply:
+
+  <BIN> app create <new_app_name> --app github.com/some_org/some_repo/manifests --project project_name --auto-sync=false --sync-option ServerSideApply=true
 `),
 		PreRun: func(_ *cobra.Command, _ []string) {
 			cloneOpts.Parse()
@@ -136,6 +162,7 @@ func NewAppCreateCommand() *cobra.Command {
 				AppOpts:         appOpts,
 				Timeout:         timeout,
 				KubeFactory:     f,
+				SyncPolicy:      syncOpts.Parse(cmd),
 			})
 		},
 	}
@@ -152,6 +179,7 @@ func NewAppCreateCommand() *cobra.Command {
 		Optional: true,
 	})
 	appOpts = application.AddFlags(cmd)
+	syncOpts = addSyncPolicyFlags(cmd)
 	f = kube.AddFlags(cmd.Flags())
 
 	die(cmd.MarkFlagRequired("app"))
@@ -178,11 +206,14 @@ func RunAppCreate(ctx context.Context, opts *AppCreateOptions) error {
 	}
 
 	if opts.AppsCloneOpts.Repo != "" {
-		if opts.AppsCloneOpts.Auth.Password == "" {
+		if opts.AppsCloneOpts.Auth.Password == "" && opts.AppsCloneOpts.Auth.SSHKeyFile == "" {
 			opts.AppsCloneOpts.Auth.Username = opts.CloneOpts.Auth.Username
 			opts.AppsCloneOpts.Auth.Password = opts.CloneOpts.Auth.Password
 			opts.AppsCloneOpts.Auth.CertFile = opts.CloneOpts.Auth.CertFile
+			opts.AppsCloneOpts.Auth.SSHKeyFile = opts.CloneOpts.Auth.SSHKeyFile
 			opts.AppsCloneOpts.Provider = opts.CloneOpts.Provider
+			// parse the repo again, so it is cloned over ssh when the ssh key is used
+			opts.AppsCloneOpts.Parse()
 		}
 
 		appsRepo, appsfs, err = getRepo(ctx, opts.AppsCloneOpts)
@@ -198,11 +229,22 @@ func RunAppCreate(ctx context.Context, opts *AppCreateOptions) error {
 		return err
 	}
 
+	if opts.SyncPolicy != nil {
+		opts.AppOpts.SyncPolicy, err = getAppSyncPolicy(repofs, opts.ProjectName, opts.SyncPolicy)
+		if err != nil {
+			return err
+		}
+	}
+
 	app, err := parseApp(opts.AppOpts, opts.ProjectName, opts.CloneOpts.URL(), opts.CloneOpts.Revision(), opts.CloneOpts.Path())
 	if err != nil {
 		return fmt.Errorf("failed to parse application from flags: %w", err)
 	}
 
+	if err = validateAppInProject(repofs, opts); err != nil {
+		return err
+	}
+
 	if err = app.CreateFiles(repofs, appsfs, opts.ProjectName); err != nil {
 		if errors.Is(err, application.ErrAppAlreadyInstalledOnProject) {
 			return fmt.Errorf("application '%s' already exists in project '%s': %w", app.Name(), opts.ProjectName, err)
@@ -224,14 +266,20 @@ func RunAppCreate(ctx context.Context, opts *AppCreateOptions) error {
 		return fmt.Errorf("failed to push to gitops repo: %w", err)
 	}
 
-	if opts.Timeout > 0 {
+	if opts.Timeout > 0 && isMultiClusterProject(repofs, opts.ProjectName) {
+		log.G(ctx).Warnf("not waiting for '%s', the apps of project '%s' are deployed to all of the clusters of its cluster selector", opts.AppOpts.AppName, opts.ProjectName)
+	} else if opts.Timeout > 0 {
 		namespace, err := getInstallationNamespace(repofs)
 		if err != nil {
 			return fmt.Errorf("failed to get application namespace: %w", err)
 		}
 
-		log.G(ctx).WithField("timeout", opts.Timeout).Infof("waiting for '%s' to finish syncing", opts.AppOpts.AppName)
 		fullName := fmt.Sprintf("%s-%s", opts.ProjectName, opts.AppOpts.AppName)
+		if err = checkAppSyncWindows(repofs, opts.ProjectName, fullName, opts.AppOpts); err != nil {
+			return err
+		}
+
+		log.G(ctx).WithField("timeout", opts.Timeout).Infof("waiting for '%s' to finish syncing", opts.AppOpts.AppName)
 
 		// wait for argocd to be ready before applying argocd-apps
 		stop := util.WithSpinner(ctx, fmt.Sprintf("waiting for '%s' to be ready", fullName))
@@ -314,6 +362,96 @@ func getProjectDestServer(repofs fs.FS, projectName string) (string, error) {
 	return p.Annotations[store.Default.DestServerAnnotation], nil
 }
 
+// validateAppInProject returns an error if the project AppProject does not permit the
+// destination or source repository of the app
+var validateAppInProject = func(repofs fs.FS, opts *AppCreateOptions) error {
+	proj := &argocdv1alpha1.AppProject{}
+	if err := repofs.ReadYamls(repofs.Join(store.Default.ProjectsDir, opts.ProjectName+".yaml"), proj); err != nil {
+		return fmt.Errorf("failed to unmarshal project: %w", err)
+	}
+
+	// kustomize apps are synced from the gitops repo, while directory apps are synced from the app repo
+	srcRepoURL := opts.CloneOpts.URL()
+	if opts.AppOpts.AppType == application.AppTypeDirectory {
+		host, orgRepo, _, _, _, suffix, _ := util.ParseGitUrl(opts.AppOpts.AppSpecifier)
+		srcRepoURL = host + orgRepo + suffix
+	}
+
+	if !proj.IsSourcePermitted(argocdv1alpha1.ApplicationSource{RepoURL: srcRepoURL}) {
+		return fmt.Errorf("source repository '%s' is not permitted in project '%s'", srcRepoURL, opts.ProjectName)
+	}
+
+	permitted, err := proj.IsDestinationPermitted(&argocdv1alpha1.Cluster{Server: opts.AppOpts.DestServer}, opts.AppOpts.DestNamespace, func(_ string) ([]*argocdv1alpha1.Cluster, error) {
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !permitted {
+		return fmt.Errorf("destination server '%s' and namespace '%s' are not permitted in project '%s'", opts.AppOpts.DestServer, opts.AppOpts.DestNamespace, opts.ProjectName)
+	}
+
+	return nil
+}
+
+// getAppSyncPolicy returns the sync policy of the app template of the project, with the sync
+// policy overrides of the app applied on top of it
+func getAppSyncPolicy(repofs fs.FS, projectName string, overrides *SyncPolicyOptions) (*argocdv1alpha1.SyncPolicy, error) {
+	appSet, err := getSyncPolicyAppSet(repofs, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	return overrides.Apply(appSet.Spec.Template.Spec.SyncPolicy)
+}
+
+// getSyncPolicyAppSet returns the ApplicationSet of the project, or an error if it does not
+// apply the sync policies of its apps
+func getSyncPolicyAppSet(repofs fs.FS, projectName string) (*argocdv1alpha1.ApplicationSet, error) {
+	_, appSet, err := getProjectInfoFromFile(repofs, repofs.Join(store.Default.ProjectsDir, projectName+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project '%s': %w", projectName, err)
+	}
+
+	if appSet.Spec.TemplatePatch == nil {
+		return nil, fmt.Errorf(util.Doc("project '%s' does not support app sync policies, update it with `<BIN> project update %s` first"), projectName, projectName)
+	}
+
+	return appSet, nil
+}
+
+// checkAppSyncWindows returns an error if the sync windows of the project do not allow the
+// app to be synced right now, as waiting for it to sync would only time out
+var checkAppSyncWindows = func(repofs fs.FS, projectName, appName string, appOpts *application.CreateOptions) error {
+	proj := &argocdv1alpha1.AppProject{}
+	if err := repofs.ReadYamls(repofs.Join(store.Default.ProjectsDir, projectName+".yaml"), proj); err != nil {
+		return fmt.Errorf("failed to unmarshal project: %w", err)
+	}
+
+	app := &argocdv1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: appName,
+		},
+		Spec: argocdv1alpha1.ApplicationSpec{
+			Destination: argocdv1alpha1.ApplicationDestination{
+				Server:    appOpts.DestServer,
+				Namespace: appOpts.DestNamespace,
+			},
+		},
+	}
+	canSync, err := proj.Spec.SyncWindows.Matches(app).CanSync(false)
+	if err != nil {
+		return fmt.Errorf("failed to check the sync windows of project '%s': %w", projectName, err)
+	}
+
+	if !canSync {
+		return fmt.Errorf(util.Doc("application '%s' was committed, but it will not be synced until the sync windows of project '%s' allow it, run `<BIN> project sync-window list %s` to see them"), appName, projectName, projectName)
+	}
+
+	return nil
+}
+
 func getCommitMsg(opts *AppCreateOptions, repofs fs.FS) string {
 	commitMsg := fmt.Sprintf("installed app '%s' on project '%s'", opts.AppOpts.AppName, opts.ProjectName)
 	if repofs.Root() != "" {
@@ -356,6 +494,7 @@ func NewAppListCommand() *cobra.Command {
 			return RunAppList(ctx, &AppListOptions{
 				CloneOpts:   cloneOpts,
 				ProjectName: args[0],
+				Out:         os.Stdout,
 			})
 		},
 	}
@@ -379,8 +518,19 @@ func RunAppList(ctx context.Context, opts *AppListOptions) error {
 		log.G(ctx).Fatalf("failed to run glob on %s", opts.ProjectName)
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	_, _ = fmt.Fprintf(w, "PROJECT\tNAME\tDEST_NAMESPACE\tDEST_SERVER\t\n")
+	_, appSet, err := getProjectInfoFromFile(repofs, repofs.Join(store.Default.ProjectsDir, opts.ProjectName+".yaml"))
+	if err != nil {
+		return fmt.Errorf("failed to read project '%s': %w", opts.ProjectName, err)
+	}
+
+	clusters, err := application.ListClusters(repofs)
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	selector := getProjectClusterSelector(appSet)
+	w := tabwriter.NewWriter(opts.Out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "PROJECT\tNAME\tDEST_NAMESPACE\tDEST_SERVER\tCLUSTERS\t\n")
 
 	for _, appPath := range matches {
 		conf, err := getConfigFileFromPath(repofs, appPath)
@@ -388,13 +538,60 @@ func RunAppList(ctx context.Context, opts *AppListOptions) error {
 			return err
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", opts.ProjectName, conf.UserGivenName, conf.DestNamespace, conf.DestServer)
+		servers, names := []string{}, []string{}
+		for _, c := range getAppTargetClusters(clusters, selector, conf.DestServer) {
+			servers = append(servers, c.Server)
+			names = append(names, c.Name)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", opts.ProjectName, conf.UserGivenName, conf.DestNamespace, formatList(servers), formatList(names))
 	}
 
 	_ = w.Flush()
 	return nil
 }
 
+// isMultiClusterProject returns true if the project has a cluster selector
+func isMultiClusterProject(repofs fs.FS, projectName string) bool {
+	_, appSet, err := getProjectInfoFromFile(repofs, repofs.Join(store.Default.ProjectsDir, projectName+".yaml"))
+	return err == nil && getProjectClusterSelector(appSet) != nil
+}
+
+// getAppTargetClusters returns the clusters an app is deployed to. With a cluster selector, these
+// are all of the clusters with matching labels, otherwise it is the cluster of destServer
+func getAppTargetClusters(clusters []*application.ClusterResConfig, selector map[string]string, destServer string) []*application.ClusterResConfig {
+	res := []*application.ClusterResConfig{}
+	for _, c := range clusters {
+		if selector != nil && labels.SelectorFromSet(selector).Matches(labels.Set(c.Labels)) {
+			res = append(res, c)
+		} else if selector == nil && c.Server == destServer {
+			res = append(res, c)
+		}
+	}
+
+	if selector == nil && len(res) == 0 {
+		// a cluster that is not managed by autopilot
+		res = append(res, &application.ClusterResConfig{Server: destServer})
+	}
+
+	return res
+}
+
+func formatList(items []string) string {
+	res := []string{}
+	for _, item := range items {
+		if item != "" {
+			res = append(res, item)
+		}
+	}
+
+	if len(res) == 0 {
+		return "-"
+	}
+
+	return strings.Join(res, ",")
+}
+
 func getConfigFileFromPath(repofs fs.FS, appPath string) (*application.Config, error) {
 	path := repofs.Join(appPath, "config.json")
 	b, err := repofs.ReadFile(path)
@@ -521,3 +718,162 @@ func RunAppDelete(ctx context.Context, opts *AppDeleteOptions) error {
 
 	return nil
 }
+
+func NewAppImportCommand() *cobra.Command {
+	var (
+		cloneOpts   *git.CloneOptions
+		projectName string
+		selector    string
+		f           kube.Factory
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import existing Argo-CD applications into their projects",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Import all of the applications that are not managed by autopilot into their own projects
+
+	<BIN> app import
+
+# Import all of the applications that are not managed by autopilot into a single project
+
+	<BIN> app import --project <project_name>
+
+# Import only the applications that match a label selector
+
+	<BIN> app import --selector team=payments
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return RunAppImport(cmd.Context(), &AppImportOptions{
+				CloneOpts:   cloneOpts,
+				ProjectName: projectName,
+				Selector:    selector,
+				KubeFactory: f,
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&projectName, "project", "p", "", "Import the applications into this project, instead of the project of each application")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "Only import the applications that match this label selector (e.g. team=payments)")
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+	f = kube.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// RunAppImport writes an app for every live Application that is not generated by an
+// ApplicationSet, so the project ApplicationSet generates an equivalent one. Applications that can
+// not be imported are skipped with a warning. An Application that is already named like the
+// generated one is adopted by the ApplicationSet in place, and any other Application is deleted
+// after the commit, without deleting its resources
+func RunAppImport(ctx context.Context, opts *AppImportOptions) error {
+	r, repofs, err := prepareRepo(ctx, opts.CloneOpts, opts.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := getInstallationNamespace(repofs)
+	if err != nil {
+		return fmt.Errorf("failed to get application namespace: %w", err)
+	}
+
+	cs, err := getArgoCDClientSet(opts.KubeFactory)
+	if err != nil {
+		return fmt.Errorf("failed to create argo-cd clientset: %w", err)
+	}
+
+	apps, err := cs.ArgoprojV1alpha1().Applications(namespace).List(ctx, metav1.ListOptions{LabelSelector: opts.Selector})
+	if err != nil {
+		return fmt.Errorf("failed to list applications in namespace '%s': %w", namespace, err)
+	}
+
+	var toDelete []argocdv1alpha1.Application
+	imported := 0
+	for _, app := range apps.Items {
+		if len(app.OwnerReferences) > 0 || app.Labels[store.Default.LabelKeyAppManagedBy] == store.Default.LabelValueManagedBy {
+			log.G(ctx).Debugf("skipping application '%s', it is already managed", app.Name)
+			continue
+		}
+
+		projectName := opts.ProjectName
+		if projectName == "" {
+			projectName = app.Spec.Project
+		} else if projectName != app.Spec.Project {
+			log.G(ctx).Warnf("moving application '%s' from project '%s' to project '%s'", app.Name, app.Spec.Project, projectName)
+		}
+
+		// the project ApplicationSet names the applications it generates <project>-<app>
+		appName := strings.TrimPrefix(app.Name, projectName+"-")
+		if err = importApp(repofs, opts.CloneOpts, &app, appName, projectName); err != nil {
+			log.G(ctx).Warnf("skipping application '%s': %v", app.Name, err)
+			continue
+		}
+
+		log.G(ctx).Infof("imported application '%s' as '%s' into project '%s'", app.Name, appName, projectName)
+		imported++
+		if appName == app.Name {
+			toDelete = append(toDelete, app)
+		}
+	}
+
+	if imported == 0 {
+		return fmt.Errorf("no applications to import were found in namespace '%s'", namespace)
+	}
+
+	log.G(ctx).Info("committing changes to gitops repo...")
+	commitMsg := fmt.Sprintf("imported %d applications", imported)
+	if opts.ProjectName != "" {
+		commitMsg += fmt.Sprintf(" into project '%s'", opts.ProjectName)
+	}
+
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: commitMsg}); err != nil {
+		return fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	for i := range toDelete {
+		app := &toDelete[i]
+		if err = removeResourcesFinalizer(ctx, cs, app); err != nil {
+			return err
+		}
+
+		log.G(ctx).Infof("deleting original application '%s'", app.Name)
+		if err = cs.ArgoprojV1alpha1().Applications(namespace).Delete(ctx, app.Name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete application '%s': %w", app.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// importApp writes the files of a live Application as the app appName in projectName, which
+// must be an existing project that applies the sync policies of its apps
+func importApp(repofs fs.FS, cloneOpts *git.CloneOptions, app *argocdv1alpha1.Application, appName, projectName string) error {
+	if !repofs.ExistsOrDie(repofs.Join(store.Default.ProjectsDir, projectName+".yaml")) {
+		return fmt.Errorf("project '%s' not found, use --project to import it into an existing project", projectName)
+	}
+
+	if _, err := getSyncPolicyAppSet(repofs, projectName); err != nil {
+		return err
+	}
+
+	importedApp, err := application.NewImportedApp(repofs, appName, app, projectName, cloneOpts.URL(), cloneOpts.Revision(), cloneOpts.Path())
+	if err != nil {
+		return err
+	}
+
+	return importedApp.CreateFiles(repofs, repofs, projectName)
+}
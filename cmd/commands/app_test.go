@@ -15,24 +15,34 @@ import (
 	fsmocks "github.com/argoproj-labs/argocd-autopilot/pkg/fs/mocks"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
 	gitmocks "github.com/argoproj-labs/argocd-autopilot/pkg/git/mocks"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
 	kubemocks "github.com/argoproj-labs/argocd-autopilot/pkg/kube/mocks"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
 
 	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argocdcs "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned"
+	argocdfake "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned/fake"
 	"github.com/go-git/go-billy/v5/memfs"
 	billyUtils "github.com/go-git/go-billy/v5/util"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
 )
 
 func TestRunAppCreate(t *testing.T) {
 	tests := map[string]struct {
 		appsRepo                 string
+		sshKey                   string
 		timeout                  time.Duration
 		wantErr                  string
 		setAppOptsDefaultsErr    error
 		parseAppErr              error
+		validateAppErr           error
+		syncWindowsErr           error
 		createFilesErr           error
 		beforeFn                 func(f *kubemocks.MockFactory)
 		prepareRepo              func(*testing.T) (git.Repository, fs.FS, error)
@@ -69,6 +79,22 @@ func TestRunAppCreate(t *testing.T) {
 				return nil, nil, fmt.Errorf("some error")
 			},
 		},
+		"Should use cloneOpts ssh key for srcCloneOpts, if required": {
+			appsRepo: "https://github.com/owner/other_name/path?ref=branch",
+			sshKey:   "/home/user/.ssh/autopilot",
+			wantErr:  "some error",
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				return nil, nil, nil
+			},
+			getRepo: func(t *testing.T, opts *git.CloneOptions) (git.Repository, fs.FS, error) {
+				assert.Equal(t, "git@github.com:owner/other_name.git", opts.URL())
+				assert.Equal(t, "branch", opts.Revision())
+				assert.Equal(t, "path", opts.Path())
+				assert.Equal(t, "/home/user/.ssh/autopilot", opts.Auth.SSHKeyFile)
+				assert.Empty(t, opts.Auth.Password)
+				return nil, nil, fmt.Errorf("some error")
+			},
+		},
 		"Should fail if setAppOptsDefaults fails": {
 			wantErr: "some error",
 			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
@@ -83,6 +109,13 @@ func TestRunAppCreate(t *testing.T) {
 			},
 			parseAppErr: errors.New("some error"),
 		},
+		"Should fail if app is not permitted in project": {
+			wantErr: "some error",
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				return nil, nil, nil
+			},
+			validateAppErr: errors.New("some error"),
+		},
 		"Should fail if app already exist in project": {
 			wantErr:        fmt.Errorf("application 'app' already exists in project 'project': %w", application.ErrAppAlreadyInstalledOnProject).Error(),
 			createFilesErr: application.ErrAppAlreadyInstalledOnProject,
@@ -213,6 +246,23 @@ func TestRunAppCreate(t *testing.T) {
 				return mockRepo, fs.Create(memfs), nil
 			},
 		},
+		"Should fail before waiting if a sync window blocks the app": {
+			timeout:        1,
+			syncWindowsErr: errors.New("some error"),
+			wantErr:        "some error",
+			prepareRepo: func(t *testing.T) (git.Repository, fs.FS, error) {
+				memfs := memfs.New()
+				_ = memfs.MkdirAll(filepath.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project"), 0666)
+				mockRepo := gitmocks.NewMockRepository(gomock.NewController(t))
+				mockRepo.EXPECT().Persist(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return("revision", nil)
+				return mockRepo, fs.Create(memfs), nil
+			},
+			getInstallationNamespace: func(repofs fs.FS) (string, error) {
+				return "namespace", nil
+			},
+		},
 		"Should wait succesfully and complete": {
 			timeout: 1,
 			beforeFn: func(f *kubemocks.MockFactory) {
@@ -236,12 +286,14 @@ func TestRunAppCreate(t *testing.T) {
 			},
 		},
 	}
-	origPrepareRepo, origGetRepo, origSetAppOptsDefault, origAppParse, origGetInstallationNamespace := prepareRepo, getRepo, setAppOptsDefaults, parseApp, getInstallationNamespace
+	origPrepareRepo, origGetRepo, origSetAppOptsDefault, origAppParse, origValidateAppInProject, origCheckAppSyncWindows, origGetInstallationNamespace := prepareRepo, getRepo, setAppOptsDefaults, parseApp, validateAppInProject, checkAppSyncWindows, getInstallationNamespace
 	defer func() {
 		prepareRepo = origPrepareRepo
 		getRepo = origGetRepo
 		setAppOptsDefaults = origSetAppOptsDefault
 		parseApp = origAppParse
+		validateAppInProject = origValidateAppInProject
+		checkAppSyncWindows = origCheckAppSyncWindows
 		getInstallationNamespace = origGetInstallationNamespace
 	}()
 	for name, tt := range tests {
@@ -285,6 +337,12 @@ func TestRunAppCreate(t *testing.T) {
 				app.EXPECT().CreateFiles(gomock.Any(), gomock.Any(), "project").Return(tt.createFilesErr).AnyTimes()
 				return app, nil
 			}
+			validateAppInProject = func(_ fs.FS, _ *AppCreateOptions) error {
+				return tt.validateAppErr
+			}
+			checkAppSyncWindows = func(_ fs.FS, _, _ string, _ *application.CreateOptions) error {
+				return tt.syncWindowsErr
+			}
 			getInstallationNamespace = tt.getInstallationNamespace
 			opts := &AppCreateOptions{
 				Timeout: tt.timeout,
@@ -306,6 +364,10 @@ func TestRunAppCreate(t *testing.T) {
 				KubeFactory: f,
 			}
 
+			if tt.sshKey != "" {
+				opts.CloneOpts.Auth = git.Auth{SSHKeyFile: tt.sshKey}
+			}
+
 			opts.CloneOpts.Parse()
 			opts.AppsCloneOpts.Parse()
 			if err := RunAppCreate(context.Background(), opts); err != nil || tt.wantErr != "" {
@@ -677,6 +739,147 @@ func Test_getProjectDestServer(t *testing.T) {
 	}
 }
 
+func Test_validateAppInProject(t *testing.T) {
+	tests := map[string]struct {
+		appOpts *application.CreateOptions
+		spec    argocdv1alpha1.AppProjectSpec
+		wantErr string
+	}{
+		"Should allow any app in an unrestricted project": {
+			appOpts: &application.CreateOptions{
+				AppType:       application.AppTypeKustomize,
+				DestServer:    "https://prod.example.com",
+				DestNamespace: "any",
+			},
+			spec: argocdv1alpha1.AppProjectSpec{
+				SourceRepos:  []string{"*"},
+				Destinations: []argocdv1alpha1.ApplicationDestination{{Server: "*", Namespace: "*"}},
+			},
+		},
+		"Should allow a permitted destination": {
+			appOpts: &application.CreateOptions{
+				AppType:       application.AppTypeKustomize,
+				DestServer:    "https://kubernetes.default.svc",
+				DestNamespace: "team-a-web",
+			},
+			spec: argocdv1alpha1.AppProjectSpec{
+				SourceRepos:  []string{"https://github.com/owner/name"},
+				Destinations: []argocdv1alpha1.ApplicationDestination{{Server: "https://kubernetes.default.svc", Namespace: "team-a-*"}},
+			},
+		},
+		"Should fail if the namespace is not permitted": {
+			appOpts: &application.CreateOptions{
+				AppType:       application.AppTypeKustomize,
+				DestServer:    "https://kubernetes.default.svc",
+				DestNamespace: "kube-system",
+			},
+			spec: argocdv1alpha1.AppProjectSpec{
+				SourceRepos:  []string{"*"},
+				Destinations: []argocdv1alpha1.ApplicationDestination{{Server: "https://kubernetes.default.svc", Namespace: "team-a-*"}},
+			},
+			wantErr: "destination server 'https://kubernetes.default.svc' and namespace 'kube-system' are not permitted in project 'project'",
+		},
+		"Should fail if the gitops repo is not permitted for a kustomize app": {
+			appOpts: &application.CreateOptions{
+				AppType:       application.AppTypeKustomize,
+				DestServer:    "https://kubernetes.default.svc",
+				DestNamespace: "default",
+			},
+			spec: argocdv1alpha1.AppProjectSpec{
+				SourceRepos:  []string{"https://github.com/owner/apps"},
+				Destinations: []argocdv1alpha1.ApplicationDestination{{Server: "*", Namespace: "*"}},
+			},
+			wantErr: "source repository 'https://github.com/owner/name.git' is not permitted in project 'project'",
+		},
+		"Should check the app repo of a directory app": {
+			appOpts: &application.CreateOptions{
+				AppType:       application.AppTypeDirectory,
+				AppSpecifier:  "github.com/owner/other/manifests?ref=main",
+				DestServer:    "https://kubernetes.default.svc",
+				DestNamespace: "default",
+			},
+			spec: argocdv1alpha1.AppProjectSpec{
+				SourceRepos:  []string{"https://github.com/owner/apps"},
+				Destinations: []argocdv1alpha1.ApplicationDestination{{Server: "*", Namespace: "*"}},
+			},
+			wantErr: "source repository 'https://github.com/owner/other.git' is not permitted in project 'project'",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repofs := fs.Create(memfs.New())
+			project := &argocdv1alpha1.AppProject{
+				ObjectMeta: metav1.ObjectMeta{Name: "project"},
+				Spec:       tt.spec,
+			}
+			assert.NoError(t, repofs.WriteYamls(repofs.Join(store.Default.ProjectsDir, "project.yaml"), project))
+
+			cloneOpts := &git.CloneOptions{Repo: "https://github.com/owner/name"}
+			cloneOpts.Parse()
+			err := validateAppInProject(repofs, &AppCreateOptions{
+				CloneOpts:   cloneOpts,
+				ProjectName: "project",
+				AppOpts:     tt.appOpts,
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_checkAppSyncWindows(t *testing.T) {
+	tests := map[string]struct {
+		windows argocdv1alpha1.SyncWindows
+		wantErr string
+	}{
+		"Should pass without sync windows": {},
+		"Should pass if the deny window does not match the app": {
+			windows: argocdv1alpha1.SyncWindows{
+				{Kind: "deny", Schedule: "* * * * *", Duration: "1h", Applications: []string{"other-*"}},
+			},
+		},
+		"Should fail if a deny window of the app is active": {
+			windows: argocdv1alpha1.SyncWindows{
+				{Kind: "deny", Schedule: "* * * * *", Duration: "1h", Applications: []string{"project-*"}},
+			},
+			wantErr: "application 'project-app' was committed, but it will not be synced until the sync windows of project 'project' allow it, run `argocd-autopilot project sync-window list project` to see them",
+		},
+		"Should fail if the deny window of the namespace is active, even with manual sync": {
+			windows: argocdv1alpha1.SyncWindows{
+				{Kind: "deny", Schedule: "* * * * *", Duration: "1h", Namespaces: []string{"default"}, ManualSync: true},
+			},
+			wantErr: "application 'project-app' was committed, but it will not be synced until the sync windows of project 'project' allow it, run `argocd-autopilot project sync-window list project` to see them",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repofs := fs.Create(memfs.New())
+			project := &argocdv1alpha1.AppProject{
+				ObjectMeta: metav1.ObjectMeta{Name: "project"},
+				Spec: argocdv1alpha1.AppProjectSpec{
+					SyncWindows: tt.windows,
+				},
+			}
+			assert.NoError(t, repofs.WriteYamls(repofs.Join(store.Default.ProjectsDir, "project.yaml"), project))
+
+			err := checkAppSyncWindows(repofs, "project", "project-app", &application.CreateOptions{
+				DestServer:    "https://kubernetes.default.svc",
+				DestNamespace: "default",
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
 func Test_setAppOptsDefaults(t *testing.T) {
 	tests := map[string]struct {
 		opts     *AppCreateOptions
@@ -844,3 +1047,371 @@ func Test_setAppOptsDefaults(t *testing.T) {
 		})
 	}
 }
+
+func TestRunAppImport(t *testing.T) {
+	newApp := func(name string, srcType argocdv1alpha1.ApplicationSourceType, src *argocdv1alpha1.ApplicationSource) *argocdv1alpha1.Application {
+		return &argocdv1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  "argocd",
+				Labels:     map[string]string{"team": "payments"},
+				Finalizers: []string{argocdv1alpha1.ResourcesFinalizerName},
+			},
+			Spec: argocdv1alpha1.ApplicationSpec{
+				Project: "project",
+				Source:  src,
+				Destination: argocdv1alpha1.ApplicationDestination{
+					Server:    store.Default.DestServer,
+					Namespace: "payments",
+				},
+			},
+			Status: argocdv1alpha1.ApplicationStatus{SourceType: srcType},
+		}
+	}
+	gitSrc := &argocdv1alpha1.ApplicationSource{
+		RepoURL:        "https://github.com/owner/apps",
+		Path:           "payments",
+		TargetRevision: "main",
+	}
+	kustomizeApp := func(name string) *argocdv1alpha1.Application {
+		return newApp(name, argocdv1alpha1.ApplicationSourceTypeKustomize, gitSrc)
+	}
+
+	tests := map[string]struct {
+		projectName string
+		selector    string
+		apps        []runtime.Object
+		beforeFn    func(t *testing.T, repofs fs.FS)
+		gitFn       func(r *gitmocks.MockRepository)
+		assertFn    func(t *testing.T, repofs fs.FS, cs argocdcs.Interface, err error)
+	}{
+		"Should import an application and delete the original": {
+			projectName: "project",
+			apps: []runtime.Object{func() runtime.Object {
+				app := kustomizeApp("payments")
+				app.Spec.Source = gitSrc.DeepCopy()
+				app.Spec.Source.Kustomize = &argocdv1alpha1.ApplicationSourceKustomize{NamePrefix: "prod-"}
+				app.Spec.SyncPolicy = &argocdv1alpha1.SyncPolicy{Automated: &argocdv1alpha1.SyncPolicyAutomated{Prune: true}}
+				return app
+			}()},
+			gitFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "imported 1 applications into project 'project'"}).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, cs argocdcs.Interface, err error) {
+				assert.NoError(t, err)
+				conf := &application.Config{}
+				assert.NoError(t, repofs.ReadJson("apps/payments/overlays/project/config.json", conf))
+				assert.Equal(t, "payments", conf.UserGivenName)
+				assert.Equal(t, "https://github.com/owner/gitops.git", conf.SrcRepoURL)
+				assert.Equal(t, "apps/payments/overlays/project", conf.SrcPath)
+				assert.Equal(t, "payments", conf.DestNamespace)
+				assert.Equal(t, &argocdv1alpha1.SyncPolicy{Automated: &argocdv1alpha1.SyncPolicyAutomated{Prune: true}}, conf.SyncPolicy)
+				base := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls("apps/payments/base/kustomization.yaml", base))
+				assert.Equal(t, []string{"https://github.com/owner/apps//payments?ref=main"}, base.Resources)
+				overlay := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls("apps/payments/overlays/project/kustomization.yaml", overlay))
+				assert.Equal(t, "prod-", overlay.NamePrefix)
+				assert.True(t, repofs.ExistsOrDie("bootstrap/cluster-resources/in-cluster/payments-ns.yaml"))
+				issues, err := checkApps(repofs, map[string]bool{"project": true}, map[string]string{store.Default.DestServer: store.Default.ClusterContextName}, map[string]map[string]bool{})
+				assert.NoError(t, err)
+				assert.Empty(t, issues)
+				_, err = cs.ArgoprojV1alpha1().Applications("argocd").Get(context.Background(), "payments", metav1.GetOptions{})
+				assert.True(t, kerrors.IsNotFound(err))
+			},
+		},
+		"Should keep an application that is named like the generated one": {
+			apps: []runtime.Object{kustomizeApp("project-payments")},
+			gitFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "imported 1 applications"}).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, cs argocdcs.Interface, err error) {
+				assert.NoError(t, err)
+				assert.True(t, repofs.ExistsOrDie("apps/payments/overlays/project/config.json"))
+				app, err := cs.ArgoprojV1alpha1().Applications("argocd").Get(context.Background(), "project-payments", metav1.GetOptions{})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{argocdv1alpha1.ResourcesFinalizerName}, app.Finalizers)
+			},
+		},
+		"Should import a directory application": {
+			apps: []runtime.Object{newApp("payments", "", &argocdv1alpha1.ApplicationSource{
+				RepoURL:   "https://github.com/owner/apps",
+				Path:      "payments",
+				Directory: &argocdv1alpha1.ApplicationSourceDirectory{Recurse: true, Exclude: "*.json"},
+			})},
+			gitFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, _ argocdcs.Interface, err error) {
+				assert.NoError(t, err)
+				conf := map[string]interface{}{}
+				assert.NoError(t, repofs.ReadJson("apps/payments/project/config_dir.json", &conf))
+				assert.Equal(t, "*.json", conf["exclude"])
+				assert.Equal(t, "payments", conf["srcPath"])
+				assert.Equal(t, map[string]interface{}{}, conf["syncPolicy"])
+			},
+		},
+		"Should import an application into the given project": {
+			projectName: "project",
+			apps: []runtime.Object{func() runtime.Object {
+				app := kustomizeApp("payments")
+				app.Spec.Project = "default"
+				return app
+			}()},
+			gitFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, _ argocdcs.Interface, err error) {
+				assert.NoError(t, err)
+				assert.True(t, repofs.ExistsOrDie("apps/payments/overlays/project/config.json"))
+			},
+		},
+		"Should skip applications that can not be imported": {
+			apps: []runtime.Object{
+				kustomizeApp("payments"),
+				func() runtime.Object {
+					app := newApp("multi", "", nil)
+					app.Spec.Sources = argocdv1alpha1.ApplicationSources{*gitSrc, *gitSrc}
+					return app
+				}(),
+				newApp("chart", "", &argocdv1alpha1.ApplicationSource{RepoURL: "https://charts.example.com", Chart: "chart"}),
+				func() runtime.Object {
+					app := kustomizeApp("other")
+					app.Spec.Project = "other"
+					return app
+				}(),
+			},
+			gitFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "imported 1 applications"}).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, _ argocdcs.Interface, err error) {
+				assert.NoError(t, err)
+				assert.True(t, repofs.ExistsOrDie("apps/payments/overlays/project/config.json"))
+				assert.False(t, repofs.ExistsOrDie("apps/multi"))
+				assert.False(t, repofs.ExistsOrDie("apps/chart"))
+				assert.False(t, repofs.ExistsOrDie("apps/other"))
+			},
+		},
+		"Should skip applications of a project that does not support app sync policies": {
+			apps: []runtime.Object{kustomizeApp("payments")},
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				appSetYAML, err := createAppSet(&createAppSetOptions{name: "project"})
+				assert.NoError(t, err)
+				assert.NoError(t, billyUtils.WriteFile(repofs, "projects/project.yaml", util.JoinManifests([]byte("kind: AppProject"), appSetYAML), 0666))
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, _ argocdcs.Interface, err error) {
+				assert.EqualError(t, err, "no applications to import were found in namespace 'argocd'")
+				assert.False(t, repofs.ExistsOrDie("apps/payments"))
+			},
+		},
+		"Should skip applications that are already managed": {
+			apps: []runtime.Object{
+				&argocdv1alpha1.Application{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "other-app",
+						Namespace:       "argocd",
+						OwnerReferences: []metav1.OwnerReference{{Kind: "ApplicationSet", Name: "other"}},
+					},
+				},
+				&argocdv1alpha1.Application{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      store.Default.RootAppName,
+						Namespace: "argocd",
+						Labels:    map[string]string{store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy},
+					},
+				},
+			},
+			assertFn: func(t *testing.T, _ fs.FS, _ argocdcs.Interface, err error) {
+				assert.EqualError(t, err, "no applications to import were found in namespace 'argocd'")
+			},
+		},
+		"Should only import applications that match the selector": {
+			selector: "team=infra",
+			apps:     []runtime.Object{kustomizeApp("payments")},
+			assertFn: func(t *testing.T, _ fs.FS, _ argocdcs.Interface, err error) {
+				assert.EqualError(t, err, "no applications to import were found in namespace 'argocd'")
+			},
+		},
+	}
+
+	origPrepareRepo, origGetArgoCDClientSet := prepareRepo, getArgoCDClientSet
+	defer func() {
+		prepareRepo = origPrepareRepo
+		getArgoCDClientSet = origGetArgoCDClientSet
+	}()
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := gitmocks.NewMockRepository(gomock.NewController(t))
+			if tt.gitFn != nil {
+				tt.gitFn(r)
+			}
+
+			repofs := fs.Create(memfs.New())
+			assert.NoError(t, repofs.WriteYamls("bootstrap/argo-cd.yaml", &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Destination: argocdv1alpha1.ApplicationDestination{Namespace: "argocd"},
+				},
+			}))
+			assert.NoError(t, repofs.WriteJson("bootstrap/cluster-resources/in-cluster.json", &application.ClusterResConfig{
+				Name:   store.Default.ClusterContextName,
+				Server: store.Default.DestServer,
+			}))
+			projectYAML, appSetYAML, _, _, err := generateProjectManifests(&GenerateProjectOptions{Name: "project"})
+			assert.NoError(t, err)
+			assert.NoError(t, billyUtils.WriteFile(repofs, "projects/project.yaml", util.JoinManifests(projectYAML, appSetYAML), 0666))
+			if tt.beforeFn != nil {
+				tt.beforeFn(t, repofs)
+			}
+
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				return r, repofs, nil
+			}
+
+			cs := argocdfake.NewSimpleClientset(tt.apps...)
+			getArgoCDClientSet = func(_ kube.Factory) (argocdcs.Interface, error) {
+				return cs, nil
+			}
+
+			cloneOpts := &git.CloneOptions{Repo: "https://github.com/owner/gitops"}
+			cloneOpts.Parse()
+			err = RunAppImport(context.Background(), &AppImportOptions{
+				CloneOpts:   cloneOpts,
+				ProjectName: tt.projectName,
+				Selector:    tt.selector,
+			})
+			tt.assertFn(t, repofs, cs, err)
+		})
+	}
+}
+
+func Test_getAppSyncPolicy(t *testing.T) {
+	no := false
+	tests := map[string]struct {
+		projectYAML func(t *testing.T) []byte
+		want        *argocdv1alpha1.SyncPolicy
+		wantErr     string
+	}{
+		"should override the sync policy of the project": {
+			projectYAML: func(t *testing.T) []byte {
+				projectYAML, appSetYAML, _, _, err := generateProjectManifests(&GenerateProjectOptions{
+					Name:       "project",
+					SyncPolicy: &argocdv1alpha1.SyncPolicy{SyncOptions: argocdv1alpha1.SyncOptions{"CreateNamespace=true"}},
+				})
+				assert.NoError(t, err)
+				return util.JoinManifests(projectYAML, appSetYAML)
+			},
+			want: &argocdv1alpha1.SyncPolicy{SyncOptions: argocdv1alpha1.SyncOptions{"CreateNamespace=true"}},
+		},
+		"should fail if the project does not have the template patch": {
+			projectYAML: func(t *testing.T) []byte {
+				appSetYAML, err := createAppSet(&createAppSetOptions{name: "project"})
+				assert.NoError(t, err)
+				return util.JoinManifests([]byte("kind: AppProject"), appSetYAML)
+			},
+			wantErr: util.Doc("project 'project' does not support app sync policies, update it with `<BIN> project update project` first"),
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := fs.Create(memfs.New())
+			_ = billyUtils.WriteFile(repofs, "projects/project.yaml", tt.projectYAML(t), 0666)
+			got, err := getAppSyncPolicy(repofs, "project", &SyncPolicyOptions{AutoSync: &no})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_getAppTargetClusters(t *testing.T) {
+	clusters := []*application.ClusterResConfig{
+		{Name: "in-cluster", Server: "https://kubernetes.default.svc"},
+		{Name: "prod-eu", Server: "https://prod-eu.example.com", Labels: map[string]string{"env": "prod", "region": "eu"}},
+		{Name: "prod-us", Server: "https://prod-us.example.com", Labels: map[string]string{"env": "prod", "region": "us"}},
+	}
+	tests := map[string]struct {
+		selector   map[string]string
+		destServer string
+		want       []string
+	}{
+		"should return the cluster of the destination server": {
+			destServer: "https://prod-eu.example.com",
+			want:       []string{"prod-eu"},
+		},
+		"should return an unknown destination server": {
+			destServer: "https://other.example.com",
+			want:       []string{""},
+		},
+		"should return all of the matching clusters": {
+			selector:   map[string]string{"env": "prod"},
+			destServer: "https://kubernetes.default.svc",
+			want:       []string{"prod-eu", "prod-us"},
+		},
+		"should return nothing if no cluster matches": {
+			selector: map[string]string{"env": "staging"},
+			want:     []string{},
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			got := []string{}
+			for _, c := range getAppTargetClusters(clusters, tt.selector, tt.destServer) {
+				got = append(got, c.Name)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRunAppList(t *testing.T) {
+	tests := map[string]struct {
+		clusterSelector map[string]string
+		want            []string
+	}{
+		"should list the destination of the apps": {
+			want: []string{"project", "app1", "app1", "https://kubernetes.default.svc", "in-cluster"},
+		},
+		"should list the clusters of the cluster selector": {
+			clusterSelector: map[string]string{"env": "prod"},
+			want:            []string{"project", "app1", "app1", "https://prod.example.com", "prod"},
+		},
+	}
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := fs.Create(memfs.New())
+			projectYAML, appSetYAML, _, _, err := generateProjectManifests(&GenerateProjectOptions{
+				Name:              "project",
+				DefaultDestServer: "https://kubernetes.default.svc",
+				ClusterSelector:   tt.clusterSelector,
+			})
+			assert.NoError(t, err)
+			_ = billyUtils.WriteFile(repofs, "projects/project.yaml", util.JoinManifests(projectYAML, appSetYAML), 0666)
+			_ = repofs.WriteJson("bootstrap/cluster-resources/in-cluster.json", &application.ClusterResConfig{Name: "in-cluster", Server: "https://kubernetes.default.svc"})
+			_ = repofs.WriteJson("bootstrap/cluster-resources/prod.json", &application.ClusterResConfig{Name: "prod", Server: "https://prod.example.com", Labels: map[string]string{"env": "prod"}})
+			_ = repofs.WriteJson("apps/app1/overlays/project/config.json", &application.Config{UserGivenName: "app1", DestNamespace: "app1", DestServer: "https://kubernetes.default.svc"})
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				return nil, repofs, nil
+			}
+
+			out := &strings.Builder{}
+			err = RunAppList(context.Background(), &AppListOptions{
+				CloneOpts:   &git.CloneOptions{},
+				ProjectName: "project",
+				Out:         out,
+			})
+			assert.NoError(t, err)
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			assert.Len(t, lines, 2)
+			assert.Equal(t, []string{"PROJECT", "NAME", "DEST_NAMESPACE", "DEST_SERVER", "CLUSTERS"}, strings.Fields(lines[0]))
+			assert.Equal(t, tt.want, strings.Fields(lines[1]))
+		})
+	}
+}
@@ -0,0 +1,671 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/application"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/argocd"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	fsutils "github.com/argoproj-labs/argocd-autopilot/pkg/fs/utils"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	restclient "k8s.io/client-go/rest"
+)
+
+const (
+	clusterSecretFileSuffix = "-cluster.yaml"
+
+	clusterOutputGit     = "git"
+	clusterOutputCluster = "cluster"
+	clusterOutputStdout  = "stdout"
+)
+
+type (
+	ClusterAddOptions struct {
+		CloneOpts   *git.CloneOptions
+		KubeFactory kube.Factory
+		KubeContext string
+		ClusterName string
+		Server      string
+		// Output is one of git|cluster|stdout
+		Output          string
+		SystemNamespace string
+		ServiceAccount  string
+		Timeout         time.Duration
+		Creds           *RepoCredsOptions
+		// Labels are set on the cluster secret, and select the cluster in projects with a cluster selector
+		Labels map[string]string
+	}
+
+	ClusterListOptions struct {
+		CloneOpts *git.CloneOptions
+		Out       io.Writer
+	}
+
+	ClusterRemoveOptions struct {
+		CloneOpts   *git.CloneOptions
+		ClusterName string
+		MoveAppsTo  string
+	}
+
+	clusterSecretOptions struct {
+		kubeContext     string
+		clusterName     string
+		server          string
+		namespace       string
+		systemNamespace string
+		serviceAccount  string
+		timeout         time.Duration
+		labels          map[string]string
+	}
+)
+
+// used for mocking
+var (
+	getContextRESTConfig  = kube.ContextRESTConfig
+	getContextKubeFactory = kube.NewFactoryForContext
+	installClusterManager = argocd.InstallClusterManager
+)
+
+func NewClusterCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Manage destination clusters",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.HelpFunc()(cmd, args)
+			exit(1)
+		},
+	}
+
+	cmd.AddCommand(NewClusterAddCommand())
+	cmd.AddCommand(NewClusterListCommand())
+	cmd.AddCommand(NewClusterRemoveCommand())
+
+	return cmd
+}
+
+func NewClusterAddCommand() *cobra.Command {
+	var (
+		clusterName     string
+		server          string
+		output          string
+		systemNamespace string
+		serviceAccount  string
+		timeout         time.Duration
+		labels          map[string]string
+		creds           = &RepoCredsOptions{}
+		cloneOpts       *git.CloneOptions
+		f               kube.Factory
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add [KUBE_CONTEXT]",
+		Short: "Add a destination cluster to the GitOps repository",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Add the cluster of a kubernetes context, committing its credentials as a SealedSecret
+
+	<BIN> cluster add <KUBE_CONTEXT> --creds-mode sealed-secret --sealed-secrets-cert <cert_file>
+
+# Add the cluster of a kubernetes context, applying its credentials to the argo-cd cluster
+# instead of committing them
+
+	<BIN> cluster add <KUBE_CONTEXT> --output cluster --context <ARGOCD_KUBE_CONTEXT>
+
+# Add the cluster of a kubernetes context with labels, that projects with a matching
+# --cluster-selector deploy to
+
+	<BIN> cluster add <KUBE_CONTEXT> --labels env=prod,region=eu --creds-mode sealed-secret --sealed-secrets-cert <cert_file>
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 1 {
+				log.G(ctx).Fatal("must enter kubernetes context name")
+			}
+
+			return RunClusterAdd(ctx, &ClusterAddOptions{
+				CloneOpts:       cloneOpts,
+				KubeFactory:     f,
+				KubeContext:     args[0],
+				ClusterName:     clusterName,
+				Server:          server,
+				Output:          output,
+				SystemNamespace: systemNamespace,
+				ServiceAccount:  serviceAccount,
+				Timeout:         timeout,
+				Creds:           creds,
+				Labels:          labels,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&clusterName, "name", "", "The name of the cluster in argo-cd (defaults to the kubernetes context name)")
+	cmd.Flags().StringVar(&server, "server", "", "The address argo-cd will use to reach the cluster (defaults to the server of the kubernetes context)")
+	cmd.Flags().StringVarP(&output, "output", "o", clusterOutputGit, "One of: git|cluster|stdout. "+
+		"If git, will commit the cluster secret to the repository, if cluster, will apply it to the argo-cd cluster, and if stdout, will only print it")
+	cmd.Flags().StringVar(&systemNamespace, "system-namespace", argocd.DefaultClusterManagerNamespace, "The namespace of the argo-cd service account, in the added cluster")
+	cmd.Flags().StringVar(&serviceAccount, "service-account", argocd.DefaultClusterManagerServiceAccount, "The name of the service account argo-cd will use to manage the added cluster")
+	cmd.Flags().DurationVar(&timeout, "timeout", time.Minute, "The max time to wait for the service account token")
+	cmd.Flags().StringToStringVar(&labels, "labels", nil, "Labels of the cluster, used by the --cluster-selector of projects (e.g. \"env=prod,region=eu\")")
+	cmd.Flags().StringVar(&creds.Mode, "creds-mode", "", "One of: plain|external-secret|sealed-secret. "+
+		"How the cluster credentials secret will be committed to the repository, required with --output git")
+	cmd.Flags().StringVar(&creds.SecretStore, "creds-secret-store", "", "The [<kind>/]<name> of the SecretStore (or ClusterSecretStore) that holds the cluster config, used in external-secret mode")
+	cmd.Flags().StringVar(&creds.RemoteKey, "creds-remote-key", "", "The key of the cluster config in the SecretStore, used in external-secret mode")
+	cmd.Flags().StringVar(&creds.SealingCert, "sealed-secrets-cert", "", "A file with the public certificate of the sealed-secrets controller, used in sealed-secret mode")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+	f = kube.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func RunClusterAdd(ctx context.Context, opts *ClusterAddOptions) error {
+	switch opts.Output {
+	case "":
+		opts.Output = clusterOutputGit
+	case clusterOutputGit, clusterOutputCluster, clusterOutputStdout:
+	default:
+		return fmt.Errorf("unknown output: %s", opts.Output)
+	}
+
+	if opts.Output == clusterOutputGit && opts.Creds.Mode == "" {
+		return fmt.Errorf("--creds-mode is required with git output, use --creds-mode %s to commit the cluster credentials as a plain secret", repoCredsModePlain)
+	}
+
+	if err := validateClusterCreds(opts.Creds); err != nil {
+		return err
+	}
+
+	if opts.ClusterName == "" {
+		opts.ClusterName = opts.KubeContext
+	}
+
+	if opts.ClusterName == store.Default.ClusterContextName {
+		return fmt.Errorf("cluster '%s' is where argo-cd is installed, and is always configured", opts.ClusterName)
+	}
+
+	r, repofs, err := prepareRepo(ctx, opts.CloneOpts, "")
+	if err != nil {
+		return err
+	}
+
+	namespace, err := getInstallationNamespace(repofs)
+	if err != nil {
+		return fmt.Errorf("failed to get installation namespace: %w", err)
+	}
+
+	clusters, err := application.ListClusters(repofs)
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	rc, err := getContextRESTConfig(opts.KubeContext)
+	if err != nil {
+		return fmt.Errorf("failed to get kubernetes context '%s': %w", opts.KubeContext, err)
+	}
+
+	if opts.Server == "" {
+		opts.Server = rc.Host
+	}
+
+	for _, c := range clusters {
+		if c.Name == opts.ClusterName || c.Server == opts.Server {
+			return fmt.Errorf("cluster '%s' (%s) already exists", c.Name, c.Server)
+		}
+	}
+
+	secret, err := generateClusterSecret(ctx, rc, &clusterSecretOptions{
+		kubeContext:     opts.KubeContext,
+		clusterName:     opts.ClusterName,
+		server:          opts.Server,
+		namespace:       namespace,
+		systemNamespace: opts.SystemNamespace,
+		serviceAccount:  opts.ServiceAccount,
+		timeout:         opts.Timeout,
+		labels:          opts.Labels,
+	})
+	if err != nil {
+		return err
+	}
+
+	creds := opts.Creds
+	if opts.Output == clusterOutputCluster {
+		creds = &RepoCredsOptions{Mode: repoCredsModePlain}
+	}
+
+	secretYAML, err := getSecretManifest(secret, "config", creds)
+	if err != nil {
+		return fmt.Errorf("failed to generate cluster secret: %w", err)
+	}
+
+	if opts.Output == clusterOutputStdout {
+		log.G(ctx).Printf("%s", secretYAML)
+		return nil
+	}
+
+	clusterResConf, err := json.Marshal(&application.ClusterResConfig{Name: opts.ClusterName, Server: opts.Server, Labels: opts.Labels})
+	if err != nil {
+		return fmt.Errorf("failed to create cluster resources config: %w", err)
+	}
+
+	clusterResPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir)
+	bulkWrites := []fsutils.BulkWriteRequest{
+		{
+			Filename: repofs.Join(clusterResPath, opts.ClusterName+".json"),
+			Data:     clusterResConf,
+			ErrMsg:   "failed to write cluster config",
+		},
+		{
+			Filename: repofs.Join(clusterResPath, opts.ClusterName, "README.md"),
+			Data:     []byte(strings.ReplaceAll(string(clusterResReadmeTpl), "{CLUSTER}", opts.Server)),
+			ErrMsg:   "failed to write cluster resources readme",
+		},
+	}
+
+	if opts.Output == clusterOutputCluster {
+		log.G(ctx).Infof("applying cluster secret to the argo-cd cluster")
+		if err = opts.KubeFactory.Apply(ctx, secretYAML); err != nil {
+			return fmt.Errorf("failed to apply cluster secret: %w", err)
+		}
+	} else {
+		if creds.Mode == repoCredsModePlain {
+			log.G(ctx).Warn("committing the cluster credentials to git as a plain secret, consider using --creds-mode")
+		}
+
+		bulkWrites = append(bulkWrites, fsutils.BulkWriteRequest{
+			Filename: getClusterSecretPath(repofs, opts.ClusterName),
+			Data:     secretYAML,
+			ErrMsg:   "failed to write cluster secret",
+		})
+	}
+
+	if err = fsutils.BulkWrite(repofs, bulkWrites...); err != nil {
+		return err
+	}
+
+	log.G(ctx).Info("committing changes to gitops repo...")
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("Added cluster '%s'", opts.ClusterName)}); err != nil {
+		return fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	log.G(ctx).Infof("cluster added: '%s'", opts.ClusterName)
+
+	return nil
+}
+
+// generateClusterSecret installs the argo-cd service account in the cluster of the kubernetes
+// context, and returns the argo-cd cluster secret that uses its token. rc is the rest config
+// of the kubernetes context
+func generateClusterSecret(ctx context.Context, rc *restclient.Config, opts *clusterSecretOptions) (*v1.Secret, error) {
+	if opts.systemNamespace == "" {
+		opts.systemNamespace = argocd.DefaultClusterManagerNamespace
+	}
+
+	if opts.serviceAccount == "" {
+		opts.serviceAccount = argocd.DefaultClusterManagerServiceAccount
+	}
+
+	log.G(ctx).Infof("creating service account '%s/%s' in context '%s'", opts.systemNamespace, opts.serviceAccount, opts.kubeContext)
+	token, err := installClusterManager(ctx, getContextKubeFactory(opts.kubeContext), &argocd.ClusterManagerOptions{
+		Namespace:      opts.systemNamespace,
+		ServiceAccount: opts.serviceAccount,
+		WaitInterval:   store.Default.WaitInterval,
+		Timeout:        opts.timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to install the argo-cd service account: %w", err)
+	}
+
+	config, err := argocd.ClusterConfigFromREST(rc, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return argocd.GenerateClusterSecret(&argocd.ClusterSecretOptions{
+		Name:      opts.clusterName,
+		Server:    opts.server,
+		Namespace: opts.namespace,
+		Config:    config,
+		Labels:    opts.labels,
+	})
+}
+
+func NewClusterListCommand() *cobra.Command {
+	var (
+		cloneOpts *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all of the destination clusters in the GitOps repository",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# List clusters
+
+	<BIN> cluster list
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunClusterList(cmd.Context(), &ClusterListOptions{
+				CloneOpts: cloneOpts,
+				Out:       os.Stdout,
+			})
+		},
+	}
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS: memfs.New(),
+	})
+
+	return cmd
+}
+
+func RunClusterList(ctx context.Context, opts *ClusterListOptions) error {
+	_, repofs, err := prepareRepo(ctx, opts.CloneOpts, "")
+	if err != nil {
+		return err
+	}
+
+	clusters, err := application.ListClusters(repofs)
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	w := tabwriter.NewWriter(opts.Out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "NAME\tSERVER\tAPPS\t\n")
+
+	for _, c := range clusters {
+		apps, err := getAppConfigsByServer(repofs, c.Server)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t\n", c.Name, c.Server, len(apps))
+	}
+
+	_ = w.Flush()
+	return nil
+}
+
+func NewClusterRemoveCommand() *cobra.Command {
+	var (
+		moveAppsTo string
+		cloneOpts  *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "remove [CLUSTER_NAME]",
+		Short: "Remove a destination cluster from the GitOps repository",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Remove a cluster that no app is deployed to
+
+	<BIN> cluster remove <CLUSTER_NAME>
+
+# Remove a cluster, and move all of its apps to another cluster
+
+	<BIN> cluster remove <CLUSTER_NAME> --move-apps-to <OTHER_CLUSTER_NAME>
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 1 {
+				log.G(ctx).Fatal("must enter cluster name")
+			}
+
+			return RunClusterRemove(ctx, &ClusterRemoveOptions{
+				CloneOpts:   cloneOpts,
+				ClusterName: args[0],
+				MoveAppsTo:  moveAppsTo,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&moveAppsTo, "move-apps-to", "", "The name of a cluster that all of the apps of the removed cluster will be moved to")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	return cmd
+}
+
+func RunClusterRemove(ctx context.Context, opts *ClusterRemoveOptions) error {
+	if opts.ClusterName == store.Default.ClusterContextName {
+		return fmt.Errorf("cannot remove cluster '%s', it is where argo-cd is installed", opts.ClusterName)
+	}
+
+	r, repofs, err := prepareRepo(ctx, opts.CloneOpts, "")
+	if err != nil {
+		return err
+	}
+
+	clusters, err := application.ListClusters(repofs)
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	var cluster, target *application.ClusterResConfig
+	for _, c := range clusters {
+		if c.Name == opts.ClusterName {
+			cluster = c
+		}
+
+		if opts.MoveAppsTo != "" && c.Name == opts.MoveAppsTo {
+			target = c
+		}
+	}
+
+	if cluster == nil {
+		return fmt.Errorf("cluster '%s' not found", opts.ClusterName)
+	}
+
+	if opts.MoveAppsTo != "" && target == nil {
+		return fmt.Errorf("cluster '%s' not found", opts.MoveAppsTo)
+	}
+
+	if target == cluster {
+		return fmt.Errorf("cannot move the apps of cluster '%s' to itself", opts.ClusterName)
+	}
+
+	apps, err := getAppConfigsByServer(repofs, cluster.Server)
+	if err != nil {
+		return err
+	}
+
+	if len(apps) > 0 {
+		if target == nil {
+			return fmt.Errorf("cluster '%s' is the destination of %d app(s): %s. Use --move-apps-to to move them to another cluster, or delete them first",
+				opts.ClusterName, len(apps), strings.Join(apps, ", "))
+		}
+
+		if err = moveApps(repofs, apps, target); err != nil {
+			return err
+		}
+
+		log.G(ctx).Infof("moved %d app(s) to cluster '%s'", len(apps), target.Name)
+	}
+
+	clusterResPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir)
+	if err = repofs.Remove(repofs.Join(clusterResPath, cluster.Name+".json")); err != nil {
+		return fmt.Errorf("failed to delete cluster config: %w", err)
+	}
+
+	if err = billyUtils.RemoveAll(repofs, repofs.Join(clusterResPath, cluster.Name)); err != nil {
+		return fmt.Errorf("failed to delete cluster resources: %w", err)
+	}
+
+	secretPath := getClusterSecretPath(repofs, cluster.Name)
+	if repofs.ExistsOrDie(secretPath) {
+		if err = repofs.Remove(secretPath); err != nil {
+			return fmt.Errorf("failed to delete cluster secret: %w", err)
+		}
+
+		log.G(ctx).Warnf("cluster resources are not pruned, delete the '%s' secret from the argo-cd namespace to disconnect the cluster", argocd.ClusterSecretName(cluster.Name))
+	}
+
+	log.G(ctx).Info("committing changes to gitops repo...")
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("Removed cluster '%s'", opts.ClusterName)}); err != nil {
+		return fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	log.G(ctx).Infof("cluster removed: '%s'", opts.ClusterName)
+
+	return nil
+}
+
+func validateClusterCreds(opts *RepoCredsOptions) error {
+	switch opts.Mode {
+	case "", repoCredsModePlain:
+	case repoCredsModeExternalSecret:
+		if opts.SecretStore == "" || opts.RemoteKey == "" {
+			return fmt.Errorf("--creds-secret-store and --creds-remote-key are required in %s mode", repoCredsModeExternalSecret)
+		}
+	case repoCredsModeSealedSecret:
+		if opts.SealingCert == "" {
+			return fmt.Errorf("--sealed-secrets-cert is required in %s mode", repoCredsModeSealedSecret)
+		}
+	default:
+		return fmt.Errorf("unknown creds mode: %s", opts.Mode)
+	}
+
+	return nil
+}
+
+// getClusterSecretPath returns the path of the cluster secret, which is applied together
+// with the cluster resources of the cluster argo-cd is installed on
+func getClusterSecretPath(repofs fs.FS, clusterName string) string {
+	return repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, store.Default.ClusterContextName, clusterName+clusterSecretFileSuffix)
+}
+
+// getAppConfigsByServer returns the paths of all of the app config files with server
+// as their destination
+func getAppConfigsByServer(repofs fs.FS, server string) ([]string, error) {
+	patterns := []string{
+		repofs.Join(store.Default.AppsDir, "*", store.Default.OverlaysDir, "*", "config.json"),
+		repofs.Join(store.Default.AppsDir, "*", "*", "config.json"),
+		repofs.Join(store.Default.AppsDir, "*", "*", "config_dir.json"),
+	}
+
+	res := []string{}
+	for _, pattern := range patterns {
+		matches, err := billyUtils.Glob(repofs, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range matches {
+			conf := &application.Config{}
+			if err = repofs.ReadJson(file, conf); err != nil {
+				return nil, fmt.Errorf("failed to read app config '%s': %w", file, err)
+			}
+
+			if conf.DestServer == server {
+				res = append(res, file)
+			}
+		}
+	}
+
+	sort.Strings(res)
+	return res, nil
+}
+
+// moveApps changes the destination of the apps in configFiles to target, and copies the
+// namespace manifests of the apps to the cluster resources of target
+func moveApps(repofs fs.FS, configFiles []string, target *application.ClusterResConfig) error {
+	clusters, err := application.ListClusters(repofs)
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	// server => cluster name
+	clusterNames := map[string]string{}
+	for _, c := range clusters {
+		clusterNames[c.Server] = c.Name
+	}
+
+	clusterResPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir)
+	for _, file := range configFiles {
+		conf := map[string]interface{}{}
+		if err = repofs.ReadJson(file, &conf); err != nil {
+			return fmt.Errorf("failed to read app config '%s': %w", file, err)
+		}
+
+		sourceServer, _ := conf["destServer"].(string)
+		conf["destServer"] = target.Server
+		if err = repofs.WriteJson(file, conf); err != nil {
+			return fmt.Errorf("failed to write app config '%s': %w", file, err)
+		}
+
+		sourceName, ok := clusterNames[sourceServer]
+		namespace, _ := conf["destNamespace"].(string)
+		if !ok || sourceName == target.Name || namespace == "" {
+			continue
+		}
+
+		nsFile := repofs.Join(clusterResPath, sourceName, namespace+"-ns.yaml")
+		targetFile := repofs.Join(clusterResPath, target.Name, namespace+"-ns.yaml")
+		if !repofs.ExistsOrDie(nsFile) || repofs.ExistsOrDie(targetFile) {
+			continue
+		}
+
+		data, err := repofs.ReadFile(nsFile)
+		if err != nil {
+			return err
+		}
+
+		if err = billyUtils.WriteFile(repofs, targetFile, data, 0666); err != nil {
+			return fmt.Errorf("failed to write namespace '%s': %w", targetFile, err)
+		}
+	}
+
+	return nil
+}
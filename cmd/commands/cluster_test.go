@@ -0,0 +1,347 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/application"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/argocd"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	gitmocks "github.com/argoproj-labs/argocd-autopilot/pkg/git/mocks"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	kubemocks "github.com/argoproj-labs/argocd-autopilot/pkg/kube/mocks"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	restclient "k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+)
+
+func clusterTestRepo(t *testing.T) fs.FS {
+	repofs := fs.Create(memfs.New())
+	assert.NoError(t, repofs.WriteJson("bootstrap/cluster-resources/in-cluster.json", &application.ClusterResConfig{Name: "in-cluster", Server: "https://kubernetes.default.svc"}))
+	assert.NoError(t, repofs.WriteJson("bootstrap/cluster-resources/prod.json", &application.ClusterResConfig{Name: "prod", Server: "https://prod.example.com"}))
+	assert.NoError(t, repofs.WriteJson("apps/app1/overlays/project/config.json", &application.Config{AppName: "app1", DestNamespace: "app1", DestServer: "https://prod.example.com"}))
+	assert.NoError(t, repofs.WriteJson("apps/app2/project/config_dir.json", map[string]string{"appName": "app2", "destServer": "https://prod.example.com", "exclude": "*.txt"}))
+	assert.NoError(t, repofs.WriteJson("apps/app3/overlays/project/config.json", &application.Config{AppName: "app3", DestServer: "https://kubernetes.default.svc"}))
+	assert.NoError(t, billyUtils.WriteFile(repofs, "bootstrap/cluster-resources/prod/app1-ns.yaml", []byte("kind: Namespace"), 0666))
+	assert.NoError(t, billyUtils.WriteFile(repofs, "bootstrap/cluster-resources/in-cluster/prod-cluster.yaml", []byte("kind: Secret"), 0666))
+	return repofs
+}
+
+func TestRunClusterAdd(t *testing.T) {
+	tests := map[string]struct {
+		opts     *ClusterAddOptions
+		wantErr  string
+		beforeFn func(*gitmocks.MockRepository, *kubemocks.MockFactory)
+		assertFn func(t *testing.T, repofs fs.FS)
+	}{
+		"should commit the cluster config, readme and secret": {
+			opts: &ClusterAddOptions{
+				KubeContext: "staging-ctx",
+				ClusterName: "staging",
+				Creds:       &RepoCredsOptions{Mode: repoCredsModePlain},
+			},
+			beforeFn: func(r *gitmocks.MockRepository, _ *kubemocks.MockFactory) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Added cluster 'staging'"}).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				conf := &application.ClusterResConfig{}
+				assert.NoError(t, repofs.ReadJson("bootstrap/cluster-resources/staging.json", conf))
+				assert.Equal(t, "https://staging.example.com", conf.Server)
+				assert.True(t, repofs.ExistsOrDie("bootstrap/cluster-resources/staging/README.md"))
+
+				secret := &v1.Secret{}
+				data, err := repofs.ReadFile("bootstrap/cluster-resources/in-cluster/staging-cluster.yaml")
+				assert.NoError(t, err)
+				assert.NoError(t, yaml.Unmarshal(data, secret))
+				assert.Equal(t, "cluster-staging", secret.Name)
+				assert.Equal(t, "argocd", secret.Namespace)
+				assert.Equal(t, "cluster", secret.Labels["argocd.argoproj.io/secret-type"])
+				assert.Equal(t, "https://staging.example.com", secret.StringData["server"])
+
+				config := &argocdv1alpha1.ClusterConfig{}
+				assert.NoError(t, yaml.Unmarshal([]byte(secret.StringData["config"]), config))
+				assert.Equal(t, "sa-token", config.BearerToken)
+				assert.Equal(t, []byte("ca"), config.CAData)
+			},
+		},
+		"should use the --server flag over the context server": {
+			opts: &ClusterAddOptions{
+				KubeContext: "staging",
+				Server:      "https://internal.staging",
+				Creds:       &RepoCredsOptions{Mode: repoCredsModePlain},
+			},
+			beforeFn: func(r *gitmocks.MockRepository, _ *kubemocks.MockFactory) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				conf := &application.ClusterResConfig{}
+				assert.NoError(t, repofs.ReadJson("bootstrap/cluster-resources/staging.json", conf))
+				assert.Equal(t, "https://internal.staging", conf.Server)
+			},
+		},
+		"should set the labels on the cluster config and secret": {
+			opts: &ClusterAddOptions{
+				KubeContext: "staging",
+				Labels:      map[string]string{"env": "staging"},
+				Creds:       &RepoCredsOptions{Mode: repoCredsModePlain},
+			},
+			beforeFn: func(r *gitmocks.MockRepository, _ *kubemocks.MockFactory) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				conf := &application.ClusterResConfig{}
+				assert.NoError(t, repofs.ReadJson("bootstrap/cluster-resources/staging.json", conf))
+				assert.Equal(t, map[string]string{"env": "staging"}, conf.Labels)
+
+				secret := &v1.Secret{}
+				data, err := repofs.ReadFile("bootstrap/cluster-resources/in-cluster/staging-cluster.yaml")
+				assert.NoError(t, err)
+				assert.NoError(t, yaml.Unmarshal(data, secret))
+				assert.Equal(t, "staging", secret.Labels["env"])
+			},
+		},
+		"should apply the secret to the cluster in cluster output": {
+			opts: &ClusterAddOptions{
+				KubeContext: "staging",
+				Output:      clusterOutputCluster,
+				Creds:       &RepoCredsOptions{Mode: repoCredsModeSealedSecret, SealingCert: "cert.pem"},
+			},
+			beforeFn: func(r *gitmocks.MockRepository, f *kubemocks.MockFactory) {
+				f.EXPECT().Apply(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, manifests []byte) error {
+						secret := &v1.Secret{}
+						assert.NoError(t, yaml.Unmarshal(manifests, secret))
+						assert.Equal(t, "Secret", secret.Kind)
+						assert.Equal(t, "cluster-staging", secret.Name)
+						return nil
+					})
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.True(t, repofs.ExistsOrDie("bootstrap/cluster-resources/staging.json"))
+				assert.False(t, repofs.ExistsOrDie("bootstrap/cluster-resources/in-cluster/staging-cluster.yaml"))
+			},
+		},
+		"should only print the secret in stdout output": {
+			opts: &ClusterAddOptions{
+				KubeContext: "staging",
+				Output:      clusterOutputStdout,
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.False(t, repofs.ExistsOrDie("bootstrap/cluster-resources/staging.json"))
+			},
+		},
+		"should fail if the cluster already exists": {
+			opts: &ClusterAddOptions{
+				KubeContext: "other",
+				Server:      "https://prod.example.com",
+				Creds:       &RepoCredsOptions{Mode: repoCredsModePlain},
+			},
+			wantErr: "cluster 'prod' (https://prod.example.com) already exists",
+		},
+		"should fail on in-cluster": {
+			opts: &ClusterAddOptions{
+				KubeContext: "in-cluster",
+				Creds:       &RepoCredsOptions{Mode: repoCredsModePlain},
+			},
+			wantErr: "cluster 'in-cluster' is where argo-cd is installed, and is always configured",
+		},
+		"should fail on unknown output": {
+			opts: &ClusterAddOptions{
+				KubeContext: "staging",
+				Output:      "file",
+			},
+			wantErr: "unknown output: file",
+		},
+		"should fail without a creds mode in git output": {
+			opts: &ClusterAddOptions{
+				KubeContext: "staging",
+			},
+			wantErr: "--creds-mode is required with git output, use --creds-mode plain to commit the cluster credentials as a plain secret",
+		},
+		"should fail without a sealing cert in sealed-secret mode": {
+			opts: &ClusterAddOptions{
+				KubeContext: "staging",
+				Creds:       &RepoCredsOptions{Mode: repoCredsModeSealedSecret},
+			},
+			wantErr: "--sealed-secrets-cert is required in sealed-secret mode",
+		},
+	}
+	origPrepareRepo, origGetInstallationNamespace := prepareRepo, getInstallationNamespace
+	origGetContextRESTConfig, origGetContextKubeFactory, origInstallClusterManager := getContextRESTConfig, getContextKubeFactory, installClusterManager
+	defer func() {
+		prepareRepo = origPrepareRepo
+		getInstallationNamespace = origGetInstallationNamespace
+		getContextRESTConfig = origGetContextRESTConfig
+		getContextKubeFactory = origGetContextKubeFactory
+		installClusterManager = origInstallClusterManager
+	}()
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := clusterTestRepo(t)
+			ctrl := gomock.NewController(t)
+			r := gitmocks.NewMockRepository(ctrl)
+			f := kubemocks.NewMockFactory(ctrl)
+			if tt.beforeFn != nil {
+				tt.beforeFn(r, f)
+			}
+
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				return r, repofs, nil
+			}
+			getInstallationNamespace = func(_ fs.FS) (string, error) {
+				return "argocd", nil
+			}
+			getContextRESTConfig = func(_ string) (*restclient.Config, error) {
+				return &restclient.Config{
+					Host:            "https://staging.example.com",
+					TLSClientConfig: restclient.TLSClientConfig{CAData: []byte("ca")},
+				}, nil
+			}
+			getContextKubeFactory = func(_ string) kube.Factory {
+				return nil
+			}
+			installClusterManager = func(_ context.Context, _ kube.Factory, opts *argocd.ClusterManagerOptions) (string, error) {
+				assert.Equal(t, "kube-system", opts.Namespace)
+				assert.Equal(t, "argocd-manager", opts.ServiceAccount)
+				return "sa-token", nil
+			}
+
+			tt.opts.CloneOpts = &git.CloneOptions{}
+			tt.opts.KubeFactory = f
+			if tt.opts.Creds == nil {
+				tt.opts.Creds = &RepoCredsOptions{}
+			}
+
+			err := RunClusterAdd(context.Background(), tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			tt.assertFn(t, repofs)
+		})
+	}
+}
+
+func TestRunClusterList(t *testing.T) {
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+	prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+		return nil, clusterTestRepo(t), nil
+	}
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, RunClusterList(context.Background(), &ClusterListOptions{
+		CloneOpts: &git.CloneOptions{},
+		Out:       out,
+	}))
+	assert.Contains(t, out.String(), "NAME        SERVER                          APPS  \n")
+	assert.Contains(t, out.String(), "in-cluster  https://kubernetes.default.svc  1     \n")
+	assert.Contains(t, out.String(), "prod        https://prod.example.com        2     \n")
+}
+
+func TestRunClusterRemove(t *testing.T) {
+	tests := map[string]struct {
+		opts     *ClusterRemoveOptions
+		wantErr  string
+		beforeFn func(*gitmocks.MockRepository)
+		assertFn func(t *testing.T, repofs fs.FS)
+	}{
+		"should refuse while apps target the cluster": {
+			opts: &ClusterRemoveOptions{
+				ClusterName: "prod",
+			},
+			wantErr: "cluster 'prod' is the destination of 2 app(s): apps/app1/overlays/project/config.json, apps/app2/project/config_dir.json. " +
+				"Use --move-apps-to to move them to another cluster, or delete them first",
+		},
+		"should move the apps and remove the cluster": {
+			opts: &ClusterRemoveOptions{
+				ClusterName: "prod",
+				MoveAppsTo:  "in-cluster",
+			},
+			beforeFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Removed cluster 'prod'"}).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.False(t, repofs.ExistsOrDie("bootstrap/cluster-resources/prod.json"))
+				assert.False(t, repofs.ExistsOrDie("bootstrap/cluster-resources/prod"))
+				assert.False(t, repofs.ExistsOrDie("bootstrap/cluster-resources/in-cluster/prod-cluster.yaml"))
+				assert.True(t, repofs.ExistsOrDie("bootstrap/cluster-resources/in-cluster/app1-ns.yaml"))
+
+				conf := &application.Config{}
+				assert.NoError(t, repofs.ReadJson("apps/app1/overlays/project/config.json", conf))
+				assert.Equal(t, "https://kubernetes.default.svc", conf.DestServer)
+
+				dirConf := map[string]string{}
+				assert.NoError(t, repofs.ReadJson("apps/app2/project/config_dir.json", &dirConf))
+				assert.Equal(t, "https://kubernetes.default.svc", dirConf["destServer"])
+				assert.Equal(t, "*.txt", dirConf["exclude"])
+			},
+		},
+		"should fail if the cluster does not exist": {
+			opts: &ClusterRemoveOptions{
+				ClusterName: "staging",
+			},
+			wantErr: "cluster 'staging' not found",
+		},
+		"should fail if the target cluster does not exist": {
+			opts: &ClusterRemoveOptions{
+				ClusterName: "prod",
+				MoveAppsTo:  "staging",
+			},
+			wantErr: "cluster 'staging' not found",
+		},
+		"should fail on in-cluster": {
+			opts: &ClusterRemoveOptions{
+				ClusterName: "in-cluster",
+			},
+			wantErr: "cannot remove cluster 'in-cluster', it is where argo-cd is installed",
+		},
+		"should fail if persist fails": {
+			opts: &ClusterRemoveOptions{
+				ClusterName: "prod",
+				MoveAppsTo:  "in-cluster",
+			},
+			beforeFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("", errors.New("some error"))
+			},
+			wantErr: "failed to push to repo: some error",
+		},
+	}
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := clusterTestRepo(t)
+			r := gitmocks.NewMockRepository(gomock.NewController(t))
+			if tt.beforeFn != nil {
+				tt.beforeFn(r)
+			}
+
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				return r, repofs, nil
+			}
+
+			tt.opts.CloneOpts = &git.CloneOptions{}
+			err := RunClusterRemove(context.Background(), tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			tt.assertFn(t, repofs)
+		})
+	}
+}
@@ -17,10 +17,21 @@ import (
 	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
 
 	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/yaml"
 )
 
+// SyncPolicyOptions are the sync policy flags of projects and apps, nil fields were not set
+type SyncPolicyOptions struct {
+	AutoSync     *bool
+	SelfHeal     *bool
+	Prune        *bool
+	SyncOptions  []string
+	RetryLimit   *int64
+	RetryBackoff *string
+}
+
 // used for mocking
 var (
 	die  = util.Die
@@ -180,6 +191,11 @@ type createAppSetOptions struct {
 	appLabels                   map[string]string
 	appAnnotations              map[string]string
 	generators                  []argocdv1alpha1.ApplicationSetGenerator
+	// syncPolicy replaces the default automated sync policy of the apps, if not nil
+	syncPolicy *argocdv1alpha1.SyncPolicy
+	// goTemplate and templatePatch are set as is on the ApplicationSet
+	goTemplate    bool
+	templatePatch string
 }
 
 func createAppSet(o *createAppSetOptions) ([]byte, error) {
@@ -199,6 +215,17 @@ func createAppSet(o *createAppSetOptions) ([]byte, error) {
 		}
 	}
 
+	syncPolicy := o.syncPolicy
+	if syncPolicy == nil {
+		syncPolicy = &argocdv1alpha1.SyncPolicy{
+			Automated: &argocdv1alpha1.SyncPolicyAutomated{
+				SelfHeal:   true,
+				Prune:      o.prune,
+				AllowEmpty: true,
+			},
+		}
+	}
+
 	appSet := &argocdv1alpha1.ApplicationSet{
 		TypeMeta: metav1.TypeMeta{
 			// do not use argocdv1alpha1.ApplicationSetSchemaGroupVersionKind.Kind because it is "Applicationset" - noticed the lowercase "s"
@@ -213,6 +240,7 @@ func createAppSet(o *createAppSetOptions) ([]byte, error) {
 			},
 		},
 		Spec: argocdv1alpha1.ApplicationSetSpec{
+			GoTemplate: o.goTemplate,
 			Generators: o.generators,
 			Template: argocdv1alpha1.ApplicationSetTemplate{
 				ApplicationSetTemplateMeta: argocdv1alpha1.ApplicationSetTemplateMeta{
@@ -232,13 +260,7 @@ func createAppSet(o *createAppSetOptions) ([]byte, error) {
 						Server:    o.destServer,
 						Namespace: o.destNamespace,
 					},
-					SyncPolicy: &argocdv1alpha1.SyncPolicy{
-						Automated: &argocdv1alpha1.SyncPolicyAutomated{
-							SelfHeal:   true,
-							Prune:      o.prune,
-							AllowEmpty: true,
-						},
-					},
+					SyncPolicy: syncPolicy,
 					IgnoreDifferences: []argocdv1alpha1.ResourceIgnoreDifferences{
 						{
 							Group: "argoproj.io",
@@ -255,10 +277,145 @@ func createAppSet(o *createAppSetOptions) ([]byte, error) {
 			},
 		},
 	}
+	if o.templatePatch != "" {
+		appSet.Spec.TemplatePatch = &o.templatePatch
+	}
 
 	return yaml.Marshal(appSet)
 }
 
+// addSyncPolicyFlags adds the sync policy flags to cmd. Use SyncPolicyOptions.Parse to get
+// the flags that were set
+func addSyncPolicyFlags(cmd *cobra.Command) *SyncPolicyOptions {
+	opts := &SyncPolicyOptions{
+		AutoSync:     new(bool),
+		SelfHeal:     new(bool),
+		Prune:        new(bool),
+		RetryLimit:   new(int64),
+		RetryBackoff: new(string),
+	}
+	cmd.Flags().BoolVar(opts.AutoSync, "auto-sync", true, "If true, argo-cd will automatically sync the applications")
+	cmd.Flags().BoolVar(opts.SelfHeal, "self-heal", true, "If true, automated syncs will revert changes made to the live resources")
+	cmd.Flags().BoolVar(opts.Prune, "prune", true, "If true, automated syncs will delete resources that are no longer in git")
+	cmd.Flags().StringArrayVar(&opts.SyncOptions, "sync-option", nil, "A sync option of the applications, can be repeated (e.g. ServerSideApply=true)")
+	cmd.Flags().Int64Var(opts.RetryLimit, "retry-limit", 0, "The number of times a failed sync is retried (default: no retries)")
+	cmd.Flags().StringVar(opts.RetryBackoff, "retry-backoff", "", "The time to wait before the first retry of a failed sync, doubled after each retry (e.g. 5s)")
+
+	return opts
+}
+
+// Parse keeps only the options of the flags that were set on cmd
+func (o *SyncPolicyOptions) Parse(cmd *cobra.Command) *SyncPolicyOptions {
+	res := &SyncPolicyOptions{}
+	changed := false
+	if cmd.Flags().Changed("auto-sync") {
+		res.AutoSync, changed = o.AutoSync, true
+	}
+
+	if cmd.Flags().Changed("self-heal") {
+		res.SelfHeal, changed = o.SelfHeal, true
+	}
+
+	if cmd.Flags().Changed("prune") {
+		res.Prune, changed = o.Prune, true
+	}
+
+	if cmd.Flags().Changed("sync-option") {
+		res.SyncOptions, changed = o.SyncOptions, true
+	}
+
+	if cmd.Flags().Changed("retry-limit") {
+		res.RetryLimit, changed = o.RetryLimit, true
+	}
+
+	if cmd.Flags().Changed("retry-backoff") {
+		res.RetryBackoff, changed = o.RetryBackoff, true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return res
+}
+
+// Apply returns a copy of policy, with the options that are set. A nil policy is the default
+// automated sync policy
+func (o *SyncPolicyOptions) Apply(policy *argocdv1alpha1.SyncPolicy) (*argocdv1alpha1.SyncPolicy, error) {
+	if policy == nil {
+		policy = defaultSyncPolicy()
+	}
+
+	res := policy.DeepCopy()
+	if o == nil {
+		return res, nil
+	}
+
+	if o.AutoSync != nil {
+		if !*o.AutoSync {
+			res.Automated = nil
+		} else if res.Automated == nil {
+			res.Automated = &argocdv1alpha1.SyncPolicyAutomated{AllowEmpty: true}
+		}
+	}
+
+	if o.SelfHeal != nil || o.Prune != nil {
+		if res.Automated == nil {
+			return nil, fmt.Errorf("--self-heal and --prune require automated sync")
+		}
+
+		if o.SelfHeal != nil {
+			res.Automated.SelfHeal = *o.SelfHeal
+		}
+
+		if o.Prune != nil {
+			res.Automated.Prune = *o.Prune
+		}
+	}
+
+	if o.SyncOptions != nil {
+		res.SyncOptions = o.SyncOptions
+	}
+
+	if o.RetryLimit != nil {
+		if *o.RetryLimit < 0 {
+			return nil, fmt.Errorf("invalid retry limit %d", *o.RetryLimit)
+		}
+
+		if *o.RetryLimit == 0 {
+			res.Retry = nil
+		} else if res.Retry == nil {
+			res.Retry = &argocdv1alpha1.RetryStrategy{Limit: *o.RetryLimit}
+		} else {
+			res.Retry.Limit = *o.RetryLimit
+		}
+	}
+
+	if o.RetryBackoff != nil && *o.RetryBackoff != "" {
+		if res.Retry == nil {
+			return nil, fmt.Errorf("--retry-backoff requires --retry-limit")
+		}
+
+		if _, err := time.ParseDuration(*o.RetryBackoff); err != nil {
+			return nil, fmt.Errorf("invalid retry backoff '%s': %w", *o.RetryBackoff, err)
+		}
+
+		res.Retry.Backoff = &argocdv1alpha1.Backoff{Duration: *o.RetryBackoff}
+	}
+
+	return res, nil
+}
+
+func defaultSyncPolicy() *argocdv1alpha1.SyncPolicy {
+	return &argocdv1alpha1.SyncPolicy{
+		Automated: &argocdv1alpha1.SyncPolicyAutomated{
+			SelfHeal:   true,
+			Prune:      true,
+			AllowEmpty: true,
+		},
+	}
+}
+
 var getInstallationNamespace = func(repofs fs.FS) (string, error) {
 	path := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml")
 	a := &argocdv1alpha1.Application{}
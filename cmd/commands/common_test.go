@@ -10,6 +10,7 @@ import (
 	gitmocks "github.com/argoproj-labs/argocd-autopilot/pkg/git/mocks"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
 
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	"github.com/go-git/go-billy/v5/memfs"
 	billyUtils "github.com/go-git/go-billy/v5/util"
 	"github.com/golang/mock/gomock"
@@ -90,3 +91,79 @@ func Test_prepareRepo(t *testing.T) {
 		})
 	}
 }
+
+func TestSyncPolicyOptions_Apply(t *testing.T) {
+	yes, no := true, false
+	limit, noLimit := int64(3), int64(0)
+	backoff := "5s"
+	tests := map[string]struct {
+		opts    *SyncPolicyOptions
+		policy  *argocdv1alpha1.SyncPolicy
+		want    *argocdv1alpha1.SyncPolicy
+		wantErr string
+	}{
+		"should return the default policy": {
+			want: defaultSyncPolicy(),
+		},
+		"should disable automated sync": {
+			opts: &SyncPolicyOptions{AutoSync: &no},
+			want: &argocdv1alpha1.SyncPolicy{},
+		},
+		"should enable automated sync without self heal and prune": {
+			opts:   &SyncPolicyOptions{AutoSync: &yes},
+			policy: &argocdv1alpha1.SyncPolicy{},
+			want: &argocdv1alpha1.SyncPolicy{
+				Automated: &argocdv1alpha1.SyncPolicyAutomated{AllowEmpty: true},
+			},
+		},
+		"should set the sync options and retry": {
+			opts: &SyncPolicyOptions{
+				SelfHeal:     &no,
+				SyncOptions:  []string{"ServerSideApply=true", "CreateNamespace=true"},
+				RetryLimit:   &limit,
+				RetryBackoff: &backoff,
+			},
+			want: &argocdv1alpha1.SyncPolicy{
+				Automated:   &argocdv1alpha1.SyncPolicyAutomated{Prune: true, AllowEmpty: true},
+				SyncOptions: argocdv1alpha1.SyncOptions{"ServerSideApply=true", "CreateNamespace=true"},
+				Retry: &argocdv1alpha1.RetryStrategy{
+					Limit:   3,
+					Backoff: &argocdv1alpha1.Backoff{Duration: "5s"},
+				},
+			},
+		},
+		"should remove the retry": {
+			opts: &SyncPolicyOptions{RetryLimit: &noLimit},
+			policy: &argocdv1alpha1.SyncPolicy{
+				Retry: &argocdv1alpha1.RetryStrategy{Limit: 3},
+			},
+			want: &argocdv1alpha1.SyncPolicy{},
+		},
+		"should fail to set prune without automated sync": {
+			opts:    &SyncPolicyOptions{AutoSync: &no, Prune: &yes},
+			wantErr: "--self-heal and --prune require automated sync",
+		},
+		"should fail to set the backoff without retry": {
+			opts:    &SyncPolicyOptions{RetryBackoff: &backoff},
+			wantErr: "--retry-backoff requires --retry-limit",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			var orig *argocdv1alpha1.SyncPolicy
+			if tt.policy != nil {
+				orig = tt.policy.DeepCopy()
+			}
+
+			got, err := tt.opts.Apply(tt.policy)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, orig, tt.policy)
+		})
+	}
+}
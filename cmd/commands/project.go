@@ -7,14 +7,15 @@ import (
 	"io"
 	"os"
 	"path"
+	"regexp"
 	"strings"
 	"text/tabwriter"
 
 	"github.com/argoproj-labs/argocd-autopilot/pkg/application"
-	"github.com/argoproj-labs/argocd-autopilot/pkg/argocd"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
 	fsutils "github.com/argoproj-labs/argocd-autopilot/pkg/fs/utils"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
@@ -27,6 +28,16 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
+// appSyncPolicyPatch replaces the sync policy of the project with the syncPolicy of the app
+// config, if it has one
+const appSyncPolicyPatch = `{{- if hasKey . "syncPolicy" }}
+spec:
+  syncPolicy: {{ merge (dict "$patch" "replace") .syncPolicy | toJson }}
+{{- end }}
+`
+
+var placeholderRegex = regexp.MustCompile(`{{\s*([a-zA-Z_][\w.]*)\s*}}`)
+
 type (
 	ProjectCreateOptions struct {
 		CloneOpts       *git.CloneOptions
@@ -34,9 +45,50 @@ type (
 		DestKubeServer  string
 		DestKubeContext string
 		DryRun          bool
-		AddCmd          argocd.AddClusterCmd
+		KubeFactory     kube.Factory
 		Labels          map[string]string
 		Annotations     map[string]string
+		// SourceRepos, Destinations and the resource lists restrict what the project
+		// applications can deploy. Empty lists allow everything (except for the deny lists)
+		SourceRepos              []string
+		Destinations             []string
+		AllowClusterResources    []string
+		DenyClusterResources     []string
+		AllowNamespacedResources []string
+		DenyNamespacedResources  []string
+		// SyncPolicy changes the default sync policy of the project applications
+		SyncPolicy *SyncPolicyOptions
+		// ClusterSelector deploys every app to all of the clusters with matching labels
+		ClusterSelector map[string]string
+	}
+
+	ProjectUpdateOptions struct {
+		CloneOpts       *git.CloneOptions
+		ProjectName     string
+		DestKubeServer  string
+		DestKubeContext string
+		// Labels and Annotations replace the current ones, if not nil
+		Labels      map[string]string
+		Annotations map[string]string
+		UpdateApps  bool
+		DryRun      bool
+	}
+
+	ProjectRenameOptions struct {
+		CloneOpts      *git.CloneOptions
+		ProjectName    string
+		NewProjectName string
+		// KeepResources keeps the live resources of the project apps, while argo-cd replaces the
+		// old applications with the new ones
+		KeepResources bool
+	}
+
+	ProjectCloneOptions struct {
+		CloneOpts       *git.CloneOptions
+		ProjectName     string
+		NewProjectName  string
+		DestKubeServer  string
+		DestKubeContext string
 	}
 
 	ProjectDeleteOptions struct {
@@ -59,6 +111,20 @@ type (
 		InstallationPath   string
 		Labels             map[string]string
 		Annotations        map[string]string
+		// the AppProject restrictions, a nil SourceRepos, Destinations or whitelist allows everything
+		SourceRepos                []string
+		Destinations               []argocdv1alpha1.ApplicationDestination
+		ClusterResourceWhitelist   []metav1.GroupKind
+		ClusterResourceBlacklist   []metav1.GroupKind
+		NamespaceResourceWhitelist []metav1.GroupKind
+		NamespaceResourceBlacklist []metav1.GroupKind
+		Roles                      []argocdv1alpha1.ProjectRole
+		SyncWindows                argocdv1alpha1.SyncWindows
+		// SyncPolicy is the default sync policy of the project applications, a nil SyncPolicy
+		// is the default automated sync policy
+		SyncPolicy *argocdv1alpha1.SyncPolicy
+		// ClusterSelector deploys every app to all of the clusters with matching labels
+		ClusterSelector map[string]string
 	}
 )
 
@@ -74,21 +140,28 @@ func NewProjectCommand() *cobra.Command {
 	}
 
 	cmd.AddCommand(NewProjectCreateCommand())
+	cmd.AddCommand(NewProjectUpdateCommand())
+	cmd.AddCommand(NewProjectRenameCommand())
+	cmd.AddCommand(NewProjectCloneCommand())
 	cmd.AddCommand(NewProjectListCommand())
 	cmd.AddCommand(NewProjectDeleteCommand())
+	cmd.AddCommand(NewProjectRoleCommand())
+	cmd.AddCommand(NewProjectSyncWindowCommand())
 
 	return cmd
 }
 
 func NewProjectCreateCommand() *cobra.Command {
 	var (
-		kubeServer  string
-		kubeContext string
-		dryRun      bool
-		addCmd      argocd.AddClusterCmd
-		labels      map[string]string
-		annotations map[string]string
-		cloneOpts   *git.CloneOptions
+		kubeServer   string
+		kubeContext  string
+		dryRun       bool
+		f            kube.Factory
+		labels       map[string]string
+		annotations  map[string]string
+		cloneOpts    *git.CloneOptions
+		restrictOpts ProjectCreateOptions
+		syncOpts     *SyncPolicyOptions
 	)
 
 	cmd := &cobra.Command{
@@ -108,6 +181,14 @@ func NewProjectCreateCommand() *cobra.Command {
 # Create a new project
 
 	<BIN> project create <PROJECT_NAME>
+
+# Create a project that can only deploy namespaced resources from the apps repo, to the "team-a-*" namespaces
+
+	<BIN> project create <PROJECT_NAME> --source-repos <APPS_REPO_URL> --allow-destination https://kubernetes.default.svc,team-a-* --allow-cluster-resource /Namespace
+
+# Create a project that deploys every app to all of the clusters that were added with the "env=prod" label
+
+	<BIN> project create <PROJECT_NAME> --cluster-selector env=prod
 `),
 		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -122,9 +203,18 @@ func NewProjectCreateCommand() *cobra.Command {
 				DestKubeServer:  kubeServer,
 				DestKubeContext: kubeContext,
 				DryRun:          dryRun,
-				AddCmd:          addCmd,
+				KubeFactory:     f,
 				Labels:          labels,
 				Annotations:     annotations,
+
+				SourceRepos:              restrictOpts.SourceRepos,
+				Destinations:             restrictOpts.Destinations,
+				AllowClusterResources:    restrictOpts.AllowClusterResources,
+				DenyClusterResources:     restrictOpts.DenyClusterResources,
+				AllowNamespacedResources: restrictOpts.AllowNamespacedResources,
+				DenyNamespacedResources:  restrictOpts.DenyNamespacedResources,
+				SyncPolicy:               syncOpts.Parse(cmd),
+				ClusterSelector:          restrictOpts.ClusterSelector,
 			})
 		},
 	}
@@ -134,13 +224,20 @@ func NewProjectCreateCommand() *cobra.Command {
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "If true, print manifests instead of applying them to the cluster (nothing will be commited to git)")
 	cmd.Flags().StringToStringVar(&labels, "labels", nil, "Optional labels that will be set on the Application resource. (e.g. \"app.kubernetes.io/managed-by={{ placeholder }}\"")
 	cmd.Flags().StringToStringVar(&annotations, "annotations", nil, "Optional annotations that will be set on the Application resource. (e.g. \"argocd.argoproj.io/sync-wave={{ placeholder }}\"")
+	cmd.Flags().StringSliceVar(&restrictOpts.SourceRepos, "source-repos", nil, "The source repositories the project applications can use (default: any repository)")
+	cmd.Flags().StringArrayVar(&restrictOpts.Destinations, "allow-destination", nil, "A <server>,<namespace> pair the project applications can be deployed to, can be repeated (default: any server and namespace)")
+	cmd.Flags().StringSliceVar(&restrictOpts.AllowClusterResources, "allow-cluster-resource", nil, "The cluster scoped resources the project applications can create, as <group>/<kind> (default: all resources)")
+	cmd.Flags().StringSliceVar(&restrictOpts.DenyClusterResources, "deny-cluster-resource", nil, "The cluster scoped resources the project applications can not create, as <group>/<kind>")
+	cmd.Flags().StringSliceVar(&restrictOpts.AllowNamespacedResources, "allow-namespaced-resource", nil, "The namespaced resources the project applications can create, as <group>/<kind> (default: all resources)")
+	cmd.Flags().StringSliceVar(&restrictOpts.DenyNamespacedResources, "deny-namespaced-resource", nil, "The namespaced resources the project applications can not create, as <group>/<kind>")
+	cmd.Flags().StringToStringVar(&restrictOpts.ClusterSelector, "cluster-selector", nil, "If set, every app of the project is deployed to all of the clusters with these labels, instead of its destination server (e.g. \"env=prod\")")
+	syncOpts = addSyncPolicyFlags(cmd)
 
 	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
 		FS:            memfs.New(),
 		CloneForWrite: true,
 	})
-	addCmd, err := argocd.AddClusterAddFlags(cmd)
-	die(err)
+	f = kube.AddFlags(cmd.Flags())
 
 	return cmd
 }
@@ -168,6 +265,16 @@ func RunProjectCreate(ctx context.Context, opts *ProjectCreateOptions) error {
 
 	log.G(ctx).Debug("repository is ok")
 
+	generateOpts, err := parseProjectRestrictions(opts)
+	if err != nil {
+		return err
+	}
+
+	generateOpts.SyncPolicy, err = opts.SyncPolicy.Apply(nil)
+	if err != nil {
+		return err
+	}
+
 	if opts.DestKubeServer == "" {
 		opts.DestKubeServer = store.Default.DestServer
 		if opts.DestKubeContext != "" {
@@ -178,17 +285,17 @@ func RunProjectCreate(ctx context.Context, opts *ProjectCreateOptions) error {
 		}
 	}
 
-	projectYAML, appsetYAML, clusterResReadme, clusterResConf, err := generateProjectManifests(&GenerateProjectOptions{
-		Name:               opts.ProjectName,
-		Namespace:          installationNamespace,
-		RepoURL:            opts.CloneOpts.URL(),
-		Revision:           opts.CloneOpts.Revision(),
-		InstallationPath:   opts.CloneOpts.Path(),
-		DefaultDestServer:  opts.DestKubeServer,
-		DefaultDestContext: opts.DestKubeContext,
-		Labels:             opts.Labels,
-		Annotations:        opts.Annotations,
-	})
+	generateOpts.Name = opts.ProjectName
+	generateOpts.Namespace = installationNamespace
+	generateOpts.RepoURL = opts.CloneOpts.URL()
+	generateOpts.Revision = opts.CloneOpts.Revision()
+	generateOpts.InstallationPath = opts.CloneOpts.Path()
+	generateOpts.DefaultDestServer = opts.DestKubeServer
+	generateOpts.DefaultDestContext = opts.DestKubeContext
+	generateOpts.Labels = opts.Labels
+	generateOpts.Annotations = opts.Annotations
+	generateOpts.ClusterSelector = opts.ClusterSelector
+	projectYAML, appsetYAML, clusterResReadme, clusterResConf, err := generateProjectManifests(generateOpts)
 	if err != nil {
 		return fmt.Errorf("failed to generate project resources: %w", err)
 	}
@@ -202,7 +309,7 @@ func RunProjectCreate(ctx context.Context, opts *ProjectCreateOptions) error {
 
 	if opts.DestKubeContext != "" {
 		log.G(ctx).Infof("adding cluster: %s", opts.DestKubeContext)
-		if err = opts.AddCmd.Execute(ctx, opts.DestKubeContext); err != nil {
+		if err = addProjectCluster(ctx, opts, installationNamespace); err != nil {
 			return fmt.Errorf("failed to add new cluster credentials: %w", err)
 		}
 
@@ -241,6 +348,91 @@ func RunProjectCreate(ctx context.Context, opts *ProjectCreateOptions) error {
 	return nil
 }
 
+// addProjectCluster applies the argo-cd cluster secret of the project destination context to
+// the argo-cd cluster
+func addProjectCluster(ctx context.Context, opts *ProjectCreateOptions, namespace string) error {
+	rc, err := getContextRESTConfig(opts.DestKubeContext)
+	if err != nil {
+		return fmt.Errorf("failed to get kubernetes context '%s': %w", opts.DestKubeContext, err)
+	}
+
+	secret, err := generateClusterSecret(ctx, rc, &clusterSecretOptions{
+		kubeContext: opts.DestKubeContext,
+		clusterName: opts.DestKubeContext,
+		server:      opts.DestKubeServer,
+		namespace:   namespace,
+	})
+	if err != nil {
+		return err
+	}
+
+	secretYAML, err := yaml.Marshal(secret)
+	if err != nil {
+		return err
+	}
+
+	return opts.KubeFactory.Apply(ctx, secretYAML)
+}
+
+// parseProjectRestrictions returns the generate options with the AppProject restrictions
+// in opts
+func parseProjectRestrictions(opts *ProjectCreateOptions) (*GenerateProjectOptions, error) {
+	var err error
+	res := &GenerateProjectOptions{
+		SourceRepos: opts.SourceRepos,
+	}
+
+	for _, d := range opts.Destinations {
+		server, namespace, found := strings.Cut(d, ",")
+		if !found || server == "" || namespace == "" {
+			return nil, fmt.Errorf("invalid destination '%s', expected <server>,<namespace>", d)
+		}
+
+		res.Destinations = append(res.Destinations, argocdv1alpha1.ApplicationDestination{
+			Server:    server,
+			Namespace: namespace,
+		})
+	}
+
+	if res.ClusterResourceWhitelist, err = parseGroupKinds(opts.AllowClusterResources); err != nil {
+		return nil, err
+	}
+
+	if res.ClusterResourceBlacklist, err = parseGroupKinds(opts.DenyClusterResources); err != nil {
+		return nil, err
+	}
+
+	if res.NamespaceResourceWhitelist, err = parseGroupKinds(opts.AllowNamespacedResources); err != nil {
+		return nil, err
+	}
+
+	if res.NamespaceResourceBlacklist, err = parseGroupKinds(opts.DenyNamespacedResources); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// parseGroupKinds parses a list of <group>/<kind> resources. The group of core
+// resources can be omitted (e.g. "Namespace" or "/Namespace")
+func parseGroupKinds(resources []string) ([]metav1.GroupKind, error) {
+	var res []metav1.GroupKind
+	for _, r := range resources {
+		group, kind := "", r
+		if i := strings.LastIndex(r, "/"); i >= 0 {
+			group, kind = r[:i], r[i+1:]
+		}
+
+		if kind == "" {
+			return nil, fmt.Errorf("invalid resource '%s', expected <group>/<kind>", r)
+		}
+
+		res = append(res, metav1.GroupKind{Group: group, Kind: kind})
+	}
+
+	return res, nil
+}
+
 func generateProjectManifests(o *GenerateProjectOptions) (projectYAML, appSetYAML, clusterResReadme, clusterResConfig []byte, err error) {
 	project := &argocdv1alpha1.AppProject{
 		TypeMeta: metav1.TypeMeta{
@@ -271,70 +463,82 @@ func generateProjectManifests(o *GenerateProjectOptions) (projectYAML, appSetYAM
 					Kind:  "*",
 				},
 			},
+			ClusterResourceBlacklist: o.ClusterResourceBlacklist,
 			NamespaceResourceWhitelist: []metav1.GroupKind{
 				{
 					Group: "*",
 					Kind:  "*",
 				},
 			},
+			NamespaceResourceBlacklist: o.NamespaceResourceBlacklist,
+			Roles:                      o.Roles,
+			SyncWindows:                o.SyncWindows,
 		},
 	}
+	if len(o.SourceRepos) > 0 {
+		project.Spec.SourceRepos = o.SourceRepos
+	}
+
+	if len(o.Destinations) > 0 {
+		project.Spec.Destinations = o.Destinations
+	}
+
+	if len(o.ClusterResourceWhitelist) > 0 {
+		project.Spec.ClusterResourceWhitelist = o.ClusterResourceWhitelist
+	}
+
+	if len(o.NamespaceResourceWhitelist) > 0 {
+		project.Spec.NamespaceResourceWhitelist = o.NamespaceResourceWhitelist
+	}
+
 	if projectYAML, err = yaml.Marshal(project); err != nil {
 		err = fmt.Errorf("failed to marshal AppProject: %w", err)
 		return
 	}
 
+	syncPolicy := o.SyncPolicy
+	if syncPolicy == nil {
+		syncPolicy = defaultSyncPolicy()
+	}
+
+	appName := fmt.Sprintf("%s-{{ .userGivenName }}", o.Name)
+	destServer := "{{ .destServer }}"
+	if len(o.ClusterSelector) > 0 {
+		// every app is deployed to all of the selected clusters, instead of its destServer
+		appName = fmt.Sprintf("%s-{{ .userGivenName }}-{{ .nameNormalized }}", o.Name)
+		destServer = "{{ .server }}"
+	}
+
 	appSetYAML, err = createAppSet(&createAppSetOptions{
 		name:                        o.Name,
 		namespace:                   o.Namespace,
-		appName:                     fmt.Sprintf("%s-{{ userGivenName }}", o.Name),
+		appName:                     appName,
 		appNamespace:                o.Namespace,
 		appProject:                  o.Name,
-		repoURL:                     "{{ srcRepoURL }}",
-		srcPath:                     "{{ srcPath }}",
-		revision:                    "{{ srcTargetRevision }}",
-		destServer:                  "{{ destServer }}",
-		destNamespace:               "{{ destNamespace }}",
-		prune:                       true,
+		repoURL:                     "{{ .srcRepoURL }}",
+		srcPath:                     "{{ .srcPath }}",
+		revision:                    "{{ .srcTargetRevision }}",
+		destServer:                  destServer,
+		destNamespace:               "{{ .destNamespace }}",
+		syncPolicy:                  syncPolicy,
 		preserveResourcesOnDeletion: false,
 		appLabels:                   getDefaultAppLabels(o.Labels),
-		appAnnotations:              o.Annotations,
+		appAnnotations:              toGoTemplateMap(o.Annotations),
+		goTemplate:                  true,
+		templatePatch:               appSyncPolicyPatch,
 		generators: []argocdv1alpha1.ApplicationSetGenerator{
-			{
-				Git: &argocdv1alpha1.GitGenerator{
-					RepoURL:  o.RepoURL,
-					Revision: o.Revision,
-					Files: []argocdv1alpha1.GitFileGeneratorItem{
-						{
-							Path: path.Join(o.InstallationPath, store.Default.AppsDir, "**", o.Name, "config.json"),
-						},
-					},
-					RequeueAfterSeconds: &DefaultApplicationSetGeneratorInterval,
-				},
-			},
-			{
-				Git: &argocdv1alpha1.GitGenerator{
-					RepoURL:  o.RepoURL,
-					Revision: o.Revision,
-					Files: []argocdv1alpha1.GitFileGeneratorItem{
-						{
-							Path: path.Join(o.InstallationPath, store.Default.AppsDir, "**", o.Name, "config_dir.json"),
-						},
-					},
-					RequeueAfterSeconds: &DefaultApplicationSetGeneratorInterval,
-					Template: argocdv1alpha1.ApplicationSetTemplate{
-						Spec: argocdv1alpha1.ApplicationSpec{
-							Source: &argocdv1alpha1.ApplicationSource{
-								Directory: &argocdv1alpha1.ApplicationSourceDirectory{
-									Recurse: true,
-									Exclude: "{{ exclude }}",
-									Include: "{{ include }}",
-								},
-							},
+			getProjectGenerator(o, "config.json", argocdv1alpha1.ApplicationSetTemplate{}),
+			getProjectGenerator(o, "config_dir.json", argocdv1alpha1.ApplicationSetTemplate{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Source: &argocdv1alpha1.ApplicationSource{
+						Directory: &argocdv1alpha1.ApplicationSourceDirectory{
+							Recurse: true,
+							Exclude: "{{ .exclude }}",
+							Include: "{{ .include }}",
 						},
 					},
 				},
-			},
+			}),
 		},
 	})
 	if err != nil {
@@ -353,18 +557,646 @@ func generateProjectManifests(o *GenerateProjectOptions) (projectYAML, appSetYAM
 	return
 }
 
+// getProjectGenerator returns the git files generator of the project app configs. If the project
+// has a cluster selector, it is combined with a cluster generator of the selected clusters
+func getProjectGenerator(o *GenerateProjectOptions, configFile string, template argocdv1alpha1.ApplicationSetTemplate) argocdv1alpha1.ApplicationSetGenerator {
+	gitGenerator := &argocdv1alpha1.GitGenerator{
+		RepoURL:  o.RepoURL,
+		Revision: o.Revision,
+		Files: []argocdv1alpha1.GitFileGeneratorItem{
+			{
+				Path: path.Join(o.InstallationPath, store.Default.AppsDir, "**", o.Name, configFile),
+			},
+		},
+		RequeueAfterSeconds: &DefaultApplicationSetGeneratorInterval,
+	}
+	if len(o.ClusterSelector) == 0 {
+		gitGenerator.Template = template
+		return argocdv1alpha1.ApplicationSetGenerator{Git: gitGenerator}
+	}
+
+	return argocdv1alpha1.ApplicationSetGenerator{
+		Matrix: &argocdv1alpha1.MatrixGenerator{
+			Generators: []argocdv1alpha1.ApplicationSetNestedGenerator{
+				{Git: gitGenerator},
+				{
+					Clusters: &argocdv1alpha1.ClusterGenerator{
+						Selector: metav1.LabelSelector{MatchLabels: o.ClusterSelector},
+					},
+				},
+			},
+			Template: template,
+		},
+	}
+}
+
+// getProjectClusterSelector returns the cluster selector of the project ApplicationSet, or nil
+// if the project apps are deployed to their destServer
+func getProjectClusterSelector(appSet *argocdv1alpha1.ApplicationSet) map[string]string {
+	for _, g := range appSet.Spec.Generators {
+		if g.Matrix == nil {
+			continue
+		}
+
+		for _, nested := range g.Matrix.Generators {
+			if nested.Clusters != nil {
+				return nested.Clusters.Selector.MatchLabels
+			}
+		}
+	}
+
+	return nil
+}
+
 func getDefaultAppLabels(labels map[string]string) map[string]string {
 	res := map[string]string{
 		store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
-		store.Default.LabelKeyAppName:      "{{ appName }}",
+		store.Default.LabelKeyAppName:      "{{ .appName }}",
 	}
-	for k, v := range labels {
+	for k, v := range toGoTemplateMap(labels) {
 		res[k] = v
 	}
 
 	return res
 }
 
+// toGoTemplateMap replaces the "{{ placeholder }}" params in the values of m with the
+// "{{ .placeholder }}" params of the go template ApplicationSet
+func toGoTemplateMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = placeholderRegex.ReplaceAllString(v, "{{ .$1 }}")
+	}
+
+	return res
+}
+
+func NewProjectUpdateCommand() *cobra.Command {
+	var (
+		kubeServer  string
+		kubeContext string
+		labels      map[string]string
+		annotations map[string]string
+		updateApps  bool
+		dryRun      bool
+		cloneOpts   *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "update [PROJECT]",
+		Short: "Update the default destination, labels and annotations of a project",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Change the default destination of a project, and move all of its apps to it
+
+	<BIN> project update <PROJECT_NAME> --dest-kube-context <CONTEXT> --update-apps
+
+# Replace the labels of the project applications
+
+	<BIN> project update <PROJECT_NAME> --labels team=payments
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 1 {
+				log.G(ctx).Fatal("must enter project name")
+			}
+
+			opts := &ProjectUpdateOptions{
+				CloneOpts:       cloneOpts,
+				ProjectName:     args[0],
+				DestKubeServer:  kubeServer,
+				DestKubeContext: kubeContext,
+				UpdateApps:      updateApps,
+				DryRun:          dryRun,
+			}
+			if cmd.Flags().Changed("labels") {
+				opts.Labels = labels
+			}
+
+			if cmd.Flags().Changed("annotations") {
+				opts.Annotations = annotations
+			}
+
+			return RunProjectUpdate(ctx, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&kubeServer, "dest-server", "", "The new default destination kubernetes server for applications in this project")
+	cmd.Flags().StringVar(&kubeContext, "dest-kube-context", "", "The new default destination kubernetes context for applications in this project (will be ignored if --dest-server is supplied)")
+	cmd.Flags().StringToStringVar(&labels, "labels", nil, "Labels that will replace the current labels of the Application resources. (e.g. \"key1=value1,key2=value2\"")
+	cmd.Flags().StringToStringVar(&annotations, "annotations", nil, "Annotations that will replace the current annotations of the Application resources. (e.g. \"key1=value1,key2=value2\"")
+	cmd.Flags().BoolVar(&updateApps, "update-apps", false, "If true, will also change the destination of all of the project apps to the new default destination")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "If true, print the updated manifests instead of committing them to git")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	return cmd
+}
+
+func RunProjectUpdate(ctx context.Context, opts *ProjectUpdateOptions) error {
+	r, repofs, err := prepareRepo(ctx, opts.CloneOpts, opts.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	projectFile := repofs.Join(store.Default.ProjectsDir, opts.ProjectName+".yaml")
+	proj, appSet, err := getProjectInfoFromFile(repofs, projectFile)
+	if err != nil {
+		return fmt.Errorf("failed to read project '%s': %w", opts.ProjectName, err)
+	}
+
+	currentServer := proj.Annotations[store.Default.DestServerAnnotation]
+	if opts.DestKubeServer == "" {
+		opts.DestKubeServer = currentServer
+		if opts.DestKubeContext != "" {
+			opts.DestKubeServer, err = util.KubeContextToServer(opts.DestKubeContext)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	destCluster, err := getDestCluster(repofs, opts.DestKubeServer)
+	if err != nil {
+		return err
+	}
+
+	generateOpts := getGenerateProjectOptions(proj, appSet, opts.CloneOpts)
+	generateOpts.DefaultDestServer = opts.DestKubeServer
+	generateOpts.DefaultDestContext = destCluster.Name
+	if opts.Labels != nil {
+		generateOpts.Labels = opts.Labels
+	}
+
+	if opts.Annotations != nil {
+		generateOpts.Annotations = opts.Annotations
+	}
+	projectYAML, appSetYAML, _, _, err := generateProjectManifests(generateOpts)
+	if err != nil {
+		return fmt.Errorf("failed to generate project resources: %w", err)
+	}
+
+	if opts.DryRun {
+		log.G(ctx).Printf("%s", util.JoinManifests(projectYAML, appSetYAML))
+		return nil
+	}
+
+	if err = billyUtils.WriteFile(repofs, projectFile, util.JoinManifests(projectYAML, appSetYAML), 0666); err != nil {
+		return fmt.Errorf("failed to write project file: %w", err)
+	}
+
+	if opts.UpdateApps {
+		configs, err := getProjectAppConfigs(repofs, opts.ProjectName)
+		if err != nil {
+			return err
+		}
+
+		if err = moveApps(repofs, configs, destCluster); err != nil {
+			return err
+		}
+
+		log.G(ctx).Infof("moved %d app(s) to cluster '%s'", len(configs), destCluster.Name)
+	} else if opts.DestKubeServer != currentServer {
+		log.G(ctx).Info("existing apps keep their destination, use --update-apps to move them as well")
+	}
+
+	log.G(ctx).Info("committing changes to gitops repo...")
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("Updated project '%s'", opts.ProjectName)}); err != nil {
+		return fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	log.G(ctx).Infof("project updated: '%s'", opts.ProjectName)
+
+	return nil
+}
+
+func NewProjectRenameCommand() *cobra.Command {
+	var (
+		keepResources bool
+		cloneOpts     *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rename [PROJECT] [NEW_NAME]",
+		Short: "Rename a project, and move all of its apps to the new name",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Rename a project
+
+	<BIN> project rename <PROJECT_NAME> <NEW_NAME>
+
+# Rename a project, without deleting and recreating the live resources of its apps
+
+	<BIN> project rename <PROJECT_NAME> <NEW_NAME> --keep-resources
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 2 {
+				log.G(ctx).Fatal("must enter project name and new name")
+			}
+
+			return RunProjectRename(ctx, &ProjectRenameOptions{
+				CloneOpts:      cloneOpts,
+				ProjectName:    args[0],
+				NewProjectName: args[1],
+				KeepResources:  keepResources,
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&keepResources, "keep-resources", false, "If true, the old project is kept without any apps, and its ApplicationSet is set to preserve the resources of the apps it deletes, so the new apps adopt them. Delete the old project once the new apps are synced")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	return cmd
+}
+
+func RunProjectRename(ctx context.Context, opts *ProjectRenameOptions) error {
+	r, repofs, err := prepareRepo(ctx, opts.CloneOpts, opts.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	newProjectFile := repofs.Join(store.Default.ProjectsDir, opts.NewProjectName+".yaml")
+	if repofs.ExistsOrDie(newProjectFile) {
+		return fmt.Errorf("project '%s' already exists", opts.NewProjectName)
+	}
+
+	projectFile := repofs.Join(store.Default.ProjectsDir, opts.ProjectName+".yaml")
+	proj, appSet, err := getProjectInfoFromFile(repofs, projectFile)
+	if err != nil {
+		return fmt.Errorf("failed to read project '%s': %w", opts.ProjectName, err)
+	}
+
+	generateOpts := getGenerateProjectOptions(proj, appSet, opts.CloneOpts)
+	generateOpts.Name = opts.NewProjectName
+	warnDroppedRoleTokens(ctx, renameProjectRefs(generateOpts, opts.ProjectName), opts.ProjectName, opts.NewProjectName)
+	projectYAML, appSetYAML, _, _, err := generateProjectManifests(generateOpts)
+	if err != nil {
+		return fmt.Errorf("failed to generate project resources: %w", err)
+	}
+
+	if err = billyUtils.WriteFile(repofs, newProjectFile, util.JoinManifests(projectYAML, appSetYAML), 0666); err != nil {
+		return fmt.Errorf("failed to write project file: %w", err)
+	}
+
+	allApps, err := repofs.ReadDir(store.Default.AppsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to list all applications")
+	}
+
+	for _, app := range allApps {
+		if err = application.RenameInProject(repofs, app.Name(), opts.ProjectName, opts.NewProjectName); err != nil {
+			return err
+		}
+	}
+
+	if opts.KeepResources {
+		if appSet.Spec.SyncPolicy == nil {
+			appSet.Spec.SyncPolicy = &argocdv1alpha1.ApplicationSetSyncPolicy{}
+		}
+
+		appSet.Spec.SyncPolicy.PreserveResourcesOnDeletion = true
+		err = writeProjectFile(repofs, projectFile, proj, appSet)
+	} else {
+		err = repofs.Remove(projectFile)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to update project '%s': %w", opts.ProjectName, err)
+	}
+
+	log.G(ctx).Info("committing changes to gitops repo...")
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("Renamed project '%s' to '%s'", opts.ProjectName, opts.NewProjectName)}); err != nil {
+		return fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	log.G(ctx).Infof("project renamed: '%s' -> '%s'", opts.ProjectName, opts.NewProjectName)
+	if opts.KeepResources {
+		log.G(ctx).Infof(util.Doc("once the apps of project '%s' are synced, delete the old project with: <BIN> project delete %s"), opts.NewProjectName, opts.ProjectName)
+	}
+
+	return nil
+}
+
+func NewProjectCloneCommand() *cobra.Command {
+	var (
+		kubeServer  string
+		kubeContext string
+		cloneOpts   *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "clone [PROJECT] [NEW_NAME]",
+		Short: "Create a new project with a copy of all of the apps of an existing project",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Create a new project, that deploys the same apps to the same cluster
+
+	<BIN> project clone <PROJECT_NAME> <NEW_NAME>
+
+# Create a new environment on another cluster, with all of the apps of an existing project
+
+	<BIN> project clone <PROJECT_NAME> <NEW_NAME> --dest-kube-context <CONTEXT>
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 2 {
+				log.G(ctx).Fatal("must enter project name and new name")
+			}
+
+			return RunProjectClone(ctx, &ProjectCloneOptions{
+				CloneOpts:       cloneOpts,
+				ProjectName:     args[0],
+				NewProjectName:  args[1],
+				DestKubeServer:  kubeServer,
+				DestKubeContext: kubeContext,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&kubeServer, "dest-server", "", "The default destination kubernetes server of the new project and its apps. Defaults to the destination of the existing project")
+	cmd.Flags().StringVar(&kubeContext, "dest-kube-context", "", "The default destination kubernetes context of the new project and its apps (will be ignored if --dest-server is supplied)")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	return cmd
+}
+
+func RunProjectClone(ctx context.Context, opts *ProjectCloneOptions) error {
+	r, repofs, err := prepareRepo(ctx, opts.CloneOpts, opts.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	newProjectFile := repofs.Join(store.Default.ProjectsDir, opts.NewProjectName+".yaml")
+	if repofs.ExistsOrDie(newProjectFile) {
+		return fmt.Errorf("project '%s' already exists", opts.NewProjectName)
+	}
+
+	proj, appSet, err := getProjectInfoFromFile(repofs, repofs.Join(store.Default.ProjectsDir, opts.ProjectName+".yaml"))
+	if err != nil {
+		return fmt.Errorf("failed to read project '%s': %w", opts.ProjectName, err)
+	}
+
+	currentServer := proj.Annotations[store.Default.DestServerAnnotation]
+	if opts.DestKubeServer == "" {
+		opts.DestKubeServer = currentServer
+		if opts.DestKubeContext != "" {
+			opts.DestKubeServer, err = util.KubeContextToServer(opts.DestKubeContext)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	destCluster, err := getDestCluster(repofs, opts.DestKubeServer)
+	if err != nil {
+		return err
+	}
+
+	generateOpts := getGenerateProjectOptions(proj, appSet, opts.CloneOpts)
+	generateOpts.Name = opts.NewProjectName
+	generateOpts.DefaultDestServer = opts.DestKubeServer
+	generateOpts.DefaultDestContext = destCluster.Name
+	warnDroppedRoleTokens(ctx, renameProjectRefs(generateOpts, opts.ProjectName), opts.ProjectName, opts.NewProjectName)
+	projectYAML, appSetYAML, _, _, err := generateProjectManifests(generateOpts)
+	if err != nil {
+		return fmt.Errorf("failed to generate project resources: %w", err)
+	}
+
+	if err = billyUtils.WriteFile(repofs, newProjectFile, util.JoinManifests(projectYAML, appSetYAML), 0666); err != nil {
+		return fmt.Errorf("failed to write project file: %w", err)
+	}
+
+	allApps, err := repofs.ReadDir(store.Default.AppsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to list all applications")
+	}
+
+	for _, app := range allApps {
+		if err = application.CopyToProject(repofs, app.Name(), opts.ProjectName, opts.NewProjectName); err != nil {
+			return err
+		}
+	}
+
+	configs, err := getProjectAppConfigs(repofs, opts.NewProjectName)
+	if err != nil {
+		return err
+	}
+
+	if opts.DestKubeServer != currentServer {
+		if err = moveApps(repofs, configs, destCluster); err != nil {
+			return err
+		}
+	}
+
+	log.G(ctx).Info("committing changes to gitops repo...")
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("Cloned project '%s' to '%s'", opts.ProjectName, opts.NewProjectName)}); err != nil {
+		return fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	log.G(ctx).Infof("project cloned: '%s' -> '%s', with %d app(s)", opts.ProjectName, opts.NewProjectName, len(configs))
+
+	return nil
+}
+
+// getDestCluster returns the configured cluster of the server
+func getDestCluster(repofs fs.FS, server string) (*application.ClusterResConfig, error) {
+	clusters, err := application.ListClusters(repofs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	for _, c := range clusters {
+		if c.Server == server {
+			return c, nil
+		}
+	}
+
+	return nil, fmt.Errorf(util.Doc("cluster '%s' is not configured yet, please execute `<BIN> cluster add` first"), server)
+}
+
+// getGenerateProjectOptions returns the options that generate the existing project manifests
+func getGenerateProjectOptions(proj *argocdv1alpha1.AppProject, appSet *argocdv1alpha1.ApplicationSet, cloneOpts *git.CloneOptions) *GenerateProjectOptions {
+	labels := map[string]string{}
+	for k, v := range appSet.Spec.Template.Labels {
+		if k != store.Default.LabelKeyAppManagedBy && k != store.Default.LabelKeyAppName {
+			labels[k] = v
+		}
+	}
+
+	return &GenerateProjectOptions{
+		Name:                       proj.Name,
+		Namespace:                  proj.Namespace,
+		RepoURL:                    cloneOpts.URL(),
+		Revision:                   cloneOpts.Revision(),
+		InstallationPath:           cloneOpts.Path(),
+		DefaultDestServer:          proj.Annotations[store.Default.DestServerAnnotation],
+		Labels:                     labels,
+		Annotations:                appSet.Spec.Template.Annotations,
+		SourceRepos:                proj.Spec.SourceRepos,
+		Destinations:               proj.Spec.Destinations,
+		ClusterResourceWhitelist:   proj.Spec.ClusterResourceWhitelist,
+		ClusterResourceBlacklist:   proj.Spec.ClusterResourceBlacklist,
+		NamespaceResourceWhitelist: proj.Spec.NamespaceResourceWhitelist,
+		NamespaceResourceBlacklist: proj.Spec.NamespaceResourceBlacklist,
+		Roles:                      proj.Spec.Roles,
+		SyncWindows:                proj.Spec.SyncWindows,
+		SyncPolicy:                 appSet.Spec.Template.Spec.SyncPolicy,
+		ClusterSelector:            getProjectClusterSelector(appSet),
+	}
+}
+
+// renameProjectRefs rewrites the role policies and sync window applications of opts that refer
+// to the project oldName, so they refer to the project opts.Name instead. JWT tokens are issued
+// for a specific project, so they are removed from the roles. Returns the names of the roles
+// that had tokens
+func renameProjectRefs(opts *GenerateProjectOptions, oldName string) []string {
+	droppedTokens := []string{}
+	roles := make([]argocdv1alpha1.ProjectRole, 0, len(opts.Roles))
+	for _, role := range opts.Roles {
+		policies := make([]string, 0, len(role.Policies))
+		for _, policy := range role.Policies {
+			policies = append(policies, renamePolicy(policy, oldName, opts.Name, role.Name))
+		}
+
+		if len(role.JWTTokens) > 0 {
+			droppedTokens = append(droppedTokens, role.Name)
+		}
+
+		role.Policies = policies
+		role.JWTTokens = nil
+		roles = append(roles, role)
+	}
+
+	windows := make(argocdv1alpha1.SyncWindows, 0, len(opts.SyncWindows))
+	for _, window := range opts.SyncWindows {
+		w := *window
+		w.Applications = nil
+		for _, app := range window.Applications {
+			if strings.HasPrefix(app, oldName+"-") {
+				app = opts.Name + "-" + strings.TrimPrefix(app, oldName+"-")
+			}
+
+			w.Applications = append(w.Applications, app)
+		}
+
+		windows = append(windows, &w)
+	}
+
+	if opts.Roles != nil {
+		opts.Roles = roles
+	}
+
+	if opts.SyncWindows != nil {
+		opts.SyncWindows = windows
+	}
+
+	return droppedTokens
+}
+
+// renamePolicy rewrites the subject ("proj:<oldName>:<roleName>") and the object ("<oldName>/<object>")
+// of a role policy to newName. Any other policy is returned as is
+func renamePolicy(policy, oldName, newName, roleName string) string {
+	fields := strings.Split(policy, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	if len(fields) < 5 || fields[0] != "p" {
+		return policy
+	}
+
+	changed := false
+	if fields[1] == fmt.Sprintf("proj:%s:%s", oldName, roleName) {
+		fields[1] = fmt.Sprintf("proj:%s:%s", newName, roleName)
+		changed = true
+	}
+
+	if strings.HasPrefix(fields[4], oldName+"/") {
+		fields[4] = newName + "/" + strings.TrimPrefix(fields[4], oldName+"/")
+		changed = true
+	}
+
+	if !changed {
+		return policy
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// warnDroppedRoleTokens warns about the roles with tokens that were not kept under the new project name
+func warnDroppedRoleTokens(ctx context.Context, roles []string, oldName, newName string) {
+	for _, role := range roles {
+		log.G(ctx).Warnf(util.Doc("the tokens of role '%s' were issued for project '%s' and are not kept, issue new ones with: <BIN> project role token %s %s"), role, oldName, newName, role)
+	}
+}
+
+// getProjectAppConfigs returns the paths of the config files of all of the apps in the project
+func getProjectAppConfigs(repofs fs.FS, projectName string) ([]string, error) {
+	patterns := []string{
+		repofs.Join(store.Default.AppsDir, "*", store.Default.OverlaysDir, projectName, "config.json"),
+		repofs.Join(store.Default.AppsDir, "*", projectName, "config.json"),
+		repofs.Join(store.Default.AppsDir, "*", projectName, "config_dir.json"),
+	}
+
+	res := []string{}
+	for _, pattern := range patterns {
+		matches, err := billyUtils.Glob(repofs, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, matches...)
+	}
+
+	return res, nil
+}
+
 func NewProjectListCommand() *cobra.Command {
 	var (
 		cloneOpts *git.CloneOptions
@@ -441,6 +1273,59 @@ var getProjectInfoFromFile = func(repofs fs.FS, name string) (*argocdv1alpha1.Ap
 	return proj, appSet, nil
 }
 
+// updateProject applies updateFn to the AppProject of the project, and commits the change
+func updateProject(ctx context.Context, cloneOpts *git.CloneOptions, projectName, commitMsg string, updateFn func(*argocdv1alpha1.AppProject) error) error {
+	r, repofs, err := prepareRepo(ctx, cloneOpts, projectName)
+	if err != nil {
+		return err
+	}
+
+	if err = updateProjectFile(repofs, projectName, updateFn); err != nil {
+		return err
+	}
+
+	log.G(ctx).Info("committing changes to gitops repo...")
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: commitMsg}); err != nil {
+		return fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	return nil
+}
+
+// updateProjectFile applies updateFn to the AppProject of the project, and writes the project
+// file back to the repo
+func updateProjectFile(repofs fs.FS, projectName string, updateFn func(*argocdv1alpha1.AppProject) error) error {
+	projectFile := repofs.Join(store.Default.ProjectsDir, projectName+".yaml")
+	proj, appSet, err := getProjectInfoFromFile(repofs, projectFile)
+	if err != nil {
+		return fmt.Errorf("failed to read project '%s': %w", projectName, err)
+	}
+
+	if err = updateFn(proj); err != nil {
+		return err
+	}
+
+	return writeProjectFile(repofs, projectFile, proj, appSet)
+}
+
+func writeProjectFile(repofs fs.FS, projectFile string, proj *argocdv1alpha1.AppProject, appSet *argocdv1alpha1.ApplicationSet) error {
+	projectYAML, err := yaml.Marshal(proj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal AppProject: %w", err)
+	}
+
+	appSetYAML, err := yaml.Marshal(appSet)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ApplicationSet: %w", err)
+	}
+
+	if err = billyUtils.WriteFile(repofs, projectFile, util.JoinManifests(projectYAML, appSetYAML), 0666); err != nil {
+		return fmt.Errorf("failed to write project file: %w", err)
+	}
+
+	return nil
+}
+
 func NewProjectDeleteCommand() *cobra.Command {
 	var (
 		cloneOpts *git.CloneOptions
@@ -0,0 +1,427 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/argocd"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/spf13/cobra"
+)
+
+type (
+	ProjectRoleCreateOptions struct {
+		CloneOpts   *git.CloneOptions
+		ProjectName string
+		RoleName    string
+		Description string
+	}
+
+	ProjectRoleDeleteOptions struct {
+		CloneOpts   *git.CloneOptions
+		ProjectName string
+		RoleName    string
+	}
+
+	ProjectRoleAddPolicyOptions struct {
+		CloneOpts   *git.CloneOptions
+		ProjectName string
+		RoleName    string
+		Resource    string
+		Action      string
+		Object      string
+		Permission  string
+	}
+
+	ProjectRoleAddGroupOptions struct {
+		CloneOpts   *git.CloneOptions
+		ProjectName string
+		RoleName    string
+		Group       string
+	}
+
+	ProjectRoleTokenOptions struct {
+		ProjectName     string
+		RoleName        string
+		Namespace       string
+		KubeConfig      string
+		KubeContextName string
+		ExpiresIn       string
+		TokenOnly       bool
+	}
+)
+
+var createProjectRoleToken = argocd.CreateProjectRoleToken
+
+func NewProjectRoleCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "role",
+		Short: "Manage the roles of a project",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.HelpFunc()(cmd, args)
+			exit(1)
+		},
+	}
+
+	cmd.AddCommand(NewProjectRoleCreateCommand())
+	cmd.AddCommand(NewProjectRoleDeleteCommand())
+	cmd.AddCommand(NewProjectRoleAddPolicyCommand())
+	cmd.AddCommand(NewProjectRoleAddGroupCommand())
+	cmd.AddCommand(NewProjectRoleTokenCommand())
+
+	return cmd
+}
+
+func NewProjectRoleCreateCommand() *cobra.Command {
+	var (
+		description string
+		cloneOpts   *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create [PROJECT] [ROLE]",
+		Short: "Create a project role",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Create a role for the CI of a project
+
+	<BIN> project role create <PROJECT_NAME> ci --description "deploys from CI"
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 2 {
+				log.G(ctx).Fatal("must enter project and role names")
+			}
+
+			return RunProjectRoleCreate(ctx, &ProjectRoleCreateOptions{
+				CloneOpts:   cloneOpts,
+				ProjectName: args[0],
+				RoleName:    args[1],
+				Description: description,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&description, "description", "", "The description of the role")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	return cmd
+}
+
+func RunProjectRoleCreate(ctx context.Context, opts *ProjectRoleCreateOptions) error {
+	return updateProject(ctx, opts.CloneOpts, opts.ProjectName, fmt.Sprintf("Created role '%s' in project '%s'", opts.RoleName, opts.ProjectName), func(proj *argocdv1alpha1.AppProject) error {
+		if _, _, err := proj.GetRoleByName(opts.RoleName); err == nil {
+			return fmt.Errorf("role '%s' already exists in project '%s'", opts.RoleName, opts.ProjectName)
+		}
+
+		proj.Spec.Roles = append(proj.Spec.Roles, argocdv1alpha1.ProjectRole{
+			Name:        opts.RoleName,
+			Description: opts.Description,
+		})
+		return nil
+	})
+}
+
+func NewProjectRoleDeleteCommand() *cobra.Command {
+	var (
+		cloneOpts *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "delete [PROJECT] [ROLE]",
+		Short: "Delete a project role",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Delete a project role
+
+	<BIN> project role delete <PROJECT_NAME> ci
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 2 {
+				log.G(ctx).Fatal("must enter project and role names")
+			}
+
+			return RunProjectRoleDelete(ctx, &ProjectRoleDeleteOptions{
+				CloneOpts:   cloneOpts,
+				ProjectName: args[0],
+				RoleName:    args[1],
+			})
+		},
+	}
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	return cmd
+}
+
+func RunProjectRoleDelete(ctx context.Context, opts *ProjectRoleDeleteOptions) error {
+	return updateProject(ctx, opts.CloneOpts, opts.ProjectName, fmt.Sprintf("Deleted role '%s' from project '%s'", opts.RoleName, opts.ProjectName), func(proj *argocdv1alpha1.AppProject) error {
+		_, i, err := proj.GetRoleByName(opts.RoleName)
+		if err != nil {
+			return err
+		}
+
+		proj.Spec.Roles = append(proj.Spec.Roles[:i], proj.Spec.Roles[i+1:]...)
+		return nil
+	})
+}
+
+func NewProjectRoleAddPolicyCommand() *cobra.Command {
+	var (
+		resource   string
+		action     string
+		object     string
+		permission string
+		cloneOpts  *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add-policy [PROJECT] [ROLE]",
+		Short: "Add a policy to a project role",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Allow the role to sync all of the project applications
+
+	<BIN> project role add-policy <PROJECT_NAME> ci --action sync
+
+# Deny the role from deleting a specific application
+
+	<BIN> project role add-policy <PROJECT_NAME> ci --action delete --object <PROJECT_NAME>-<APP_NAME> --permission deny
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 2 {
+				log.G(ctx).Fatal("must enter project and role names")
+			}
+
+			return RunProjectRoleAddPolicy(ctx, &ProjectRoleAddPolicyOptions{
+				CloneOpts:   cloneOpts,
+				ProjectName: args[0],
+				RoleName:    args[1],
+				Resource:    resource,
+				Action:      action,
+				Object:      object,
+				Permission:  permission,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&resource, "resource", "applications", "The resource the policy applies to")
+	cmd.Flags().StringVar(&action, "action", "", "The action the policy applies to (e.g. get, sync, delete)")
+	cmd.Flags().StringVar(&object, "object", "*", "The name of the object in the project the policy applies to, can be a glob pattern")
+	cmd.Flags().StringVar(&permission, "permission", "allow", "Whether to allow or deny the action (allow|deny)")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	die(cmd.MarkFlagRequired("action"))
+
+	return cmd
+}
+
+func RunProjectRoleAddPolicy(ctx context.Context, opts *ProjectRoleAddPolicyOptions) error {
+	if opts.Permission != "allow" && opts.Permission != "deny" {
+		return fmt.Errorf("invalid permission '%s', must be one of: allow|deny", opts.Permission)
+	}
+
+	policy := fmt.Sprintf("p, proj:%s:%s, %s, %s, %s/%s, %s", opts.ProjectName, opts.RoleName, opts.Resource, opts.Action, opts.ProjectName, opts.Object, opts.Permission)
+	return updateProject(ctx, opts.CloneOpts, opts.ProjectName, fmt.Sprintf("Added policy to role '%s' in project '%s'", opts.RoleName, opts.ProjectName), func(proj *argocdv1alpha1.AppProject) error {
+		role, err := getProjectRole(proj, opts.RoleName)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range role.Policies {
+			if p == policy {
+				return fmt.Errorf("policy '%s' already exists in role '%s'", policy, opts.RoleName)
+			}
+		}
+
+		role.Policies = append(role.Policies, policy)
+		return nil
+	})
+}
+
+func NewProjectRoleAddGroupCommand() *cobra.Command {
+	var (
+		cloneOpts *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add-group [PROJECT] [ROLE] [GROUP]",
+		Short: "Add an OIDC group claim to a project role",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Give the members of an OIDC group the permissions of the role
+
+	<BIN> project role add-group <PROJECT_NAME> developers my-org:team-a
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 3 {
+				log.G(ctx).Fatal("must enter project, role and group names")
+			}
+
+			return RunProjectRoleAddGroup(ctx, &ProjectRoleAddGroupOptions{
+				CloneOpts:   cloneOpts,
+				ProjectName: args[0],
+				RoleName:    args[1],
+				Group:       args[2],
+			})
+		},
+	}
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	return cmd
+}
+
+func RunProjectRoleAddGroup(ctx context.Context, opts *ProjectRoleAddGroupOptions) error {
+	return updateProject(ctx, opts.CloneOpts, opts.ProjectName, fmt.Sprintf("Added group '%s' to role '%s' in project '%s'", opts.Group, opts.RoleName, opts.ProjectName), func(proj *argocdv1alpha1.AppProject) error {
+		role, err := getProjectRole(proj, opts.RoleName)
+		if err != nil {
+			return err
+		}
+
+		for _, g := range role.Groups {
+			if g == opts.Group {
+				return fmt.Errorf("group '%s' already exists in role '%s'", opts.Group, opts.RoleName)
+			}
+		}
+
+		role.Groups = append(role.Groups, opts.Group)
+		return nil
+	})
+}
+
+// getProjectRole returns the role in the project spec, so it can be changed in place
+func getProjectRole(proj *argocdv1alpha1.AppProject, roleName string) (*argocdv1alpha1.ProjectRole, error) {
+	_, i, err := proj.GetRoleByName(roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proj.Spec.Roles[i], nil
+}
+
+func NewProjectRoleTokenCommand() *cobra.Command {
+	var (
+		expiresIn string
+		tokenOnly bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "token [PROJECT] [ROLE]",
+		Short: "Issue a JWT for a project role",
+		Long:  "Issue a JWT for a project role, through the Argo CD api. The role must already be synced to the cluster",
+		Example: util.Doc(`
+# Issue a token for the CI role of a project, that expires in 30 days
+
+	<BIN> project role token <PROJECT_NAME> ci --expires-in 720h --token-only
+`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 2 {
+				log.G(ctx).Fatal("must enter project and role names")
+			}
+
+			return RunProjectRoleToken(ctx, &ProjectRoleTokenOptions{
+				ProjectName:     args[0],
+				RoleName:        args[1],
+				Namespace:       cmd.Flag("namespace").Value.String(),
+				KubeConfig:      cmd.Flag("kubeconfig").Value.String(),
+				KubeContextName: cmd.Flag("context").Value.String(),
+				ExpiresIn:       expiresIn,
+				TokenOnly:       tokenOnly,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&expiresIn, "expires-in", "", "Duration before the token will expire (e.g. 24h). (default: No expiration)")
+	cmd.Flags().BoolVar(&tokenOnly, "token-only", false, "If true, will only print the token")
+	_ = kube.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func RunProjectRoleToken(ctx context.Context, opts *ProjectRoleTokenOptions) error {
+	if opts.Namespace == "" {
+		opts.Namespace = store.Default.ArgoCDNamespace
+	}
+
+	log.G(ctx).Debugf("creating token for role '%s' in project '%s'", opts.RoleName, opts.ProjectName)
+	err := createProjectRoleToken(&argocd.ProjectRoleTokenOptions{
+		Namespace:   opts.Namespace,
+		KubeConfig:  opts.KubeConfig,
+		KubeContext: opts.KubeContextName,
+		Project:     opts.ProjectName,
+		Role:        opts.RoleName,
+		ExpiresIn:   opts.ExpiresIn,
+		TokenOnly:   opts.TokenOnly,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create token for role '%s' in project '%s': %w", opts.RoleName, opts.ProjectName, err)
+	}
+
+	return nil
+}
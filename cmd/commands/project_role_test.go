@@ -0,0 +1,287 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/argocd"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	gitmocks "github.com/argoproj-labs/argocd-autopilot/pkg/git/mocks"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// projectTestRepo returns a repo with the project "project", that has the provided roles
+func projectTestRepo(t *testing.T, roles ...argocdv1alpha1.ProjectRole) fs.FS {
+	repofs := fs.Create(memfs.New())
+	projectYAML, appSetYAML, _, _, err := generateProjectManifests(&GenerateProjectOptions{
+		Name:              "project",
+		Namespace:         "namespace",
+		DefaultDestServer: "https://kubernetes.default.svc",
+		Roles:             roles,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, billyUtils.WriteFile(repofs, "projects/project.yaml", util.JoinManifests(projectYAML, appSetYAML), 0666))
+	return repofs
+}
+
+func runUpdateProjectTest(t *testing.T, repofs fs.FS, commitMsg string, runFn func() error) error {
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+
+	r := gitmocks.NewMockRepository(gomock.NewController(t))
+	if commitMsg != "" {
+		r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: commitMsg}).Return("revision", nil)
+	}
+
+	prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+		return r, repofs, nil
+	}
+
+	return runFn()
+}
+
+func getTestProjectRoles(t *testing.T, repofs fs.FS) []argocdv1alpha1.ProjectRole {
+	proj, appSet, err := getProjectInfoFromFile(repofs, "projects/project.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "project", appSet.Name)
+	return proj.Spec.Roles
+}
+
+func TestRunProjectRoleCreate(t *testing.T) {
+	tests := map[string]struct {
+		roles     []argocdv1alpha1.ProjectRole
+		commitMsg string
+		wantErr   string
+		wantRoles []argocdv1alpha1.ProjectRole
+	}{
+		"should add the role": {
+			roles:     []argocdv1alpha1.ProjectRole{{Name: "admin"}},
+			commitMsg: "Created role 'ci' in project 'project'",
+			wantRoles: []argocdv1alpha1.ProjectRole{{Name: "admin"}, {Name: "ci", Description: "deploys from CI"}},
+		},
+		"should fail if the role already exists": {
+			roles:   []argocdv1alpha1.ProjectRole{{Name: "ci"}},
+			wantErr: "role 'ci' already exists in project 'project'",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := projectTestRepo(t, tt.roles...)
+			err := runUpdateProjectTest(t, repofs, tt.commitMsg, func() error {
+				return RunProjectRoleCreate(context.Background(), &ProjectRoleCreateOptions{
+					ProjectName: "project",
+					RoleName:    "ci",
+					Description: "deploys from CI",
+				})
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRoles, getTestProjectRoles(t, repofs))
+		})
+	}
+}
+
+func TestRunProjectRoleDelete(t *testing.T) {
+	tests := map[string]struct {
+		roles     []argocdv1alpha1.ProjectRole
+		commitMsg string
+		wantErr   string
+		wantRoles []argocdv1alpha1.ProjectRole
+	}{
+		"should remove the role": {
+			roles:     []argocdv1alpha1.ProjectRole{{Name: "admin"}, {Name: "ci"}},
+			commitMsg: "Deleted role 'ci' from project 'project'",
+			wantRoles: []argocdv1alpha1.ProjectRole{{Name: "admin"}},
+		},
+		"should fail if the role does not exist": {
+			roles:   []argocdv1alpha1.ProjectRole{{Name: "admin"}},
+			wantErr: "role 'ci' does not exist in project 'project'",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := projectTestRepo(t, tt.roles...)
+			err := runUpdateProjectTest(t, repofs, tt.commitMsg, func() error {
+				return RunProjectRoleDelete(context.Background(), &ProjectRoleDeleteOptions{
+					ProjectName: "project",
+					RoleName:    "ci",
+				})
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRoles, getTestProjectRoles(t, repofs))
+		})
+	}
+}
+
+func TestRunProjectRoleAddPolicy(t *testing.T) {
+	tests := map[string]struct {
+		opts         *ProjectRoleAddPolicyOptions
+		roles        []argocdv1alpha1.ProjectRole
+		commitMsg    string
+		wantErr      string
+		wantPolicies []string
+	}{
+		"should add the policy": {
+			opts: &ProjectRoleAddPolicyOptions{
+				Resource:   "applications",
+				Action:     "sync",
+				Object:     "*",
+				Permission: "allow",
+			},
+			roles:        []argocdv1alpha1.ProjectRole{{Name: "ci"}},
+			commitMsg:    "Added policy to role 'ci' in project 'project'",
+			wantPolicies: []string{"p, proj:project:ci, applications, sync, project/*, allow"},
+		},
+		"should fail if the policy already exists": {
+			opts: &ProjectRoleAddPolicyOptions{
+				Resource:   "applications",
+				Action:     "sync",
+				Object:     "*",
+				Permission: "allow",
+			},
+			roles:   []argocdv1alpha1.ProjectRole{{Name: "ci", Policies: []string{"p, proj:project:ci, applications, sync, project/*, allow"}}},
+			wantErr: "policy 'p, proj:project:ci, applications, sync, project/*, allow' already exists in role 'ci'",
+		},
+		"should fail on an invalid permission": {
+			opts: &ProjectRoleAddPolicyOptions{
+				Action:     "sync",
+				Permission: "maybe",
+			},
+			wantErr: "invalid permission 'maybe', must be one of: allow|deny",
+		},
+		"should fail if the role does not exist": {
+			opts: &ProjectRoleAddPolicyOptions{
+				Action:     "sync",
+				Permission: "deny",
+			},
+			wantErr: "role 'ci' does not exist in project 'project'",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := projectTestRepo(t, tt.roles...)
+			tt.opts.ProjectName = "project"
+			tt.opts.RoleName = "ci"
+			err := runUpdateProjectTest(t, repofs, tt.commitMsg, func() error {
+				return RunProjectRoleAddPolicy(context.Background(), tt.opts)
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantPolicies, getTestProjectRoles(t, repofs)[0].Policies)
+		})
+	}
+}
+
+func TestRunProjectRoleAddGroup(t *testing.T) {
+	tests := map[string]struct {
+		roles      []argocdv1alpha1.ProjectRole
+		commitMsg  string
+		wantErr    string
+		wantGroups []string
+	}{
+		"should add the group": {
+			roles:      []argocdv1alpha1.ProjectRole{{Name: "ci", Groups: []string{"my-org:admins"}}},
+			commitMsg:  "Added group 'my-org:team-a' to role 'ci' in project 'project'",
+			wantGroups: []string{"my-org:admins", "my-org:team-a"},
+		},
+		"should fail if the group already exists": {
+			roles:   []argocdv1alpha1.ProjectRole{{Name: "ci", Groups: []string{"my-org:team-a"}}},
+			wantErr: "group 'my-org:team-a' already exists in role 'ci'",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := projectTestRepo(t, tt.roles...)
+			err := runUpdateProjectTest(t, repofs, tt.commitMsg, func() error {
+				return RunProjectRoleAddGroup(context.Background(), &ProjectRoleAddGroupOptions{
+					ProjectName: "project",
+					RoleName:    "ci",
+					Group:       "my-org:team-a",
+				})
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantGroups, getTestProjectRoles(t, repofs)[0].Groups)
+		})
+	}
+}
+
+func TestRunProjectRoleToken(t *testing.T) {
+	tests := map[string]struct {
+		opts     *ProjectRoleTokenOptions
+		tokenErr error
+		wantErr  string
+		wantOpts *argocd.ProjectRoleTokenOptions
+	}{
+		"should use the default argo-cd namespace": {
+			opts: &ProjectRoleTokenOptions{
+				ProjectName: "project",
+				RoleName:    "ci",
+				ExpiresIn:   "24h",
+				TokenOnly:   true,
+			},
+			wantOpts: &argocd.ProjectRoleTokenOptions{
+				Namespace: "argocd",
+				Project:   "project",
+				Role:      "ci",
+				ExpiresIn: "24h",
+				TokenOnly: true,
+			},
+		},
+		"should fail if the token can not be created": {
+			opts: &ProjectRoleTokenOptions{
+				ProjectName: "project",
+				RoleName:    "ci",
+				Namespace:   "custom",
+			},
+			tokenErr: errors.New("some error"),
+			wantErr:  "failed to create token for role 'ci' in project 'project': some error",
+		},
+	}
+	origCreateProjectRoleToken := createProjectRoleToken
+	defer func() { createProjectRoleToken = origCreateProjectRoleToken }()
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			createProjectRoleToken = func(opts *argocd.ProjectRoleTokenOptions) error {
+				if tt.wantOpts != nil {
+					assert.Equal(t, tt.wantOpts, opts)
+				}
+
+				return tt.tokenErr
+			}
+
+			err := RunProjectRoleToken(context.Background(), tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/spf13/cobra"
+)
+
+type (
+	ProjectSyncWindowAddOptions struct {
+		CloneOpts    *git.CloneOptions
+		ProjectName  string
+		Kind         string
+		Schedule     string
+		Duration     string
+		Applications []string
+		Namespaces   []string
+		Clusters     []string
+		ManualSync   bool
+		TimeZone     string
+		Description  string
+	}
+
+	ProjectSyncWindowListOptions struct {
+		CloneOpts   *git.CloneOptions
+		ProjectName string
+		Out         io.Writer
+	}
+
+	ProjectSyncWindowRemoveOptions struct {
+		CloneOpts   *git.CloneOptions
+		ProjectName string
+		ID          int
+	}
+)
+
+func NewProjectSyncWindowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "sync-window",
+		Aliases: []string{"windows"},
+		Short:   "Manage the sync windows of a project",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.HelpFunc()(cmd, args)
+			exit(1)
+		},
+	}
+
+	cmd.AddCommand(NewProjectSyncWindowAddCommand())
+	cmd.AddCommand(NewProjectSyncWindowListCommand())
+	cmd.AddCommand(NewProjectSyncWindowRemoveCommand())
+
+	return cmd
+}
+
+func NewProjectSyncWindowAddCommand() *cobra.Command {
+	var (
+		opts      ProjectSyncWindowAddOptions
+		cloneOpts *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add [PROJECT]",
+		Short: "Add a sync window to a project",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Block syncing all of the project applications every night between 22:00 and 02:00, unless synced manually
+
+	<BIN> project sync-window add <PROJECT_NAME> --kind deny --schedule "0 22 * * *" --duration 4h --applications "*" --manual-sync
+
+# Only allow syncing a specific application on weekdays, during working hours
+
+	<BIN> project sync-window add <PROJECT_NAME> --kind allow --schedule "0 9 * * 1-5" --duration 8h --applications <PROJECT_NAME>-<APP_NAME> --time-zone Europe/London
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 1 {
+				log.G(ctx).Fatal("must enter project name")
+			}
+
+			opts.CloneOpts = cloneOpts
+			opts.ProjectName = args[0]
+			return RunProjectSyncWindowAdd(ctx, &opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Kind, "kind", "", "Whether the window allows or denies syncs (allow|deny)")
+	cmd.Flags().StringVar(&opts.Schedule, "schedule", "", "The time the window starts, in cron format (e.g. \"0 22 * * *\")")
+	cmd.Flags().StringVar(&opts.Duration, "duration", "", "The duration of the window (e.g. 1h30m)")
+	cmd.Flags().StringSliceVar(&opts.Applications, "applications", nil, "The applications the window applies to, can be glob patterns. The application names are prefixed with the project name (e.g. \"<PROJECT_NAME>-*\")")
+	cmd.Flags().StringSliceVar(&opts.Namespaces, "namespaces", nil, "The destination namespaces the window applies to, can be glob patterns")
+	cmd.Flags().StringSliceVar(&opts.Clusters, "clusters", nil, "The destination clusters the window applies to, can be glob patterns")
+	cmd.Flags().BoolVar(&opts.ManualSync, "manual-sync", false, "If true, manual syncs are allowed while the window blocks syncs")
+	cmd.Flags().StringVar(&opts.TimeZone, "time-zone", "UTC", "The time zone of the schedule")
+	cmd.Flags().StringVar(&opts.Description, "description", "", "The description of the window")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	die(cmd.MarkFlagRequired("kind"))
+	die(cmd.MarkFlagRequired("schedule"))
+	die(cmd.MarkFlagRequired("duration"))
+
+	return cmd
+}
+
+func RunProjectSyncWindowAdd(ctx context.Context, opts *ProjectSyncWindowAddOptions) error {
+	if len(opts.Applications) == 0 && len(opts.Namespaces) == 0 && len(opts.Clusters) == 0 {
+		return fmt.Errorf("must supply at least one of --applications, --namespaces or --clusters")
+	}
+
+	commitMsg := fmt.Sprintf("Added %s sync window to project '%s'", opts.Kind, opts.ProjectName)
+	return updateProject(ctx, opts.CloneOpts, opts.ProjectName, commitMsg, func(proj *argocdv1alpha1.AppProject) error {
+		err := proj.Spec.AddWindow(opts.Kind, opts.Schedule, opts.Duration, opts.Applications, opts.Namespaces, opts.Clusters, opts.ManualSync, opts.TimeZone, false, opts.Description)
+		if err != nil {
+			return fmt.Errorf("invalid sync window: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func NewProjectSyncWindowListCommand() *cobra.Command {
+	var (
+		cloneOpts *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list [PROJECT]",
+		Short: "List the sync windows of a project",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# List the sync windows of a project
+
+	<BIN> project sync-window list <PROJECT_NAME>
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 1 {
+				log.G(ctx).Fatal("must enter project name")
+			}
+
+			return RunProjectSyncWindowList(ctx, &ProjectSyncWindowListOptions{
+				CloneOpts:   cloneOpts,
+				ProjectName: args[0],
+				Out:         os.Stdout,
+			})
+		},
+	}
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS: memfs.New(),
+	})
+
+	return cmd
+}
+
+func RunProjectSyncWindowList(ctx context.Context, opts *ProjectSyncWindowListOptions) error {
+	_, repofs, err := prepareRepo(ctx, opts.CloneOpts, opts.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	proj, _, err := getProjectInfoFromFile(repofs, repofs.Join(store.Default.ProjectsDir, opts.ProjectName+".yaml"))
+	if err != nil {
+		return fmt.Errorf("failed to read project '%s': %w", opts.ProjectName, err)
+	}
+
+	w := tabwriter.NewWriter(opts.Out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "ID\tSTATUS\tKIND\tSCHEDULE\tDURATION\tAPPLICATIONS\tNAMESPACES\tCLUSTERS\tMANUAL SYNC\tTIME ZONE\n")
+	for i, window := range proj.Spec.SyncWindows {
+		status := "Inactive"
+		active, err := (&argocdv1alpha1.SyncWindows{window}).Active()
+		if err != nil {
+			return fmt.Errorf("invalid sync window %d: %w", i, err)
+		}
+
+		if active.HasWindows() {
+			status = "Active"
+		}
+
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\t%s\n",
+			i,
+			status,
+			window.Kind,
+			window.Schedule,
+			window.Duration,
+			formatList(window.Applications),
+			formatList(window.Namespaces),
+			formatList(window.Clusters),
+			window.ManualSync,
+			window.TimeZone,
+		)
+	}
+
+	return w.Flush()
+}
+
+func NewProjectSyncWindowRemoveCommand() *cobra.Command {
+	var (
+		cloneOpts *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "remove [PROJECT] [ID]",
+		Short: "Remove a sync window from a project",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Remove a sync window, by the ID shown in "<BIN> project sync-window list"
+
+	<BIN> project sync-window remove <PROJECT_NAME> 0
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) < 2 {
+				log.G(ctx).Fatal("must enter project name and window id")
+			}
+
+			id, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid window id '%s': %w", args[1], err)
+			}
+
+			return RunProjectSyncWindowRemove(ctx, &ProjectSyncWindowRemoveOptions{
+				CloneOpts:   cloneOpts,
+				ProjectName: args[0],
+				ID:          id,
+			})
+		},
+	}
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	return cmd
+}
+
+func RunProjectSyncWindowRemove(ctx context.Context, opts *ProjectSyncWindowRemoveOptions) error {
+	commitMsg := fmt.Sprintf("Removed sync window %d from project '%s'", opts.ID, opts.ProjectName)
+	return updateProject(ctx, opts.CloneOpts, opts.ProjectName, commitMsg, func(proj *argocdv1alpha1.AppProject) error {
+		return proj.Spec.DeleteWindow(opts.ID)
+	})
+}
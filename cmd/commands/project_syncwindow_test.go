@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func syncWindowTestRepo(t *testing.T, windows argocdv1alpha1.SyncWindows) fs.FS {
+	repofs := projectTestRepo(t)
+	assert.NoError(t, updateProjectFile(repofs, "project", func(proj *argocdv1alpha1.AppProject) error {
+		proj.Spec.SyncWindows = windows
+		return nil
+	}))
+	return repofs
+}
+
+func TestRunProjectSyncWindowAdd(t *testing.T) {
+	tests := map[string]struct {
+		opts        *ProjectSyncWindowAddOptions
+		commitMsg   string
+		wantErr     string
+		wantWindows argocdv1alpha1.SyncWindows
+	}{
+		"should add the sync window": {
+			opts: &ProjectSyncWindowAddOptions{
+				Kind:         "deny",
+				Schedule:     "0 22 * * *",
+				Duration:     "4h",
+				Applications: []string{"project-*"},
+				ManualSync:   true,
+				TimeZone:     "UTC",
+			},
+			commitMsg: "Added deny sync window to project 'project'",
+			wantWindows: argocdv1alpha1.SyncWindows{
+				{
+					Kind:         "deny",
+					Schedule:     "0 22 * * *",
+					Duration:     "4h",
+					Applications: []string{"project-*"},
+					ManualSync:   true,
+					TimeZone:     "UTC",
+				},
+			},
+		},
+		"should fail on an invalid kind": {
+			opts: &ProjectSyncWindowAddOptions{
+				Kind:       "block",
+				Schedule:   "0 22 * * *",
+				Duration:   "4h",
+				Namespaces: []string{"*"},
+			},
+			wantErr: "invalid sync window: kind 'block' mismatch: can only be allow or deny",
+		},
+		"should fail without any target": {
+			opts: &ProjectSyncWindowAddOptions{
+				Kind:     "deny",
+				Schedule: "0 22 * * *",
+				Duration: "4h",
+			},
+			wantErr: "must supply at least one of --applications, --namespaces or --clusters",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := projectTestRepo(t)
+			tt.opts.CloneOpts = &git.CloneOptions{}
+			tt.opts.ProjectName = "project"
+			err := runUpdateProjectTest(t, repofs, tt.commitMsg, func() error {
+				return RunProjectSyncWindowAdd(context.Background(), tt.opts)
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			proj, _, err := getProjectInfoFromFile(repofs, "projects/project.yaml")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantWindows, proj.Spec.SyncWindows)
+		})
+	}
+}
+
+func TestRunProjectSyncWindowList(t *testing.T) {
+	repofs := syncWindowTestRepo(t, argocdv1alpha1.SyncWindows{
+		{Kind: "deny", Schedule: "* * * * *", Duration: "1h", Applications: []string{"project-*"}, TimeZone: "UTC"},
+		{Kind: "allow", Schedule: "0 0 1 1 *", Duration: "1m", Namespaces: []string{"default", "web"}, Clusters: []string{"prod"}, ManualSync: true, TimeZone: "UTC"},
+	})
+	out := &bytes.Buffer{}
+	err := runUpdateProjectTest(t, repofs, "", func() error {
+		return RunProjectSyncWindowList(context.Background(), &ProjectSyncWindowListOptions{
+			ProjectName: "project",
+			Out:         out,
+		})
+	})
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Regexp(t, `^ID\s+STATUS\s+KIND\s+SCHEDULE\s+DURATION\s+APPLICATIONS\s+NAMESPACES\s+CLUSTERS\s+MANUAL SYNC\s+TIME ZONE$`, lines[0])
+	assert.Regexp(t, `^0\s+Active\s+deny\s+\* \* \* \* \*\s+1h\s+project-\*\s+-\s+-\s+false\s+UTC$`, lines[1])
+	assert.Regexp(t, `^1\s+Inactive\s+allow\s+0 0 1 1 \*\s+1m\s+-\s+default,web\s+prod\s+true\s+UTC$`, lines[2])
+}
+
+func TestRunProjectSyncWindowRemove(t *testing.T) {
+	tests := map[string]struct {
+		id          int
+		commitMsg   string
+		wantErr     string
+		wantWindows argocdv1alpha1.SyncWindows
+	}{
+		"should remove the sync window": {
+			id:          0,
+			commitMsg:   "Removed sync window 0 from project 'project'",
+			wantWindows: argocdv1alpha1.SyncWindows{{Kind: "allow", Schedule: "0 9 * * *", Duration: "8h"}},
+		},
+		"should fail if the sync window does not exist": {
+			id:      2,
+			wantErr: "window with id '2' not found",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := syncWindowTestRepo(t, argocdv1alpha1.SyncWindows{
+				{Kind: "deny", Schedule: "0 22 * * *", Duration: "4h"},
+				{Kind: "allow", Schedule: "0 9 * * *", Duration: "8h"},
+			})
+			err := runUpdateProjectTest(t, repofs, tt.commitMsg, func() error {
+				return RunProjectSyncWindowRemove(context.Background(), &ProjectSyncWindowRemoveOptions{
+					CloneOpts:   &git.CloneOptions{},
+					ProjectName: "project",
+					ID:          tt.id,
+				})
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			proj, _, err := getProjectInfoFromFile(repofs, "projects/project.yaml")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantWindows, proj.Spec.SyncWindows)
+		})
+	}
+}
@@ -164,6 +164,11 @@ func Test_generateProjectManifests(t *testing.T) {
 		wantContextName        string
 		wantLabels             map[string]string
 		wantAnnotations        map[string]string
+		wantSourceRepos        []string
+		wantDestinations       []argocdv1alpha1.ApplicationDestination
+		wantClusterWhitelist   []v1.GroupKind
+		wantClusterBlacklist   []v1.GroupKind
+		wantSyncPolicy         *argocdv1alpha1.SyncPolicy
 	}{
 		"should generate project and appset with correct values": {
 			o: &GenerateProjectOptions{
@@ -191,11 +196,69 @@ func Test_generateProjectManifests(t *testing.T) {
 			wantLabels: map[string]string{
 				"some-key":                         "some-value",
 				store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
-				store.Default.LabelKeyAppName:      "{{ appName }}",
+				store.Default.LabelKeyAppName:      "{{ .appName }}",
 			},
 			wantAnnotations: map[string]string{
 				"some-key": "some-value",
 			},
+			wantSourceRepos:      []string{"*"},
+			wantDestinations:     []argocdv1alpha1.ApplicationDestination{{Server: "*", Namespace: "*"}},
+			wantClusterWhitelist: []v1.GroupKind{{Group: "*", Kind: "*"}},
+			wantSyncPolicy: &argocdv1alpha1.SyncPolicy{
+				Automated: &argocdv1alpha1.SyncPolicyAutomated{SelfHeal: true, Prune: true, AllowEmpty: true},
+			},
+		},
+		"should generate a restricted project": {
+			o: &GenerateProjectOptions{
+				Name:                     "name",
+				Namespace:                "namespace",
+				DefaultDestServer:        "defaultDestServer",
+				RepoURL:                  "repoUrl",
+				Revision:                 "revision",
+				SourceRepos:              []string{"https://github.com/owner/apps"},
+				Destinations:             []argocdv1alpha1.ApplicationDestination{{Server: "defaultDestServer", Namespace: "team-a-*"}},
+				ClusterResourceWhitelist: []v1.GroupKind{{Kind: "Namespace"}},
+				ClusterResourceBlacklist: []v1.GroupKind{{Group: "rbac.authorization.k8s.io", Kind: "*"}},
+			},
+			wantName:               "name",
+			wantNamespace:          "namespace",
+			wantProjectDescription: "name project",
+			wantRepoURL:            "repoUrl",
+			wantRevision:           "revision",
+			wantDefaultDestServer:  "defaultDestServer",
+			wantSourceRepos:        []string{"https://github.com/owner/apps"},
+			wantDestinations:       []argocdv1alpha1.ApplicationDestination{{Server: "defaultDestServer", Namespace: "team-a-*"}},
+			wantClusterWhitelist:   []v1.GroupKind{{Kind: "Namespace"}},
+			wantClusterBlacklist:   []v1.GroupKind{{Group: "rbac.authorization.k8s.io", Kind: "*"}},
+			wantSyncPolicy: &argocdv1alpha1.SyncPolicy{
+				Automated: &argocdv1alpha1.SyncPolicyAutomated{SelfHeal: true, Prune: true, AllowEmpty: true},
+			},
+		},
+		"should generate a project with a custom sync policy": {
+			o: &GenerateProjectOptions{
+				Name:              "name",
+				Namespace:         "namespace",
+				DefaultDestServer: "defaultDestServer",
+				RepoURL:           "repoUrl",
+				Revision:          "revision",
+				SyncPolicy: &argocdv1alpha1.SyncPolicy{
+					SyncOptions: argocdv1alpha1.SyncOptions{"ServerSideApply=true"},
+					Retry:       &argocdv1alpha1.RetryStrategy{Limit: 3},
+				},
+			},
+			wantName:               "name",
+			wantNamespace:          "namespace",
+			wantProjectDescription: "name project",
+			wantRepoURL:            "repoUrl",
+			wantRevision:           "revision",
+			wantDefaultDestServer:  "defaultDestServer",
+			wantSourceRepos:        []string{"*"},
+			wantDestinations:       []argocdv1alpha1.ApplicationDestination{{Server: "*", Namespace: "*"}},
+			wantClusterWhitelist:   []v1.GroupKind{{Group: "*", Kind: "*"}},
+			wantSyncPolicy: &argocdv1alpha1.SyncPolicy{
+				SyncOptions: argocdv1alpha1.SyncOptions{"ServerSideApply=true"},
+				Retry:       &argocdv1alpha1.RetryStrategy{Limit: 3},
+			},
 		},
 	}
 	for ttname, tt := range tests {
@@ -216,6 +279,10 @@ func Test_generateProjectManifests(t *testing.T) {
 			assert.Equal(tt.wantNamespace, gotProject.Namespace, "Project Namespace")
 			assert.Equal(tt.wantProjectDescription, gotProject.Spec.Description, "Project Description")
 			assert.Equal(tt.o.DefaultDestServer, gotProject.Annotations[store.Default.DestServerAnnotation], "Application Set Default Destination Server")
+			assert.Equal(tt.wantSourceRepos, gotProject.Spec.SourceRepos, "Project Source Repos")
+			assert.Equal(tt.wantDestinations, gotProject.Spec.Destinations, "Project Destinations")
+			assert.Equal(tt.wantClusterWhitelist, gotProject.Spec.ClusterResourceWhitelist, "Project Cluster Resource Whitelist")
+			assert.Equal(tt.wantClusterBlacklist, gotProject.Spec.ClusterResourceBlacklist, "Project Cluster Resource Blacklist")
 
 			assert.Equal(tt.wantName, gotAppSet.Name, "Application Set Name")
 			assert.Equal(tt.wantNamespace, gotAppSet.Namespace, "Application Set Namespace")
@@ -224,6 +291,64 @@ func Test_generateProjectManifests(t *testing.T) {
 
 			assert.Equal(tt.wantNamespace, gotAppSet.Spec.Template.Namespace, "Application Set Template Namespace")
 			assert.Equal(tt.wantName, gotAppSet.Spec.Template.Spec.Project, "Application Set Template Project")
+			assert.Equal(tt.wantSyncPolicy, gotAppSet.Spec.Template.Spec.SyncPolicy, "Application Set Template Sync Policy")
+			assert.True(gotAppSet.Spec.GoTemplate, "Application Set Go Template")
+			assert.Equal(appSyncPolicyPatch, *gotAppSet.Spec.TemplatePatch, "Application Set Template Patch")
+		})
+	}
+}
+
+func Test_parseProjectRestrictions(t *testing.T) {
+	tests := map[string]struct {
+		opts    *ProjectCreateOptions
+		want    *GenerateProjectOptions
+		wantErr string
+	}{
+		"should not restrict anything by default": {
+			opts: &ProjectCreateOptions{},
+			want: &GenerateProjectOptions{},
+		},
+		"should parse the destinations and resources": {
+			opts: &ProjectCreateOptions{
+				SourceRepos:              []string{"https://github.com/owner/apps"},
+				Destinations:             []string{"https://kubernetes.default.svc,team-a-*"},
+				AllowClusterResources:    []string{"/Namespace"},
+				DenyClusterResources:     []string{"rbac.authorization.k8s.io/*"},
+				AllowNamespacedResources: []string{"Service", "apps/Deployment"},
+				DenyNamespacedResources:  []string{"ResourceQuota"},
+			},
+			want: &GenerateProjectOptions{
+				SourceRepos:                []string{"https://github.com/owner/apps"},
+				Destinations:               []argocdv1alpha1.ApplicationDestination{{Server: "https://kubernetes.default.svc", Namespace: "team-a-*"}},
+				ClusterResourceWhitelist:   []v1.GroupKind{{Kind: "Namespace"}},
+				ClusterResourceBlacklist:   []v1.GroupKind{{Group: "rbac.authorization.k8s.io", Kind: "*"}},
+				NamespaceResourceWhitelist: []v1.GroupKind{{Kind: "Service"}, {Group: "apps", Kind: "Deployment"}},
+				NamespaceResourceBlacklist: []v1.GroupKind{{Kind: "ResourceQuota"}},
+			},
+		},
+		"should fail on a destination without a namespace": {
+			opts: &ProjectCreateOptions{
+				Destinations: []string{"https://kubernetes.default.svc"},
+			},
+			wantErr: "invalid destination 'https://kubernetes.default.svc', expected <server>,<namespace>",
+		},
+		"should fail on a resource without a kind": {
+			opts: &ProjectCreateOptions{
+				DenyClusterResources: []string{"apps/"},
+			},
+			wantErr: "invalid resource 'apps/', expected <group>/<kind>",
+		},
+	}
+	for ttname, tt := range tests {
+		t.Run(ttname, func(t *testing.T) {
+			got, err := parseProjectRestrictions(tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
 		})
 	}
 }
@@ -646,7 +771,7 @@ func Test_getDefaultAppLabels(t *testing.T) {
 			labels: nil,
 			want: map[string]string{
 				store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
-				store.Default.LabelKeyAppName:      "{{ appName }}",
+				store.Default.LabelKeyAppName:      "{{ .appName }}",
 			},
 		},
 		"Should contain any additional labels sent": {
@@ -656,7 +781,7 @@ func Test_getDefaultAppLabels(t *testing.T) {
 			want: map[string]string{
 				"something":                        "or the other",
 				store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
-				store.Default.LabelKeyAppName:      "{{ appName }}",
+				store.Default.LabelKeyAppName:      "{{ .appName }}",
 			},
 		},
 		"Should overwrite the default managed by": {
@@ -665,7 +790,17 @@ func Test_getDefaultAppLabels(t *testing.T) {
 			},
 			want: map[string]string{
 				store.Default.LabelKeyAppManagedBy: "someone else",
-				store.Default.LabelKeyAppName:      "{{ appName }}",
+				store.Default.LabelKeyAppName:      "{{ .appName }}",
+			},
+		},
+		"Should convert the placeholders to go template params": {
+			labels: map[string]string{
+				"team": "{{ userGivenName }}-{{.destNamespace}}",
+			},
+			want: map[string]string{
+				"team":                             "{{ .userGivenName }}-{{.destNamespace}}",
+				store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
+				store.Default.LabelKeyAppName:      "{{ .appName }}",
 			},
 		},
 		"Should overwrite the default app name": {
@@ -686,3 +821,389 @@ func Test_getDefaultAppLabels(t *testing.T) {
 		})
 	}
 }
+
+func TestRunProjectUpdate(t *testing.T) {
+	tests := map[string]struct {
+		opts     *ProjectUpdateOptions
+		wantErr  string
+		beforeFn func(*gitmocks.MockRepository)
+		assertFn func(t *testing.T, repofs fs.FS)
+	}{
+		"should keep the current values when nothing is changed": {
+			opts: &ProjectUpdateOptions{},
+			beforeFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Updated project 'project'"}).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				proj, appSet, err := getProjectInfoFromFile(repofs, "projects/project.yaml")
+				assert.NoError(t, err)
+				assert.Equal(t, "https://kubernetes.default.svc", proj.Annotations[store.Default.DestServerAnnotation])
+				assert.Equal(t, "namespace", proj.Namespace)
+				assert.Equal(t, "payments", appSet.Spec.Template.Labels["team"])
+				assert.Equal(t, "1", appSet.Spec.Template.Annotations["argocd.argoproj.io/sync-wave"])
+			},
+		},
+		"should replace the labels and annotations": {
+			opts: &ProjectUpdateOptions{
+				Labels:      map[string]string{"team": "billing"},
+				Annotations: map[string]string{},
+			},
+			beforeFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				_, appSet, err := getProjectInfoFromFile(repofs, "projects/project.yaml")
+				assert.NoError(t, err)
+				assert.Equal(t, "billing", appSet.Spec.Template.Labels["team"])
+				assert.Equal(t, store.Default.LabelValueManagedBy, appSet.Spec.Template.Labels[store.Default.LabelKeyAppManagedBy])
+				assert.Empty(t, appSet.Spec.Template.Annotations)
+			},
+		},
+		"should change the default destination without moving the apps": {
+			opts: &ProjectUpdateOptions{
+				DestKubeServer: "https://prod.example.com",
+			},
+			beforeFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				proj, _, err := getProjectInfoFromFile(repofs, "projects/project.yaml")
+				assert.NoError(t, err)
+				assert.Equal(t, "https://prod.example.com", proj.Annotations[store.Default.DestServerAnnotation])
+
+				conf := &application.Config{}
+				assert.NoError(t, repofs.ReadJson("apps/app1/overlays/project/config.json", conf))
+				assert.Equal(t, "https://kubernetes.default.svc", conf.DestServer)
+			},
+		},
+		"should move the apps of the project with --update-apps": {
+			opts: &ProjectUpdateOptions{
+				DestKubeServer: "https://prod.example.com",
+				UpdateApps:     true,
+			},
+			beforeFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				conf := &application.Config{}
+				assert.NoError(t, repofs.ReadJson("apps/app1/overlays/project/config.json", conf))
+				assert.Equal(t, "https://prod.example.com", conf.DestServer)
+				assert.True(t, repofs.ExistsOrDie("bootstrap/cluster-resources/prod/app1-ns.yaml"))
+
+				assert.NoError(t, repofs.ReadJson("apps/app1/overlays/other/config.json", conf))
+				assert.Equal(t, "https://kubernetes.default.svc", conf.DestServer)
+			},
+		},
+		"should fail if the destination cluster is not configured": {
+			opts: &ProjectUpdateOptions{
+				DestKubeServer: "https://staging.example.com",
+			},
+			wantErr: util.Doc("cluster 'https://staging.example.com' is not configured yet, please execute `<BIN> cluster add` first"),
+		},
+	}
+	origPrepareRepo := prepareRepo
+	defer func() { prepareRepo = origPrepareRepo }()
+	for ttName, tt := range tests {
+		t.Run(ttName, func(t *testing.T) {
+			repofs := fs.Create(memfs.New())
+			projectYAML, appSetYAML, _, _, err := generateProjectManifests(&GenerateProjectOptions{
+				Name:              "project",
+				Namespace:         "namespace",
+				DefaultDestServer: "https://kubernetes.default.svc",
+				Labels:            map[string]string{"team": "payments"},
+				Annotations:       map[string]string{"argocd.argoproj.io/sync-wave": "1"},
+			})
+			assert.NoError(t, err)
+			assert.NoError(t, billyUtils.WriteFile(repofs, "projects/project.yaml", util.JoinManifests(projectYAML, appSetYAML), 0666))
+			assert.NoError(t, repofs.WriteJson("bootstrap/cluster-resources/in-cluster.json", &application.ClusterResConfig{Name: "in-cluster", Server: "https://kubernetes.default.svc"}))
+			assert.NoError(t, repofs.WriteJson("bootstrap/cluster-resources/prod.json", &application.ClusterResConfig{Name: "prod", Server: "https://prod.example.com"}))
+			assert.NoError(t, billyUtils.WriteFile(repofs, "bootstrap/cluster-resources/in-cluster/app1-ns.yaml", []byte("kind: Namespace"), 0666))
+			assert.NoError(t, repofs.WriteJson("apps/app1/overlays/project/config.json", &application.Config{AppName: "app1", DestNamespace: "app1", DestServer: "https://kubernetes.default.svc"}))
+			assert.NoError(t, repofs.WriteJson("apps/app1/overlays/other/config.json", &application.Config{AppName: "app1", DestNamespace: "app1", DestServer: "https://kubernetes.default.svc"}))
+
+			r := gitmocks.NewMockRepository(gomock.NewController(t))
+			if tt.beforeFn != nil {
+				tt.beforeFn(r)
+			}
+
+			prepareRepo = func(_ context.Context, _ *git.CloneOptions, _ string) (git.Repository, fs.FS, error) {
+				return r, repofs, nil
+			}
+
+			tt.opts.CloneOpts = &git.CloneOptions{}
+			tt.opts.ProjectName = "project"
+			err = RunProjectUpdate(context.Background(), tt.opts)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			tt.assertFn(t, repofs)
+		})
+	}
+}
+
+// projectTestRole is a role of "project" with policies and a token
+var projectTestRole = argocdv1alpha1.ProjectRole{
+	Name:      "ci",
+	Policies:  []string{"p, proj:project:ci, applications, sync, project/*, allow", "p, proj:project:ci, logs, get, *, allow"},
+	JWTTokens: []argocdv1alpha1.JWTToken{{IssuedAt: 1}},
+}
+
+// projectTestRepoWithWindow returns a projectTestRepo with a sync window on some of the apps of the project
+func projectTestRepoWithWindow(t *testing.T, roles ...argocdv1alpha1.ProjectRole) fs.FS {
+	repofs := projectTestRepo(t, roles...)
+	proj, appSet, err := getProjectInfoFromFile(repofs, "projects/project.yaml")
+	assert.NoError(t, err)
+	proj.Spec.SyncWindows = argocdv1alpha1.SyncWindows{{
+		Kind:         "deny",
+		Schedule:     "0 22 * * *",
+		Duration:     "1h",
+		Applications: []string{"project-*", "project-app1"},
+	}}
+	assert.NoError(t, writeProjectFile(repofs, "projects/project.yaml", proj, appSet))
+	return repofs
+}
+
+func TestRunProjectRename(t *testing.T) {
+	tests := map[string]struct {
+		keepResources bool
+		newName       string
+		wantErr       string
+		assertFn      func(t *testing.T, repofs fs.FS)
+	}{
+		"should rename the project and its apps": {
+			newName: "renamed",
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.False(t, repofs.ExistsOrDie("projects/project.yaml"))
+				proj, appSet, err := getProjectInfoFromFile(repofs, "projects/renamed.yaml")
+				assert.NoError(t, err)
+				assert.Equal(t, "renamed", proj.Name)
+				assert.Equal(t, []argocdv1alpha1.ProjectRole{{
+					Name:     "ci",
+					Policies: []string{"p, proj:renamed:ci, applications, sync, renamed/*, allow", "p, proj:renamed:ci, logs, get, *, allow"},
+				}}, proj.Spec.Roles)
+				assert.Equal(t, []string{"renamed-*", "renamed-app1"}, proj.Spec.SyncWindows[0].Applications)
+				assert.Equal(t, "renamed", appSet.Name)
+				assert.Equal(t, "renamed-{{ .userGivenName }}", appSet.Spec.Template.Name)
+				assert.Equal(t, "renamed", appSet.Spec.Template.Spec.Project)
+				assert.Equal(t, "apps/**/renamed/config.json", appSet.Spec.Generators[0].Git.Files[0].Path)
+
+				conf := &application.Config{}
+				assert.NoError(t, repofs.ReadJson("apps/app1/overlays/renamed/config.json", conf))
+				assert.Equal(t, "apps/app1/overlays/renamed", conf.SrcPath)
+				assert.True(t, repofs.ExistsOrDie("apps/app2/renamed/config_dir.json"))
+				assert.True(t, repofs.ExistsOrDie("apps/app2/other/config_dir.json"))
+			},
+		},
+		"should keep the old project with --keep-resources": {
+			newName:       "renamed",
+			keepResources: true,
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				proj, appSet, err := getProjectInfoFromFile(repofs, "projects/project.yaml")
+				assert.NoError(t, err)
+				assert.True(t, appSet.Spec.SyncPolicy.PreserveResourcesOnDeletion)
+				assert.Equal(t, projectTestRole, proj.Spec.Roles[0])
+				assert.Equal(t, []string{"project-*", "project-app1"}, proj.Spec.SyncWindows[0].Applications)
+
+				_, appSet, err = getProjectInfoFromFile(repofs, "projects/renamed.yaml")
+				assert.NoError(t, err)
+				assert.False(t, appSet.Spec.SyncPolicy.PreserveResourcesOnDeletion)
+				assert.False(t, repofs.ExistsOrDie("apps/app1/overlays/project"))
+			},
+		},
+		"should fail if the new project already exists": {
+			newName: "other",
+			wantErr: "project 'other' already exists",
+		},
+	}
+	for ttName, tt := range tests {
+		t.Run(ttName, func(t *testing.T) {
+			repofs := projectTestRepoWithWindow(t, projectTestRole)
+			assert.NoError(t, billyUtils.WriteFile(repofs, "projects/other.yaml", []byte{}, 0666))
+			assert.NoError(t, repofs.WriteJson("apps/app1/overlays/project/config.json", &application.Config{AppName: "app1", SrcPath: "apps/app1/overlays/project"}))
+			assert.NoError(t, repofs.WriteJson("apps/app2/project/config_dir.json", &application.Config{AppName: "app2", SrcPath: "manifests"}))
+			assert.NoError(t, repofs.WriteJson("apps/app2/other/config_dir.json", &application.Config{AppName: "app2", SrcPath: "manifests"}))
+
+			commitMsg := ""
+			if tt.wantErr == "" {
+				commitMsg = "Renamed project 'project' to 'renamed'"
+			}
+
+			err := runUpdateProjectTest(t, repofs, commitMsg, func() error {
+				return RunProjectRename(context.Background(), &ProjectRenameOptions{
+					CloneOpts:      &git.CloneOptions{},
+					ProjectName:    "project",
+					NewProjectName: tt.newName,
+					KeepResources:  tt.keepResources,
+				})
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			tt.assertFn(t, repofs)
+		})
+	}
+}
+
+func TestRunProjectClone(t *testing.T) {
+	tests := map[string]struct {
+		destServer string
+		newName    string
+		wantErr    string
+		assertFn   func(t *testing.T, repofs fs.FS)
+	}{
+		"should clone the project and its apps": {
+			newName: "cloned",
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				proj, appSet, err := getProjectInfoFromFile(repofs, "projects/cloned.yaml")
+				assert.NoError(t, err)
+				assert.Equal(t, "cloned", proj.Name)
+				assert.Equal(t, "https://kubernetes.default.svc", proj.Annotations[store.Default.DestServerAnnotation])
+				assert.Equal(t, []argocdv1alpha1.ProjectRole{{
+					Name:     "ci",
+					Policies: []string{"p, proj:cloned:ci, applications, sync, cloned/*, allow", "p, proj:cloned:ci, logs, get, *, allow"},
+				}}, proj.Spec.Roles)
+				assert.Equal(t, []string{"cloned-*", "cloned-app1"}, proj.Spec.SyncWindows[0].Applications)
+				assert.Equal(t, "cloned", appSet.Name)
+
+				proj, _, err = getProjectInfoFromFile(repofs, "projects/project.yaml")
+				assert.NoError(t, err)
+				assert.Equal(t, projectTestRole, proj.Spec.Roles[0])
+				assert.Equal(t, []string{"project-*", "project-app1"}, proj.Spec.SyncWindows[0].Applications)
+
+				data, err := repofs.ReadFile("apps/app1/overlays/cloned/patch.yaml")
+				assert.NoError(t, err)
+				assert.Equal(t, "kind: Deployment", string(data))
+				conf := &application.Config{}
+				assert.NoError(t, repofs.ReadJson("apps/app1/overlays/cloned/config.json", conf))
+				assert.Equal(t, "apps/app1/overlays/cloned", conf.SrcPath)
+				assert.Equal(t, "https://kubernetes.default.svc", conf.DestServer)
+				assert.True(t, repofs.ExistsOrDie("apps/app1/overlays/project/config.json"))
+			},
+		},
+		"should move the cloned apps to the new destination": {
+			newName:    "cloned",
+			destServer: "https://prod.example.com",
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				proj, _, err := getProjectInfoFromFile(repofs, "projects/cloned.yaml")
+				assert.NoError(t, err)
+				assert.Equal(t, "https://prod.example.com", proj.Annotations[store.Default.DestServerAnnotation])
+
+				conf := &application.Config{}
+				assert.NoError(t, repofs.ReadJson("apps/app1/overlays/cloned/config.json", conf))
+				assert.Equal(t, "https://prod.example.com", conf.DestServer)
+				assert.NoError(t, repofs.ReadJson("apps/app2/cloned/config_dir.json", conf))
+				assert.Equal(t, "https://prod.example.com", conf.DestServer)
+				assert.Equal(t, "manifests", conf.SrcPath)
+
+				assert.NoError(t, repofs.ReadJson("apps/app1/overlays/project/config.json", conf))
+				assert.Equal(t, "https://kubernetes.default.svc", conf.DestServer)
+			},
+		},
+		"should fail if the new project already exists": {
+			newName: "other",
+			wantErr: "project 'other' already exists",
+		},
+		"should fail if the destination cluster is not configured": {
+			newName:    "cloned",
+			destServer: "https://staging.example.com",
+			wantErr:    util.Doc("cluster 'https://staging.example.com' is not configured yet, please execute `<BIN> cluster add` first"),
+		},
+	}
+	for ttName, tt := range tests {
+		t.Run(ttName, func(t *testing.T) {
+			repofs := projectTestRepoWithWindow(t, projectTestRole)
+			assert.NoError(t, billyUtils.WriteFile(repofs, "projects/other.yaml", []byte{}, 0666))
+			assert.NoError(t, repofs.WriteJson("bootstrap/cluster-resources/in-cluster.json", &application.ClusterResConfig{Name: "in-cluster", Server: "https://kubernetes.default.svc"}))
+			assert.NoError(t, repofs.WriteJson("bootstrap/cluster-resources/prod.json", &application.ClusterResConfig{Name: "prod", Server: "https://prod.example.com"}))
+			assert.NoError(t, billyUtils.WriteFile(repofs, "apps/app1/overlays/project/patch.yaml", []byte("kind: Deployment"), 0666))
+			assert.NoError(t, repofs.WriteJson("apps/app1/overlays/project/config.json", &application.Config{AppName: "app1", SrcPath: "apps/app1/overlays/project", DestServer: "https://kubernetes.default.svc"}))
+			assert.NoError(t, repofs.WriteJson("apps/app2/project/config_dir.json", &application.Config{AppName: "app2", SrcPath: "manifests", DestServer: "https://kubernetes.default.svc"}))
+
+			commitMsg := ""
+			if tt.wantErr == "" {
+				commitMsg = "Cloned project 'project' to 'cloned'"
+			}
+
+			err := runUpdateProjectTest(t, repofs, commitMsg, func() error {
+				return RunProjectClone(context.Background(), &ProjectCloneOptions{
+					CloneOpts:      &git.CloneOptions{},
+					ProjectName:    "project",
+					NewProjectName: tt.newName,
+					DestKubeServer: tt.destServer,
+				})
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			tt.assertFn(t, repofs)
+		})
+	}
+}
+
+func Test_getProjectGenerator(t *testing.T) {
+	template := argocdv1alpha1.ApplicationSetTemplate{
+		Spec: argocdv1alpha1.ApplicationSpec{
+			Source: &argocdv1alpha1.ApplicationSource{Directory: &argocdv1alpha1.ApplicationSourceDirectory{Recurse: true}},
+		},
+	}
+	tests := map[string]struct {
+		clusterSelector map[string]string
+		assertFn        func(t *testing.T, got argocdv1alpha1.ApplicationSetGenerator)
+	}{
+		"should return a git generator": {
+			assertFn: func(t *testing.T, got argocdv1alpha1.ApplicationSetGenerator) {
+				assert.Nil(t, got.Matrix)
+				assert.Equal(t, "path/apps/**/project/config_dir.json", got.Git.Files[0].Path)
+				assert.Equal(t, template, got.Git.Template)
+			},
+		},
+		"should combine the git generator with a cluster generator": {
+			clusterSelector: map[string]string{"env": "prod"},
+			assertFn: func(t *testing.T, got argocdv1alpha1.ApplicationSetGenerator) {
+				assert.Nil(t, got.Git)
+				assert.Len(t, got.Matrix.Generators, 2)
+				assert.Equal(t, "path/apps/**/project/config_dir.json", got.Matrix.Generators[0].Git.Files[0].Path)
+				assert.Equal(t, argocdv1alpha1.ApplicationSetTemplate{}, got.Matrix.Generators[0].Git.Template)
+				assert.Equal(t, map[string]string{"env": "prod"}, got.Matrix.Generators[1].Clusters.Selector.MatchLabels)
+				assert.Equal(t, template, got.Matrix.Template)
+			},
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			got := getProjectGenerator(&GenerateProjectOptions{
+				Name:             "project",
+				RepoURL:          "repoUrl",
+				InstallationPath: "path",
+				ClusterSelector:  tt.clusterSelector,
+			}, "config_dir.json", template)
+			tt.assertFn(t, got)
+		})
+	}
+}
+
+func Test_generateProjectManifests_clusterSelector(t *testing.T) {
+	_, appSetYAML, _, _, err := generateProjectManifests(&GenerateProjectOptions{
+		Name:              "project",
+		Namespace:         "namespace",
+		DefaultDestServer: "https://kubernetes.default.svc",
+		ClusterSelector:   map[string]string{"env": "prod"},
+	})
+	assert.NoError(t, err)
+
+	appSet := &argocdv1alpha1.ApplicationSet{}
+	assert.NoError(t, yaml.Unmarshal(appSetYAML, appSet))
+	assert.Equal(t, "project-{{ .userGivenName }}-{{ .nameNormalized }}", appSet.Spec.Template.Name)
+	assert.Equal(t, "{{ .server }}", appSet.Spec.Template.Spec.Destination.Server)
+	assert.Equal(t, map[string]string{"env": "prod"}, getProjectClusterSelector(appSet))
+	assert.Equal(t, map[string]string{"env": "prod"}, getGenerateProjectOptions(&argocdv1alpha1.AppProject{}, appSet, &git.CloneOptions{}).ClusterSelector)
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/argoproj-labs/argocd-autopilot/pkg/application"
@@ -23,19 +27,31 @@ import (
 
 	argocdcommon "github.com/argoproj/argo-cd/v3/common"
 	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argocdcs "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned"
 	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
 	billyUtils "github.com/go-git/go-billy/v5/util"
 	"github.com/spf13/cobra"
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	kusttypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
 	"sigs.k8s.io/yaml"
 )
 
 const (
 	installationModeFlat   = "flat"
 	installationModeNormal = "normal"
+
+	repoCredsModePlain          = "plain"
+	repoCredsModeExternalSecret = "external-secret"
+	repoCredsModeSealedSecret   = "sealed-secret"
+
+	repoCredsFileName = "repo-creds.yaml"
+
+	vendoredManifestsFileName = "vendored-install.yaml"
 )
 
 // used for mocking
@@ -43,6 +59,7 @@ var (
 	argocdLogin        = argocd.Login
 	currentKubeContext = kube.CurrentContext
 	runKustomizeBuild  = application.GenerateManifests
+	getArgoCDClientSet = argocd.ClientSet
 )
 
 type (
@@ -53,6 +70,7 @@ type (
 		KubeConfig          string
 		KubeContextName     string
 		DryRun              bool
+		OutputDir           string
 		HidePassword        bool
 		Insecure            bool
 		Recover             bool
@@ -62,6 +80,24 @@ type (
 		ArgoCDLabels        map[string]string
 		BootstrapAppsLabels map[string]string
 		NamespaceLabels     map[string]string
+		ArgoCDConfigs       map[string][]string
+		ArgoCDPatches       []string
+		GenerateDeployKey   bool
+		Airgap              bool
+		ImageRegistryMirror string
+		RepoCreds           RepoCredsOptions
+	}
+
+	// RepoCredsOptions controls how the repository credentials secret is created
+	RepoCredsOptions struct {
+		// Mode is one of plain|external-secret|sealed-secret
+		Mode string
+		// SecretStore is the [<kind>/]<name> of the SecretStore, in external-secret mode
+		SecretStore string
+		// RemoteKey is the key of the git token (or ssh key) in the SecretStore
+		RemoteKey string
+		// SealingCert is the sealed-secrets controller public certificate file
+		SealingCert string
 	}
 
 	RepoUninstallOptions struct {
@@ -73,6 +109,9 @@ type (
 		Force           bool
 		ClusterOnly     bool
 		FastExit        bool
+		Plan            bool
+		OrphanWorkloads bool
+		Out             io.Writer
 	}
 
 	bootstrapManifests struct {
@@ -85,6 +124,9 @@ type (
 		applyManifests         []byte
 		bootstrapKustomization []byte
 		namespace              []byte
+		argocdPatches          map[string][]byte
+		commitRepoCreds        bool
+		vendoredManifests      []byte
 	}
 
 	deleteClusterResourcesOptions struct {
@@ -106,6 +148,11 @@ func NewRepoCommand() *cobra.Command {
 
 	cmd.AddCommand(NewRepoBootstrapCommand())
 	cmd.AddCommand(NewRepoUninstallCommand())
+	cmd.AddCommand(NewRepoUpgradeCommand())
+	cmd.AddCommand(NewRepoStatusCommand())
+	cmd.AddCommand(NewRepoDoctorCommand())
+	cmd.AddCommand(NewRepoMigrateModeCommand())
+	cmd.AddCommand(NewRepoAdoptCommand())
 
 	return cmd
 }
@@ -114,6 +161,7 @@ func NewRepoBootstrapCommand() *cobra.Command {
 	var (
 		appSpecifier     string
 		dryRun           bool
+		outputDir        string
 		hidePassword     bool
 		insecure         bool
 		recover          bool
@@ -121,6 +169,14 @@ func NewRepoBootstrapCommand() *cobra.Command {
 		cloneOpts        *git.CloneOptions
 		f                kube.Factory
 		namespaceLabels  map[string]string
+		argocdConfig     []string
+		argocdRBAC       []string
+		argocdCmdParams  []string
+		argocdPatches    []string
+		genDeployKey     bool
+		repoCreds        RepoCredsOptions
+		airgap           bool
+		registryMirror   string
 	)
 
 	cmd := &cobra.Command{
@@ -145,6 +201,30 @@ func NewRepoBootstrapCommand() *cobra.Command {
 # and persists the bootstrap manifests to a specific folder in the gitops repository
 
 	<BIN> repo bootstrap --repo https://github.com/example/repo/path/to/installation_root
+
+# Install argo-cd with additional configuration merged into the argocd-cm and argocd-rbac-cm
+# config maps. Each value is either a key=value pair, or a file whose name will be used as the key
+
+	<BIN> repo bootstrap --repo https://github.com/example/repo --argocd-config admin.enabled=false --argocd-rbac ./policy.csv
+
+# Install argo-cd with a strategic merge patch, and a JSON6902 patch that targets a specific resource
+
+	<BIN> repo bootstrap --repo https://github.com/example/repo --argocd-patch ./limits.yaml --argocd-patch Deployment/argocd-repo-server=./replicas.yaml
+
+# Install argo-cd with a newly generated ssh deploy key, so argo-cd and autopilot will access
+# the repository over ssh, and the git token is not stored in the cluster
+
+	<BIN> repo bootstrap --repo https://github.com/example/repo --git-ssh-key ~/.ssh/autopilot --generate-deploy-key
+
+# Write the files that would be committed to the gitops repository into a local directory,
+# without applying anything to the cluster or pushing anything to the repository
+
+	<BIN> repo bootstrap --repo https://github.com/example/repo --dry-run --output-dir ./out
+
+# Install argo-cd in a disconnected cluster. The remote manifests are fetched once and committed
+# to the repository, and all of the images are pulled from the registry mirror
+
+	<BIN> repo bootstrap --repo https://git.local/example/repo --airgap --image-registry-mirror registry.local
 `),
 		PreRun: func(_ *cobra.Command, _ []string) {
 			cloneOpts.Parse()
@@ -162,6 +242,7 @@ func NewRepoBootstrapCommand() *cobra.Command {
 				KubeConfig:       cmd.Flag("kubeconfig").Value.String(),
 				KubeContextName:  cmd.Flag("context").Value.String(),
 				DryRun:           dryRun,
+				OutputDir:        outputDir,
 				HidePassword:     hidePassword,
 				Insecure:         insecure,
 				Recover:          recover,
@@ -169,18 +250,41 @@ func NewRepoBootstrapCommand() *cobra.Command {
 				KubeFactory:      f,
 				CloneOptions:     cloneOpts,
 				NamespaceLabels:  namespaceLabels,
+				ArgoCDConfigs: map[string][]string{
+					argocdcommon.ArgoCDConfigMapName:          argocdConfig,
+					argocdcommon.ArgoCDRBACConfigMapName:      argocdRBAC,
+					argocdcommon.ArgoCDCmdParamsConfigMapName: argocdCmdParams,
+				},
+				ArgoCDPatches:       argocdPatches,
+				GenerateDeployKey:   genDeployKey,
+				RepoCreds:           repoCreds,
+				Airgap:              airgap,
+				ImageRegistryMirror: registryMirror,
 			})
 		},
 	}
 
 	cmd.Flags().StringVar(&appSpecifier, "app", "", "The application specifier (e.g. github.com/argoproj-labs/argocd-autopilot/manifests?ref=v0.2.5), overrides the default installation argo-cd manifests")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "If true, print manifests instead of applying them to the cluster (nothing will be commited to git)")
+	cmd.Flags().StringVar(&outputDir, "output-dir", "", "Used with --dry-run, writes the repository files that would be committed to this local directory, instead of printing the manifests")
 	cmd.Flags().BoolVar(&hidePassword, "hide-password", false, "If true, will not print initial argo cd password")
 	cmd.Flags().BoolVar(&recover, "recover", false, "Installs Argo-CD on a cluster without pushing installation manifests to the git repository. This is meant to be used together with --app flag to use the same Argo-CD manifests that exists in the git repository (e.g. --app https://github.com/git-user/repo-name/bootstrap/argo-cd)")
 	cmd.Flags().BoolVar(&insecure, "insecure", false, "Run Argo-CD server without TLS")
 	cmd.Flags().StringToStringVar(&namespaceLabels, "namespace-labels", nil, "Optional labels that will be set on the namespace resource. (e.g. \"key1=value1,key2=value2\"")
 	cmd.Flags().StringVar(&installationMode, "installation-mode", "normal", "One of: normal|flat. "+
 		"If flat, will commit the bootstrap manifests, otherwise will commit the bootstrap kustomization.yaml")
+	cmd.Flags().StringArrayVar(&argocdConfig, "argocd-config", nil, "A key=value pair or a file that will be merged into the argocd-cm config map (can be used multiple times)")
+	cmd.Flags().StringArrayVar(&argocdRBAC, "argocd-rbac", nil, "A key=value pair or a file that will be merged into the argocd-rbac-cm config map (can be used multiple times)")
+	cmd.Flags().StringArrayVar(&argocdCmdParams, "argocd-cmd-params", nil, "A key=value pair or a file that will be merged into the argocd-cmd-params-cm config map (can be used multiple times)")
+	cmd.Flags().StringArrayVar(&argocdPatches, "argocd-patch", nil, "A strategic merge patch file, or <kind>/<name>=<file> for a JSON6902 patch, that will be applied to the argo-cd installation (can be used multiple times)")
+	cmd.Flags().BoolVar(&genDeployKey, "generate-deploy-key", false, "If true, will generate a new ssh key pair at the --git-ssh-key path, and add its public key as a deploy key of the repository")
+	cmd.Flags().StringVar(&repoCreds.Mode, "repo-creds-mode", repoCredsModePlain, "One of: plain|external-secret|sealed-secret. "+
+		"If plain, will apply the repository credentials secret to the cluster, otherwise will also commit them to the repository as an ExternalSecret or a SealedSecret")
+	cmd.Flags().StringVar(&repoCreds.SecretStore, "repo-creds-secret-store", "", "The [<kind>/]<name> of the SecretStore (or ClusterSecretStore) that holds the git token, used in external-secret mode")
+	cmd.Flags().StringVar(&repoCreds.RemoteKey, "repo-creds-remote-key", "", "The key of the git token (or ssh private key) in the SecretStore, used in external-secret mode")
+	cmd.Flags().StringVar(&repoCreds.SealingCert, "sealed-secrets-cert", "", "A file with the public certificate of the sealed-secrets controller, used in sealed-secret mode")
+	cmd.Flags().BoolVar(&airgap, "airgap", false, "If true, will fetch the remote argo-cd manifests once and commit them to the repository, so the installation does not need access to any remote resources")
+	cmd.Flags().StringVar(&registryMirror, "image-registry-mirror", "", "Used with --airgap, the registry that all of the argo-cd images will be pulled from (e.g. registry.local)")
 
 	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
 		FS:               memfs.New(),
@@ -208,6 +312,14 @@ func RunRepoBootstrap(ctx context.Context, opts *RepoBootstrapOptions) error {
 		"kube-context": opts.KubeContextName,
 	}).Debug("starting with options: ")
 
+	if opts.GenerateDeployKey {
+		log.G(ctx).Infof("generating deploy key: %s", opts.CloneOptions.Auth.SSHKeyFile)
+		err = opts.CloneOptions.CreateDeployKey(ctx, store.Default.LabelValueManagedBy)
+		if err != nil {
+			return fmt.Errorf("failed to create deploy key: %w", err)
+		}
+	}
+
 	manifests, err := buildBootstrapManifests(
 		opts.Namespace,
 		opts.AppSpecifier,
@@ -215,12 +327,27 @@ func RunRepoBootstrap(ctx context.Context, opts *RepoBootstrapOptions) error {
 		opts.ArgoCDLabels,
 		opts.BootstrapAppsLabels,
 		opts.NamespaceLabels,
+		opts.ArgoCDConfigs,
+		opts.ArgoCDPatches,
+		&opts.RepoCreds,
+		opts.Airgap,
+		opts.ImageRegistryMirror,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to build bootstrap manifests: %w", err)
 	}
 
 	// Dry Run check
+	if opts.DryRun && opts.OutputDir != "" {
+		if err = writeManifestsToDir(opts.OutputDir, opts.CloneOptions.Path(), manifests, opts.InstallationMode, opts.Namespace); err != nil {
+			return err
+		}
+
+		log.G(ctx).Infof("bootstrap repository files written to: %s", opts.OutputDir)
+		exit(0)
+		return nil
+	}
+
 	if opts.DryRun {
 		fmt.Printf("%s", util.JoinManifests(
 			manifests.namespace,
@@ -250,6 +377,18 @@ func RunRepoBootstrap(ctx context.Context, opts *RepoBootstrapOptions) error {
 
 	log.G(ctx).Debug("repository is ok")
 
+	if opts.Recover {
+		repoCreds, err := readCommittedRepoCreds(repofs)
+		if err != nil {
+			return err
+		}
+
+		if repoCreds != nil {
+			log.G(ctx).Infof("using the repository credentials committed to the repository")
+			manifests.repoCreds = repoCreds
+		}
+	}
+
 	// apply built manifest to k8s cluster
 	log.G(ctx).Infof("using context: \"%s\", namespace: \"%s\"", opts.KubeContextName, opts.Namespace)
 	log.G(ctx).Infof("applying bootstrap manifests to cluster...")
@@ -267,7 +406,7 @@ func RunRepoBootstrap(ctx context.Context, opts *RepoBootstrapOptions) error {
 	// wait for argocd to be ready before applying argocd-apps
 	stop := util.WithSpinner(ctx, "waiting for argo-cd to be ready")
 
-	if err = waitClusterReady(ctx, opts.KubeFactory, opts.Timeout, opts.Namespace); err != nil {
+	if err = waitClusterReady(ctx, opts.KubeFactory, opts.Timeout, opts.Namespace, manifests.applyManifests); err != nil {
 		stop()
 		return err
 	}
@@ -322,10 +461,12 @@ func RunRepoBootstrap(ctx context.Context, opts *RepoBootstrapOptions) error {
 
 func NewRepoUninstallCommand() *cobra.Command {
 	var (
-		cloneOpts   *git.CloneOptions
-		f           kube.Factory
-		force       bool
-		clusterOnly bool
+		cloneOpts       *git.CloneOptions
+		f               kube.Factory
+		force           bool
+		clusterOnly     bool
+		plan            bool
+		orphanWorkloads bool
 	)
 
 	cmd := &cobra.Command{
@@ -356,6 +497,16 @@ func NewRepoUninstallCommand() *cobra.Command {
 # it will still attempt to delete argo-cd from the cluster. Use with caution!
 
 	<BIN> repo uninstall --repo https://github.com/example/repo --force
+
+# List the repository paths and the cluster resources that would be deleted,
+# without changing anything
+
+	<BIN> repo uninstall --repo https://github.com/example/repo --plan
+
+# Uninstall argo-cd and the autopilot resources, but keep all of the resources
+# that were deployed by the applications running in the cluster
+
+	<BIN> repo uninstall --repo https://github.com/example/repo --orphan-workloads
 `),
 		PreRunE: func(_ *cobra.Command, _ []string) error {
 			if !clusterOnly {
@@ -363,8 +514,12 @@ func NewRepoUninstallCommand() *cobra.Command {
 			}
 
 			if !clusterOnly {
-				if cloneOpts.Repo == "" || cloneOpts.Auth.Password == "" {
-					return fmt.Errorf("both --repo and --git-token flags are required")
+				if cloneOpts.Repo == "" {
+					return fmt.Errorf("the --repo flag is required")
+				}
+
+				if cloneOpts.Auth.Password == "" && cloneOpts.Auth.SSHKeyFile == "" {
+					return fmt.Errorf("one of the --git-token or --git-ssh-key flags is required")
 				}
 			}
 
@@ -384,12 +539,17 @@ func NewRepoUninstallCommand() *cobra.Command {
 				Force:           force,
 				ClusterOnly:     clusterOnly,
 				KubeFactory:     f,
+				Plan:            plan,
+				OrphanWorkloads: orphanWorkloads,
+				Out:             os.Stdout,
 			})
 		},
 	}
 
 	cmd.Flags().BoolVar(&force, "force", false, "If true, will try to complete the uninstallation even if one or more of the uninstallation steps failed")
 	cmd.Flags().BoolVar(&clusterOnly, "clusterOnly", false, "If true, will uninstall directly from cluster, without touching the git repository")
+	cmd.Flags().BoolVar(&plan, "plan", false, "If true, will only list the repository paths and the cluster resources that would be deleted, without deleting anything")
+	cmd.Flags().BoolVar(&orphanWorkloads, "orphan-workloads", false, "If true, will keep the resources deployed by the applications, and only remove argo-cd and the autopilot resources")
 
 	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
 		FS:            memfs.New(),
@@ -418,6 +578,8 @@ func RunRepoUninstall(ctx context.Context, opts *RepoUninstallOptions) error {
 		"revision":     opts.CloneOptions.Revision(),
 		"namespace":    opts.Namespace,
 		"kube-context": opts.KubeContextName,
+		"plan":         opts.Plan,
+		"orphan":       opts.OrphanWorkloads,
 	}).Debug("starting with options: ")
 
 	var revision string
@@ -431,7 +593,24 @@ func RunRepoUninstall(ctx context.Context, opts *RepoUninstallOptions) error {
 
 			log.G().Warnf("Continuing uninstall, even though failed getting repo: %v", err)
 		}
+	}
+
+	if opts.Plan {
+		return printUninstallPlan(ctx, opts, repofs)
+	}
 
+	if opts.OrphanWorkloads {
+		err = orphanWorkloads(ctx, opts, r, repofs)
+		if err != nil {
+			if !opts.Force {
+				return err
+			}
+
+			log.G().Warnf("Continuing uninstall, even though failed orphaning the applications workloads: %v", err)
+		}
+	}
+
+	if !opts.ClusterOnly {
 		if r != nil && repofs != nil {
 			revision, err = removeFromRepo(ctx, r, repofs)
 			if err != nil {
@@ -497,6 +676,44 @@ func setBootstrapOptsDefaults(opts RepoBootstrapOptions) (*RepoBootstrapOptions,
 		opts.InstallationMode = installationModeFlat
 	}
 
+	switch opts.RepoCreds.Mode {
+	case repoCredsModePlain, repoCredsModeExternalSecret, repoCredsModeSealedSecret:
+	case "":
+		opts.RepoCreds.Mode = repoCredsModePlain
+	default:
+		return nil, fmt.Errorf("unknown repo creds mode: %s", opts.RepoCreds.Mode)
+	}
+
+	if opts.RepoCreds.Mode == repoCredsModeExternalSecret && (opts.RepoCreds.SecretStore == "" || opts.RepoCreds.RemoteKey == "") {
+		return nil, fmt.Errorf("--repo-creds-secret-store and --repo-creds-remote-key are required in %s mode", repoCredsModeExternalSecret)
+	}
+
+	if opts.RepoCreds.Mode == repoCredsModeSealedSecret && opts.RepoCreds.SealingCert == "" {
+		return nil, fmt.Errorf("--sealed-secrets-cert is required in %s mode", repoCredsModeSealedSecret)
+	}
+
+	if opts.GenerateDeployKey {
+		if opts.CloneOptions.Auth.SSHKeyFile == "" {
+			return nil, fmt.Errorf("--generate-deploy-key requires the --git-ssh-key flag")
+		}
+
+		if opts.DryRun {
+			return nil, fmt.Errorf("--generate-deploy-key cannot be used together with --dry-run")
+		}
+
+		if opts.CloneOptions.Auth.Password == "" {
+			return nil, fmt.Errorf("--generate-deploy-key requires a git token, in order to add the deploy key to the repository")
+		}
+	}
+
+	if opts.ImageRegistryMirror != "" && !opts.Airgap {
+		return nil, fmt.Errorf("--image-registry-mirror can only be used together with --airgap")
+	}
+
+	if opts.OutputDir != "" && !opts.DryRun {
+		return nil, fmt.Errorf("--output-dir can only be used together with --dry-run")
+	}
+
 	return &opts, nil
 }
 
@@ -517,24 +734,170 @@ func validateRepo(repofs fs.FS, recover bool) error {
 	return nil
 }
 
-func waitClusterReady(ctx context.Context, f kube.Factory, timeout time.Duration, namespace string) error {
+func waitClusterReady(ctx context.Context, f kube.Factory, timeout time.Duration, namespace string, manifests []byte) error {
 	return f.Wait(ctx, &kube.WaitOptions{
-		Interval: store.Default.WaitInterval,
-		Timeout:  timeout,
-		Resources: []kube.Resource{
-			{
-				Name:      "argocd-server",
-				Namespace: namespace,
-				WaitFunc:  kube.WaitDeploymentReady,
-			},
-		},
+		Interval:  store.Default.WaitInterval,
+		Timeout:   timeout,
+		Resources: getArgoCDComponents(namespace, manifests),
 	})
 }
 
-func getRepoCredsSecret(username, token, namespace, repoURL string) ([]byte, error) {
+// getArgoCDComponents returns the argo-cd resources that must be ready before the first sync.
+// The CRDs, the repo-server and the application-controller are part of every installation, and
+// may not be created yet, so a missing one is waited for. Any other component is optional (e.g.
+// dex is not part of a core installation). If the manifests that are being applied are given, an
+// optional component is only waited for if they include it. Otherwise the installation is
+// already synced, and a missing optional component ends its wait
+func getArgoCDComponents(namespace string, manifests []byte) []kube.Resource {
+	resources := []kube.Resource{}
+	for _, crd := range []string{"applications.argoproj.io", "applicationsets.argoproj.io", "appprojects.argoproj.io"} {
+		resources = append(resources, kube.Resource{
+			Name:     crd,
+			WaitFunc: kube.WaitForCreation(kube.WaitCRDEstablished),
+		})
+	}
+
+	resources = append(resources,
+		kube.Resource{
+			Name:      "argocd-repo-server",
+			Namespace: namespace,
+			WaitFunc:  kube.WaitForCreation(kube.WaitDeploymentReady),
+		},
+		kube.Resource{
+			Name:      "argocd-application-controller",
+			Namespace: namespace,
+			WaitFunc:  kube.WaitForCreation(kube.WaitStatefulSetReady),
+		},
+	)
+
+	var included map[string]bool
+	if manifests != nil {
+		included = map[string]bool{}
+		for _, doc := range util.SplitManifests(manifests) {
+			obj := &metav1.PartialObjectMetadata{}
+			if err := yaml.Unmarshal(doc, obj); err == nil && obj.Kind == "Deployment" {
+				included[obj.Name] = true
+			}
+		}
+	}
+
+	// a high-availability installation runs redis behind the "argocd-redis-ha-haproxy" deployment
+	for _, deployment := range []string{"argocd-server", "argocd-applicationset-controller", "argocd-dex-server", "argocd-redis", "argocd-redis-ha-haproxy"} {
+		waitFunc := kube.WaitDeploymentReady
+		if included != nil {
+			if !included[deployment] {
+				continue
+			}
+
+			waitFunc = kube.WaitForCreation(kube.WaitDeploymentReady)
+		}
+
+		resources = append(resources, kube.Resource{
+			Name:      deployment,
+			Namespace: namespace,
+			WaitFunc:  waitFunc,
+		})
+	}
+
+	return resources
+}
+
+func getRepoCredsSecret(auth *git.Auth, namespace, repoURL string) ([]byte, error) {
+	secret, err := newRepoCredsSecret(auth, namespace, repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(secret)
+}
+
+// getRepoCredsManifest returns the manifest that provides the repository credentials to
+// argo-cd, according to the repo creds mode. In plain mode it is the secret itself
+func getRepoCredsManifest(auth *git.Auth, namespace, repoURL string, opts *RepoCredsOptions) ([]byte, error) {
+	if opts == nil || opts.Mode == "" || opts.Mode == repoCredsModePlain {
+		return getRepoCredsSecret(auth, namespace, repoURL)
+	}
+
+	secret, err := newRepoCredsSecret(auth, namespace, repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	secretKey := "password"
+	if auth.SSHKeyFile != "" {
+		secretKey = "sshPrivateKey"
+	}
+
+	return getSecretManifest(secret, secretKey, opts)
+}
+
+// getSecretManifest returns the manifest of secret according to the creds mode: the secret
+// itself, an ExternalSecret that reads secretKey from the SecretStore, or a SealedSecret
+func getSecretManifest(secret *v1.Secret, secretKey string, opts *RepoCredsOptions) ([]byte, error) {
+	switch opts.Mode {
+	case "", repoCredsModePlain:
+		return yaml.Marshal(secret)
+	case repoCredsModeExternalSecret:
+		secretStore := kube.SecretStoreRef{Kind: "SecretStore", Name: opts.SecretStore}
+		if kind, name, found := strings.Cut(opts.SecretStore, "/"); found {
+			secretStore = kube.SecretStoreRef{Kind: kind, Name: name}
+		}
+
+		return yaml.Marshal(kube.GenerateExternalSecret(secret, secretStore, map[string]string{secretKey: opts.RemoteKey}))
+	case repoCredsModeSealedSecret:
+		cert, err := os.ReadFile(opts.SealingCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sealed-secrets certificate '%s': %w", opts.SealingCert, err)
+		}
+
+		sealed, err := kube.SealSecret(secret, cert)
+		if err != nil {
+			return nil, err
+		}
+
+		return yaml.Marshal(sealed)
+	default:
+		return nil, fmt.Errorf("unknown repo creds mode: %s", opts.Mode)
+	}
+}
+
+// readCommittedRepoCreds returns the repository credentials manifest that was committed
+// to the bootstrap dir, or nil if the credentials are not kept in the repository
+func readCommittedRepoCreds(repofs fs.FS) ([]byte, error) {
+	repoCredsPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, repoCredsFileName)
+	if !repofs.ExistsOrDie(repoCredsPath) {
+		return nil, nil
+	}
+
+	data, err := billyUtils.ReadFile(repofs, repoCredsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", repoCredsPath, err)
+	}
+
+	return data, nil
+}
+
+func newRepoCredsSecret(auth *git.Auth, namespace, repoURL string) (*v1.Secret, error) {
 	host, _, _, _, _, _, _ := util.ParseGitUrl(repoURL)
+	sshPrivateKey, err := auth.GetSSHPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed reading git ssh key file: %w", err)
+	}
 
-	return yaml.Marshal(&v1.Secret{
+	stringData := map[string]string{
+		"type": "git",
+		"url":  host,
+	}
+
+	if sshPrivateKey != nil {
+		// the git token is not stored in the cluster when using an ssh key
+		stringData["sshPrivateKey"] = string(sshPrivateKey)
+	} else {
+		stringData["username"] = auth.Username
+		stringData["password"] = auth.Password
+	}
+
+	return &v1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
 			Kind:       "Secret",
@@ -547,14 +910,9 @@ func getRepoCredsSecret(username, token, namespace, repoURL string) ([]byte, err
 				store.Default.LabelKeyAppManagedBy: store.Default.LabelValueManagedBy,
 			},
 		},
-		Type: v1.SecretTypeOpaque,
-		StringData: map[string]string{
-			"type":     "git",
-			"url":      host,
-			"username": username,
-			"password": token,
-		},
-	})
+		Type:       v1.SecretTypeOpaque,
+		StringData: stringData,
+	}, nil
 }
 
 func getInitialPassword(ctx context.Context, f kube.Factory, namespace string) (string, error) {
@@ -580,7 +938,7 @@ func getBootstrapAppSpecifier(insecure bool) string {
 	return store.Get().InstallationManifestsURL
 }
 
-func buildBootstrapManifests(namespace, appSpecifier string, cloneOpts *git.CloneOptions, argocdLabels map[string]string, bootstrapAppsLabels map[string]string, namespaceLabels map[string]string) (*bootstrapManifests, error) {
+func buildBootstrapManifests(namespace, appSpecifier string, cloneOpts *git.CloneOptions, argocdLabels map[string]string, bootstrapAppsLabels map[string]string, namespaceLabels map[string]string, argocdConfigs map[string][]string, argocdPatches []string, repoCreds *RepoCredsOptions, airgap bool, imageRegistryMirror string) (*bootstrapManifests, error) {
 	var err error
 	manifests := &bootstrapManifests{}
 
@@ -662,6 +1020,22 @@ func buildBootstrapManifests(namespace, appSpecifier string, cloneOpts *git.Clon
 		return nil, err
 	}
 
+	if airgap {
+		manifests.vendoredManifests, err = vendorArgoCDManifests(k, imageRegistryMirror)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = addArgoCDConfigs(k, argocdConfigs); err != nil {
+		return nil, err
+	}
+
+	manifests.argocdPatches, err = addArgoCDPatches(k, argocdPatches)
+	if err != nil {
+		return nil, err
+	}
+
 	if namespace != "" && namespace != "default" {
 		ns := kube.GenerateNamespace(namespace, namespaceLabels)
 		manifests.namespace, err = yaml.Marshal(ns)
@@ -670,16 +1044,32 @@ func buildBootstrapManifests(namespace, appSpecifier string, cloneOpts *git.Clon
 		}
 	}
 
-	manifests.applyManifests, err = runKustomizeBuild(k)
+	// the patch files and the vendored manifests are committed next to the kustomization.yaml
+	files := map[string][]byte{}
+	for name, data := range manifests.argocdPatches {
+		files[name] = data
+	}
+
+	if airgap {
+		files[vendoredManifestsFileName] = manifests.vendoredManifests
+	}
+
+	manifests.applyManifests, err = runKustomizeBuild(k, files)
 	if err != nil {
 		return nil, err
 	}
 
-	manifests.repoCreds, err = getRepoCredsSecret(cloneOpts.Auth.Username, cloneOpts.Auth.Password, namespace, cloneOpts.URL())
+	manifests.repoCreds, err = getRepoCredsManifest(&cloneOpts.Auth, namespace, cloneOpts.URL(), repoCreds)
 	if err != nil {
 		return nil, err
 	}
 
+	if repoCreds != nil && repoCreds.Mode != "" && repoCreds.Mode != repoCredsModePlain {
+		// the repo creds are committed next to the kustomization.yaml, and synced by the argo-cd app
+		manifests.commitRepoCreds = true
+		k.Resources = append(k.Resources, repoCredsFileName)
+	}
+
 	manifests.bootstrapKustomization, err = yaml.Marshal(k)
 	if err != nil {
 		return nil, err
@@ -697,6 +1087,14 @@ func writeManifestsToRepo(repoFS fs.FS, manifests *bootstrapManifests, installat
 		bulkWrites = []fsutils.BulkWriteRequest{
 			{Filename: repoFS.Join(argocdPath, "kustomization.yaml"), Data: manifests.bootstrapKustomization},
 		}
+
+		if manifests.vendoredManifests != nil {
+			bulkWrites = append(bulkWrites, fsutils.BulkWriteRequest{Filename: repoFS.Join(argocdPath, vendoredManifestsFileName), Data: manifests.vendoredManifests})
+		}
+
+		for name, data := range manifests.argocdPatches {
+			bulkWrites = append(bulkWrites, fsutils.BulkWriteRequest{Filename: repoFS.Join(argocdPath, name), Data: data})
+		}
 	} else {
 		bulkWrites = []fsutils.BulkWriteRequest{
 			{Filename: repoFS.Join(argocdPath, "install.yaml"), Data: manifests.applyManifests},
@@ -713,6 +1111,11 @@ func writeManifestsToRepo(repoFS fs.FS, manifests *bootstrapManifests, installat
 		{Filename: repoFS.Join(store.Default.AppsDir, "README.md"), Data: appsReadme},                                                                                       // write ./apps/README.md
 	}...)
 
+	if manifests.commitRepoCreds {
+		// write ./bootstrap/argo-cd/repo-creds.yaml
+		bulkWrites = append(bulkWrites, fsutils.BulkWriteRequest{Filename: repoFS.Join(argocdPath, repoCredsFileName), Data: manifests.repoCreds})
+	}
+
 	if manifests.namespace != nil {
 		// write ./bootstrap/cluster-resources/in-cluster/...-ns.yaml
 		bulkWrites = append(
@@ -724,6 +1127,26 @@ func writeManifestsToRepo(repoFS fs.FS, manifests *bootstrapManifests, installat
 	return fsutils.BulkWrite(repoFS, bulkWrites...)
 }
 
+// writeManifestsToDir writes the bootstrap files into a local directory, in the same layout
+// they would have in the repository, including the installation path
+func writeManifestsToDir(dir, installationPath string, manifests *bootstrapManifests, installationMode, namespace string) error {
+	bfs, err := osfs.New(dir).Chroot(installationPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	repofs := fs.Create(bfs)
+	if err = validateRepo(repofs, false); err != nil {
+		return err
+	}
+
+	if err = writeManifestsToRepo(repofs, manifests, installationMode, namespace); err != nil {
+		return fmt.Errorf("failed to write manifests to output directory: %w", err)
+	}
+
+	return nil
+}
+
 func createBootstrapKustomization(namespace, appSpecifier string, cloneOpts *git.CloneOptions) (*kusttypes.Kustomization, error) {
 	k := &kusttypes.Kustomization{
 		Resources: []string{
@@ -741,7 +1164,7 @@ func createBootstrapKustomization(namespace, appSpecifier string, cloneOpts *git
 		return nil, err
 	}
 
-	if cert != nil {
+	if cert != nil && cloneOpts.Auth.SSHKeyFile == "" {
 		u, err := url.Parse(cloneOpts.URL())
 		if err != nil {
 			return nil, err
@@ -776,6 +1199,221 @@ func createBootstrapKustomization(namespace, appSpecifier string, cloneOpts *git
 	return k, nil
 }
 
+// vendorArgoCDManifests builds the (remote) argo-cd resources of k once, and replaces them with the
+// vendored manifests file, that is committed next to the kustomization.yaml. If imageRegistryMirror
+// is set, every image in the result is changed to be pulled from the mirror. Returns the vendored
+// manifests
+func vendorArgoCDManifests(k *kusttypes.Kustomization, imageRegistryMirror string) ([]byte, error) {
+	vendored, err := runKustomizeBuild(&kusttypes.Kustomization{
+		TypeMeta:  k.TypeMeta,
+		Resources: k.Resources,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch argo-cd manifests: %w", err)
+	}
+
+	k.Resources = []string{vendoredManifestsFileName}
+	if imageRegistryMirror != "" {
+		k.Images, err = application.GenerateImageMirrors(vendored, imageRegistryMirror)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return vendored, nil
+}
+
+// addArgoCDConfigs merges the entries of each config map in configs into the matching argo-cd
+// config map, using a configMapGenerator. An entry is either a key=value pair, or a path to a
+// file, in which case the file name is used as the key and the file content as the value.
+func addArgoCDConfigs(k *kusttypes.Kustomization, configs map[string][]string) error {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		if len(configs[name]) == 0 {
+			continue
+		}
+
+		literals, err := getConfigMapLiterals(configs[name])
+		if err != nil {
+			return fmt.Errorf("failed to get '%s' config: %w", name, err)
+		}
+
+		k.ConfigMapGenerator = append(k.ConfigMapGenerator, kusttypes.ConfigMapArgs{
+			GeneratorArgs: kusttypes.GeneratorArgs{
+				Name:     name,
+				Behavior: kusttypes.BehaviorMerge.String(),
+				KvPairSources: kusttypes.KvPairSources{
+					LiteralSources: literals,
+				},
+			},
+		})
+	}
+
+	return nil
+}
+
+// addArgoCDCustomizations adds the data of the argo-cd config maps, and the image registry mirror,
+// of the current (flat) argo-cd manifests to k, so they are kept when k is built from a new
+// specifier. Returns the upstream manifests of k, without any customization.
+func addArgoCDCustomizations(k *kusttypes.Kustomization, current []byte) ([]byte, error) {
+	configs, err := getArgoCDConfigs(current)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = addArgoCDConfigs(k, configs); err != nil {
+		return nil, err
+	}
+
+	upstream, err := runKustomizeBuild(&kusttypes.Kustomization{
+		TypeMeta:  k.TypeMeta,
+		Resources: k.Resources,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch argo-cd manifests: %w", err)
+	}
+
+	mirror, err := application.DetectImageRegistryMirror(current, upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	if mirror != "" {
+		k.Images, err = application.GenerateImageMirrors(upstream, mirror)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return upstream, nil
+}
+
+// getArgoCDConfigs returns the data of the argo-cd config maps in manifests, as key=value entries
+// that can be merged into a new installation with addArgoCDConfigs
+func getArgoCDConfigs(manifests []byte) (map[string][]string, error) {
+	configs := map[string][]string{}
+	for _, manifest := range util.SplitManifests(manifests) {
+		meta := &metav1.PartialObjectMetadata{}
+		if err := yaml.Unmarshal(manifest, meta); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+		}
+
+		switch meta.Name {
+		case argocdcommon.ArgoCDConfigMapName, argocdcommon.ArgoCDRBACConfigMapName, argocdcommon.ArgoCDCmdParamsConfigMapName:
+		default:
+			continue
+		}
+
+		cm := &v1.ConfigMap{}
+		if meta.Kind != "ConfigMap" || yaml.Unmarshal(manifest, cm) != nil {
+			continue
+		}
+
+		keys := make([]string, 0, len(cm.Data))
+		for key := range cm.Data {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		for _, key := range keys {
+			configs[cm.Name] = append(configs[cm.Name], key+"="+cm.Data[key])
+		}
+	}
+
+	return configs, nil
+}
+
+// addArgoCDPatches adds a patch to k for each of the entries in patches, and returns the content
+// of the patch files by their file name. An entry is either a path to a strategic merge patch
+// file, or <kind>/<name>=<file> for a JSON6902 patch that targets a specific resource.
+func addArgoCDPatches(k *kusttypes.Kustomization, patches []string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, entry := range patches {
+		var target *kusttypes.Selector
+		path := entry
+		if t, p, ok := strings.Cut(entry, "="); ok {
+			kind, name, ok := strings.Cut(t, "/")
+			if !ok || kind == "" || name == "" {
+				return nil, fmt.Errorf("invalid patch target '%s', expected <kind>/<name>", t)
+			}
+
+			target = &kusttypes.Selector{ResId: resid.ResId{Gvk: resid.Gvk{Kind: kind}, Name: name}}
+			path = p
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read patch file '%s': %w", path, err)
+		}
+
+		name := filepath.Base(path)
+		if name == "kustomization.yaml" || name == "install.yaml" || name == vendoredManifestsFileName || name == repoCredsFileName {
+			return nil, fmt.Errorf("invalid patch file name '%s'", name)
+		}
+
+		if _, exists := files[name]; exists {
+			return nil, fmt.Errorf("duplicate patch file name '%s'", name)
+		}
+
+		files[name] = data
+		k.Patches = append(k.Patches, kusttypes.Patch{Path: name, Target: target})
+	}
+
+	return files, nil
+}
+
+func getConfigMapLiterals(entries []string) ([]string, error) {
+	literals := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if strings.Contains(entry, "=") {
+			if strings.HasPrefix(entry, "=") {
+				return nil, fmt.Errorf("missing key in '%s'", entry)
+			}
+
+			literals = append(literals, entry)
+			continue
+		}
+
+		data, err := os.ReadFile(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file '%s': %w", entry, err)
+		}
+
+		literals = append(literals, filepath.Base(entry)+"="+string(data))
+	}
+
+	return literals, nil
+}
+
+// readKustomizationFiles returns the content of the files in dir, other than the kustomization.yaml,
+// so the kustomization in dir can be built by runKustomizeBuild from the repo, and not from the
+// local filesystem
+func readKustomizationFiles(repofs fs.FS, dir string) (map[string][]byte, error) {
+	infos, err := repofs.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", dir, err)
+	}
+
+	files := map[string][]byte{}
+	for _, fi := range infos {
+		if fi.IsDir() || fi.Name() == "kustomization.yaml" {
+			continue
+		}
+
+		files[fi.Name()], err = repofs.ReadFile(repofs.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
 func setUninstallOptsDefaults(opts RepoUninstallOptions) (*RepoUninstallOptions, error) {
 	var err error
 
@@ -886,15 +1524,17 @@ func waitForBootstrapAppDelete(ctx context.Context, opts *RepoUninstallOptions)
 	return err
 }
 
-func deleteClusterResources(ctx context.Context, opts *deleteClusterResourcesOptions) error {
-	labelSelectors := []string{
+func getUninstallLabelSelectors() []string {
+	return []string{
 		store.Default.LabelKeyAppManagedBy + "=" + store.Default.LabelValueManagedBy,
 		argocdcommon.LabelKeyAppInstance + "=" + store.Default.ArgoCDName,
 		store.Default.LabelKeyAppPartOf + "=" + store.Default.ArgoCDNamespace,
 		store.Default.LabelKeyAppPartOf + "=" + store.Default.ArgoCDApplicationSet,
 	}
+}
 
-	for _, labelSelector := range labelSelectors {
+func deleteClusterResources(ctx context.Context, opts *deleteClusterResourcesOptions) error {
+	for _, labelSelector := range getUninstallLabelSelectors() {
 		if err := opts.KubeFactory.Delete(ctx, &kube.DeleteOptions{
 			LabelSelector:   labelSelector,
 			ResourceTypes:   []string{"applications", "secrets"},
@@ -924,6 +1564,266 @@ func deleteClusterResources(ctx context.Context, opts *deleteClusterResourcesOpt
 	return nil
 }
 
+// orphanWorkloads makes sure that deleting the applications will not delete the resources they
+// deployed. The ApplicationSets are changed in the repository first, so argo-cd will not revert
+// them, and then the finalizer is removed from every live Application. Without the repository,
+// automated sync is disabled on the bootstrap and root apps instead, before the live
+// ApplicationSets are patched
+func orphanWorkloads(ctx context.Context, opts *RepoUninstallOptions, r git.Repository, repofs fs.FS) error {
+	cs, err := getArgoCDClientSet(opts.KubeFactory)
+	if err != nil {
+		return fmt.Errorf("failed to create argo-cd clientset: %w", err)
+	}
+
+	if r == nil || repofs == nil {
+		for _, name := range []string{store.Default.BootsrtrapAppName, store.Default.RootAppName} {
+			if err = disableAutoSync(ctx, cs, opts.Namespace, name); err != nil {
+				return err
+			}
+		}
+	} else {
+		changed, err := preserveAppSetsResources(repofs)
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			log.G(ctx).Info("pushing orphan workloads changes to remote")
+			revision, err := r.Persist(ctx, &git.PushOptions{CommitMsg: "Autopilot Uninstall, orphan workloads"})
+			if err != nil {
+				return err
+			}
+
+			if err = waitForBootstrapAppSync(ctx, opts, revision); err != nil {
+				return err
+			}
+		}
+	}
+
+	appSets, err := cs.ArgoprojV1alpha1().ApplicationSets(opts.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list applicationsets in namespace '%s': %w", opts.Namespace, err)
+	}
+
+	preservePatch := []byte(`{"spec":{"syncPolicy":{"preserveResourcesOnDeletion":true}}}`)
+	for _, appSet := range appSets.Items {
+		if appSet.Spec.SyncPolicy != nil && appSet.Spec.SyncPolicy.PreserveResourcesOnDeletion {
+			continue
+		}
+
+		log.G(ctx).Debugf("preserving resources of applicationset '%s'", appSet.Name)
+		_, err = cs.ArgoprojV1alpha1().ApplicationSets(opts.Namespace).Patch(ctx, appSet.Name, types.MergePatchType, preservePatch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to patch applicationset '%s': %w", appSet.Name, err)
+		}
+	}
+
+	apps, err := cs.ArgoprojV1alpha1().Applications(opts.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list applications in namespace '%s': %w", opts.Namespace, err)
+	}
+
+	for _, app := range apps.Items {
+		// the root app only deploys the AppProjects and ApplicationSets, which should be
+		// deleted together with it
+		if app.Name == store.Default.RootAppName {
+			continue
+		}
+
+		if err = removeResourcesFinalizer(ctx, cs, &app); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// disableAutoSync removes the automated sync policy of a live Application, so argo-cd will not
+// revert changes to the resources it manages. A missing Application is ignored
+func disableAutoSync(ctx context.Context, cs argocdcs.Interface, namespace, name string) error {
+	log.G(ctx).Debugf("disabling automated sync of application '%s'", name)
+	patch := []byte(`{"spec":{"syncPolicy":{"automated":null}}}`)
+	_, err := cs.ArgoprojV1alpha1().Applications(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to disable automated sync of application '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+// removeResourcesFinalizer removes the resources finalizer from app, so deleting it does not
+// delete any of its resources
+func removeResourcesFinalizer(ctx context.Context, cs argocdcs.Interface, app *argocdv1alpha1.Application) error {
+	finalizers := []string{}
+	for _, finalizer := range app.Finalizers {
+		if !strings.HasPrefix(finalizer, argocdv1alpha1.ResourcesFinalizerName) {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+
+	if len(finalizers) == len(app.Finalizers) {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers": finalizers,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	log.G(ctx).Debugf("removing resources finalizer from application '%s'", app.Name)
+	_, err = cs.ArgoprojV1alpha1().Applications(app.Namespace).Patch(ctx, app.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch application '%s': %w", app.Name, err)
+	}
+
+	return nil
+}
+
+// preserveAppSetsResources sets preserveResourcesOnDeletion on every project ApplicationSet in
+// the repository. Returns true if any of the project files was changed
+func preserveAppSetsResources(repofs fs.FS) (bool, error) {
+	matches, err := billyUtils.Glob(repofs, repofs.Join(store.Default.ProjectsDir, "*.yaml"))
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, name := range matches {
+		proj, appSet, err := getProjectInfoFromFile(repofs, name)
+		if err != nil {
+			return false, fmt.Errorf("failed to read project file '%s': %w", name, err)
+		}
+
+		if appSet.Spec.SyncPolicy == nil {
+			appSet.Spec.SyncPolicy = &argocdv1alpha1.ApplicationSetSyncPolicy{}
+		}
+
+		if appSet.Spec.SyncPolicy.PreserveResourcesOnDeletion {
+			continue
+		}
+
+		appSet.Spec.SyncPolicy.PreserveResourcesOnDeletion = true
+		if err = repofs.WriteYamls(name, proj, appSet); err != nil {
+			return false, fmt.Errorf("failed to write project file '%s': %w", name, err)
+		}
+
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// printUninstallPlan writes the repository paths that uninstall would delete, and the live
+// objects that match each of the uninstall label selectors, without changing anything
+func printUninstallPlan(ctx context.Context, opts *RepoUninstallOptions, repofs fs.FS) error {
+	if repofs != nil {
+		paths, err := getGitOpsFiles(repofs)
+		if err != nil {
+			return fmt.Errorf("failed listing repository files: %w", err)
+		}
+
+		_, _ = fmt.Fprintf(opts.Out, "Repository paths to delete (%s):\n", opts.CloneOptions.URL())
+		if len(paths) == 0 {
+			_, _ = fmt.Fprintln(opts.Out, "  <none>")
+		}
+
+		for _, path := range paths {
+			_, _ = fmt.Fprintf(opts.Out, "  %s\n", path)
+		}
+
+		_, _ = fmt.Fprintln(opts.Out)
+	}
+
+	for _, labelSelector := range getUninstallLabelSelectors() {
+		refs, err := opts.KubeFactory.List(ctx, &kube.ListOptions{
+			LabelSelector: labelSelector,
+			ResourceTypes: []string{
+				"applications",
+				"all",
+				"configmaps",
+				"secrets",
+				"serviceaccounts",
+				"networkpolicies",
+				"rolebindings",
+				"roles",
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed listing resources with label selector '%s': %w", labelSelector, err)
+		}
+
+		sort.Slice(refs, func(i, j int) bool {
+			if refs[i].Kind != refs[j].Kind {
+				return refs[i].Kind < refs[j].Kind
+			}
+
+			if refs[i].Namespace != refs[j].Namespace {
+				return refs[i].Namespace < refs[j].Namespace
+			}
+
+			return refs[i].Name < refs[j].Name
+		})
+
+		_, _ = fmt.Fprintf(opts.Out, "Cluster resources to delete (%s, context: %s):\n", labelSelector, opts.KubeContextName)
+		if len(refs) == 0 {
+			_, _ = fmt.Fprintln(opts.Out, "  <none>")
+			_, _ = fmt.Fprintln(opts.Out)
+			continue
+		}
+
+		w := tabwriter.NewWriter(opts.Out, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(w, "  KIND\tNAMESPACE\tNAME\t\n")
+		for _, ref := range refs {
+			namespace := ref.Namespace
+			if namespace == "" {
+				namespace = "-"
+			}
+
+			_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\t\n", ref.Kind, namespace, ref.Name)
+		}
+
+		if err = w.Flush(); err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintln(opts.Out)
+	}
+
+	return nil
+}
+
+// getGitOpsFiles returns all of the files that deleteGitOpsFiles would delete, sorted
+func getGitOpsFiles(repofs fs.FS) ([]string, error) {
+	paths := []string{}
+	for _, dir := range []string{store.Default.AppsDir, store.Default.BootsrtrapDir, store.Default.ProjectsDir} {
+		err := billyUtils.Walk(repofs, dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+
+				return err
+			}
+
+			if !info.IsDir() {
+				paths = append(paths, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
 func removeLeftoversFromRepo(ctx context.Context, r git.Repository, repofs fs.FS) error {
 	log.G(ctx).Debug("Deleting leftovers from repo")
 	err := billyUtils.RemoveAll(repofs, store.Default.BootsrtrapDir)
@@ -0,0 +1,211 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type (
+	RepoAdoptOptions struct {
+		Namespace       string
+		KubeContextName string
+		KubeFactory     kube.Factory
+		CloneOptions    *git.CloneOptions
+	}
+)
+
+func NewRepoAdoptCommand() *cobra.Command {
+	var (
+		cloneOpts *git.CloneOptions
+		f         kube.Factory
+	)
+
+	cmd := &cobra.Command{
+		Use:   "adopt",
+		Short: "Manage an existing Argo-CD installation with autopilot, without reinstalling it",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider
+# and provide it using:
+
+    export GIT_TOKEN=<token>
+
+# or with the flag:
+
+    --git-token <token>
+
+# Adopt the argo-cd installation in the argocd namespace of the current kubernetes context,
+# and persist the bootstrap manifests to the root of gitops repository
+
+	<BIN> repo adopt --repo https://github.com/example/repo
+
+# Adopt the argo-cd installation in a different namespace
+
+	<BIN> repo adopt --repo https://github.com/example/repo --namespace gitops
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return RunRepoAdopt(cmd.Context(), &RepoAdoptOptions{
+				Namespace:       cmd.Flag("namespace").Value.String(),
+				KubeContextName: cmd.Flag("context").Value.String(),
+				KubeFactory:     f,
+				CloneOptions:    cloneOpts,
+			})
+		},
+	}
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:               memfs.New(),
+		CreateIfNotExist: true,
+		CloneForWrite:    true,
+	})
+	f = kube.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+// RunRepoAdopt commits a snapshot of the live argo-cd installation, together with the rest of
+// the bootstrap layout, and applies only the bootstrap application. The live argo-cd is not
+// reinstalled, and from then on it is managed from the repository
+func RunRepoAdopt(ctx context.Context, opts *RepoAdoptOptions) error {
+	var err error
+
+	if opts, err = setAdoptOptsDefaults(*opts); err != nil {
+		return err
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"repo-url":     opts.CloneOptions.URL(),
+		"revision":     opts.CloneOptions.Revision(),
+		"namespace":    opts.Namespace,
+		"kube-context": opts.KubeContextName,
+	}).Debug("starting with options: ")
+
+	cs, err := opts.KubeFactory.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+
+	if _, err = cs.AppsV1().Deployments(opts.Namespace).Get(ctx, "argocd-server", metav1.GetOptions{}); err != nil {
+		if kerrors.IsNotFound(err) {
+			return fmt.Errorf("argo-cd was not found in namespace '%s', use `repo bootstrap` to install it", opts.Namespace)
+		}
+
+		return fmt.Errorf("failed to get argo-cd server: %w", err)
+	}
+
+	log.G(ctx).Infof("exporting the live argo-cd manifests from namespace: %s", opts.Namespace)
+	snapshot, err := opts.KubeFactory.Export(ctx, &kube.ListOptions{
+		Namespace:     opts.Namespace,
+		LabelSelector: store.Default.LabelKeyAppPartOf + "=" + store.Default.ArgoCDNamespace,
+		// secrets are not exported, so they are never committed to the repository
+		ResourceTypes: []string{
+			"customresourcedefinitions",
+			"clusterroles",
+			"clusterrolebindings",
+			"serviceaccounts",
+			"roles",
+			"rolebindings",
+			"configmaps",
+			"services",
+			"deployments",
+			"statefulsets",
+			"networkpolicies",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export argo-cd manifests: %w", err)
+	}
+
+	if len(snapshot) == 0 {
+		return fmt.Errorf("no argo-cd resources were found in namespace '%s'", opts.Namespace)
+	}
+
+	log.G(ctx).Infof("cloning repo: %s", opts.CloneOptions.URL())
+	r, repofs, err := getRepo(ctx, opts.CloneOptions)
+	if err != nil {
+		return err
+	}
+
+	if err = validateRepo(repofs, false); err != nil {
+		return err
+	}
+
+	manifests, err := buildAdoptManifests(opts.Namespace, snapshot, opts.CloneOptions)
+	if err != nil {
+		return fmt.Errorf("failed to build bootstrap manifests: %w", err)
+	}
+
+	if err = writeManifestsToRepo(repofs, manifests, installationModeFlat, opts.Namespace); err != nil {
+		return fmt.Errorf("failed to write manifests to repo: %w", err)
+	}
+
+	log.G(ctx).Infof("applying repository credentials to cluster...")
+	if err = opts.KubeFactory.Apply(ctx, manifests.repoCreds); err != nil {
+		return fmt.Errorf("failed to apply repository credentials to cluster: %w", err)
+	}
+
+	log.G(ctx).Infof("pushing bootstrap manifests to repo")
+	commitMsg := "Autopilot Adopt"
+	if opts.CloneOptions.Path() != "" {
+		commitMsg = "Autopilot Adopt at " + opts.CloneOptions.Path()
+	}
+
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: commitMsg}); err != nil {
+		return err
+	}
+
+	log.G(ctx).Infof("applying argo-cd bootstrap application")
+	if err = opts.KubeFactory.Apply(ctx, manifests.bootstrapApp); err != nil {
+		return err
+	}
+
+	log.G(ctx).Infof("argo-cd in namespace '%s' is now managed by autopilot", opts.Namespace)
+	return nil
+}
+
+func setAdoptOptsDefaults(opts RepoAdoptOptions) (*RepoAdoptOptions, error) {
+	var err error
+
+	if opts.Namespace == "" {
+		opts.Namespace = store.Default.ArgoCDNamespace
+	}
+
+	if opts.KubeContextName == "" {
+		opts.KubeContextName, err = currentKubeContext()
+		if err != nil {
+			return &opts, err
+		}
+	}
+
+	return &opts, nil
+}
+
+// buildAdoptManifests builds the bootstrap manifests of a flat installation, that uses the
+// exported snapshot as the argo-cd manifests
+func buildAdoptManifests(namespace string, snapshot []byte, cloneOpts *git.CloneOptions) (*bootstrapManifests, error) {
+	snapshotDir, err := os.MkdirTemp("", "autopilot-adopt")
+	if err != nil {
+		return nil, fmt.Errorf("failed creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(snapshotDir)
+
+	snapshotPath := filepath.Join(snapshotDir, vendoredManifestsFileName)
+	if err = os.WriteFile(snapshotPath, snapshot, 0644); err != nil {
+		return nil, err
+	}
+
+	return buildBootstrapManifests(namespace, snapshotPath, cloneOpts, nil, nil, nil, nil, nil, nil, false, "")
+}
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	gitmocks "github.com/argoproj-labs/argocd-autopilot/pkg/git/mocks"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	kubemocks "github.com/argoproj-labs/argocd-autopilot/pkg/kube/mocks"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+)
+
+func TestRunRepoAdopt(t *testing.T) {
+	argocdServer := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "argocd-server",
+			Namespace: "argocd",
+		},
+	}
+
+	tests := map[string]struct {
+		objs     []runtime.Object
+		beforeFn func(t *testing.T, repofs fs.FS)
+		mockFn   func(r *gitmocks.MockRepository, f *kubemocks.MockFactory)
+		assertFn func(t *testing.T, repofs fs.FS, err error)
+	}{
+		"Should commit the argo-cd snapshot and apply the bootstrap app": {
+			objs: []runtime.Object{argocdServer},
+			mockFn: func(r *gitmocks.MockRepository, f *kubemocks.MockFactory) {
+				f.EXPECT().Export(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, opts *kube.ListOptions) ([]byte, error) {
+						assert.Equal(t, "argocd", opts.Namespace)
+						assert.Equal(t, "app.kubernetes.io/part-of=argocd", opts.LabelSelector)
+						assert.NotContains(t, opts.ResourceTypes, "secrets")
+						return []byte("snapshot"), nil
+					})
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Autopilot Adopt"}).Return("revision", nil)
+				f.EXPECT().Apply(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				data, err := repofs.ReadFile(repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, "install.yaml"))
+				assert.NoError(t, err)
+				assert.Equal(t, "snapshot", string(data))
+				assert.False(t, repofs.ExistsOrDie(repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, "kustomization.yaml")))
+				assert.True(t, repofs.ExistsOrDie(repofs.Join(store.Default.BootsrtrapDir, store.Default.RootAppName+".yaml")))
+				assert.True(t, repofs.ExistsOrDie(repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir+".yaml")))
+			},
+		},
+		"Should fail when argo-cd is not installed": {
+			assertFn: func(t *testing.T, _ fs.FS, err error) {
+				assert.EqualError(t, err, "argo-cd was not found in namespace 'argocd', use `repo bootstrap` to install it")
+			},
+		},
+		"Should fail when the repo is already bootstrapped": {
+			objs: []runtime.Object{argocdServer},
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, billyUtils.WriteFile(repofs, repofs.Join(store.Default.BootsrtrapDir, "file"), []byte{}, 0666))
+			},
+			mockFn: func(_ *gitmocks.MockRepository, f *kubemocks.MockFactory) {
+				f.EXPECT().Export(gomock.Any(), gomock.Any()).Return([]byte("snapshot"), nil)
+			},
+			assertFn: func(t *testing.T, _ fs.FS, err error) {
+				assert.EqualError(t, err, "folder bootstrap already exist in: /bootstrap")
+			},
+		},
+		"Should fail when the export fails": {
+			objs: []runtime.Object{argocdServer},
+			mockFn: func(_ *gitmocks.MockRepository, f *kubemocks.MockFactory) {
+				f.EXPECT().Export(gomock.Any(), gomock.Any()).Return(nil, errors.New("some error"))
+			},
+			assertFn: func(t *testing.T, _ fs.FS, err error) {
+				assert.EqualError(t, err, "failed to export argo-cd manifests: some error")
+			},
+		},
+	}
+
+	origGetRepo, origRunKustomizeBuild := getRepo, runKustomizeBuild
+	defer func() {
+		getRepo = origGetRepo
+		runKustomizeBuild = origRunKustomizeBuild
+	}()
+	runKustomizeBuild = func(k *kusttypes.Kustomization, _ map[string][]byte) ([]byte, error) {
+		return os.ReadFile(k.Resources[0])
+	}
+
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			r := gitmocks.NewMockRepository(ctrl)
+			f := kubemocks.NewMockFactory(ctrl)
+			repofs := fs.Create(memfs.New())
+			if tt.beforeFn != nil {
+				tt.beforeFn(t, repofs)
+			}
+
+			f.EXPECT().KubernetesClientSet().Return(fake.NewSimpleClientset(tt.objs...), nil)
+			if tt.mockFn != nil {
+				tt.mockFn(r, f)
+			}
+
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				return r, repofs, nil
+			}
+
+			cloneOpts := &git.CloneOptions{Repo: "https://github.com/owner/name"}
+			cloneOpts.Parse()
+			err := RunRepoAdopt(context.Background(), &RepoAdoptOptions{
+				KubeContextName: "context",
+				KubeFactory:     f,
+				CloneOptions:    cloneOpts,
+			})
+			tt.assertFn(t, repofs, err)
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/application"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+)
+
+type (
+	RepoDoctorOptions struct {
+		CloneOptions *git.CloneOptions
+		Fix          bool
+		Out          io.Writer
+	}
+
+	// doctorIssue is a single problem found in the repository layout. If fix is not
+	// nil, the problem can be repaired automatically.
+	doctorIssue struct {
+		path string
+		msg  string
+		fix  func(repofs fs.FS) error
+	}
+)
+
+func NewRepoDoctorCommand() *cobra.Command {
+	var (
+		fix       bool
+		cloneOpts *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the gitops repository layout for problems",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Report all of the problems found in the repository
+
+	<BIN> repo doctor
+
+# Report all of the problems and commit a fix for the ones that can be safely repaired
+
+	<BIN> repo doctor --fix
+`),
+		PreRun: func(_ *cobra.Command, _ []string) {
+			cloneOpts.Parse()
+			if !fix {
+				// when only reporting we don't want to commit anything
+				cloneOpts.CloneForWrite = false
+			}
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return RunRepoDoctor(cmd.Context(), &RepoDoctorOptions{
+				CloneOptions: cloneOpts,
+				Fix:          fix,
+				Out:          os.Stdout,
+			})
+		},
+	}
+
+	cmd.Flags().BoolVar(&fix, "fix", false, "If true, will remove orphaned overlays and namespace manifests, and commit the changes")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	return cmd
+}
+
+func RunRepoDoctor(ctx context.Context, opts *RepoDoctorOptions) error {
+	r, repofs, err := prepareRepo(ctx, opts.CloneOptions, "")
+	if err != nil {
+		return err
+	}
+
+	issues, err := runDoctorChecks(repofs)
+	if err != nil {
+		return err
+	}
+
+	if len(issues) == 0 {
+		log.G(ctx).Info("no problems found")
+		return nil
+	}
+
+	fixed := 0
+	for _, issue := range issues {
+		status := ""
+		if opts.Fix && issue.fix != nil {
+			if err = issue.fix(repofs); err != nil {
+				return fmt.Errorf("failed to fix '%s': %w", issue.path, err)
+			}
+
+			status = " [fixed]"
+			fixed++
+		}
+
+		_, _ = fmt.Fprintf(opts.Out, "%s: %s%s\n", issue.path, issue.msg, status)
+	}
+
+	if fixed > 0 {
+		log.G(ctx).Info("committing changes to gitops repo...")
+		if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: "Autopilot Doctor fixes"}); err != nil {
+			return fmt.Errorf("failed to push to repo: %w", err)
+		}
+	}
+
+	if fixed < len(issues) {
+		return fmt.Errorf("found %d problem(s) in the repository", len(issues)-fixed)
+	}
+
+	return nil
+}
+
+// runDoctorChecks walks the repository and returns all of the layout problems it finds.
+func runDoctorChecks(repofs fs.FS) ([]*doctorIssue, error) {
+	issues := []*doctorIssue{}
+
+	projects := map[string]bool{}
+	projectFiles, err := billyUtils.Glob(repofs, repofs.Join(store.Default.ProjectsDir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range projectFiles {
+		if _, _, err = getProjectInfoFromFile(repofs, file); err != nil {
+			issues = append(issues, &doctorIssue{path: file, msg: fmt.Sprintf("malformed project file: %v", err)})
+		}
+
+		projects[strings.TrimSuffix(filepath.Base(file), ".yaml")] = true
+	}
+
+	for _, name := range []string{store.Default.RootAppName, store.Default.ArgoCDName} {
+		file := repofs.Join(store.Default.BootsrtrapDir, name+".yaml")
+		if err = repofs.ReadYamls(file, &argocdv1alpha1.Application{}); err != nil {
+			issues = append(issues, &doctorIssue{path: file, msg: fmt.Sprintf("malformed application: %v", err)})
+		}
+	}
+
+	clusterResPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir)
+	file := clusterResPath + ".yaml"
+	if err = repofs.ReadYamls(file, &argocdv1alpha1.ApplicationSet{}); err != nil {
+		issues = append(issues, &doctorIssue{path: file, msg: fmt.Sprintf("malformed application set: %v", err)})
+	}
+
+	// server => cluster name
+	clusters := map[string]string{}
+	clusterNames := map[string]bool{}
+	clusterConfs, err := billyUtils.Glob(repofs, repofs.Join(clusterResPath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range clusterConfs {
+		conf := &application.ClusterResConfig{}
+		if err = repofs.ReadJson(file, conf); err != nil {
+			issues = append(issues, &doctorIssue{path: file, msg: fmt.Sprintf("malformed cluster config: %v", err)})
+			continue
+		}
+
+		clusters[conf.Server] = conf.Name
+		clusterNames[strings.TrimSuffix(filepath.Base(file), ".json")] = true
+	}
+
+	// cluster name => namespaces used by apps
+	namespaces := map[string]map[string]bool{}
+	appIssues, err := checkApps(repofs, projects, clusters, namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	issues = append(issues, appIssues...)
+
+	installationNamespace, err := getInstallationNamespace(repofs)
+	if err != nil {
+		issues = append(issues, &doctorIssue{
+			path: repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"),
+			msg:  fmt.Sprintf("failed to get installation namespace: %v", err),
+		})
+	} else if installationNamespace == "" {
+		issues = append(issues, &doctorIssue{
+			path: repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"),
+			msg:  "argo-cd application has no destination namespace",
+		})
+	}
+
+	nsFiles, err := billyUtils.Glob(repofs, repofs.Join(clusterResPath, "*", "*-ns.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range nsFiles {
+		cluster := filepath.Base(filepath.Dir(file))
+		if !clusterNames[cluster] {
+			// reported as part of the cluster directory check
+			continue
+		}
+
+		ns := &v1.Namespace{}
+		if err = repofs.ReadYamls(file, ns); err != nil {
+			issues = append(issues, &doctorIssue{path: file, msg: fmt.Sprintf("malformed namespace manifest: %v", err)})
+			continue
+		}
+
+		if cluster == store.Default.ClusterContextName && ns.Name == installationNamespace {
+			continue
+		}
+
+		if !namespaces[cluster][ns.Name] {
+			nsFile := file
+			issues = append(issues, &doctorIssue{
+				path: file,
+				msg:  fmt.Sprintf("namespace '%s' is not used by any application on cluster '%s'", ns.Name, cluster),
+				fix:  func(repofs fs.FS) error { return repofs.Remove(nsFile) },
+			})
+		}
+	}
+
+	clusterDirs, err := repofs.ReadDir(clusterResPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, dir := range clusterDirs {
+		if dir.IsDir() && !clusterNames[dir.Name()] {
+			issues = append(issues, &doctorIssue{
+				path: repofs.Join(clusterResPath, dir.Name()),
+				msg:  fmt.Sprintf("cluster resources directory has no '%s.json' cluster config", dir.Name()),
+			})
+		}
+	}
+
+	return issues, nil
+}
+
+// checkApps checks every app in the apps dir, and fills namespaces with the namespaces used
+// by the apps on each cluster.
+func checkApps(repofs fs.FS, projects map[string]bool, clusters map[string]string, namespaces map[string]map[string]bool) ([]*doctorIssue, error) {
+	issues := []*doctorIssue{}
+	apps, err := repofs.ReadDir(store.Default.AppsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return issues, nil
+		}
+
+		return nil, err
+	}
+
+	for _, app := range apps {
+		if !app.IsDir() {
+			continue
+		}
+
+		appName := app.Name()
+		appPath := repofs.Join(store.Default.AppsDir, appName)
+		projectsDir := appPath
+		configFiles := []string{"config.json", "config_dir.json"}
+		overlaysPath := repofs.Join(appPath, store.Default.OverlaysDir)
+		if repofs.ExistsOrDie(overlaysPath) {
+			projectsDir = overlaysPath
+			configFiles = []string{"config.json"}
+			baseKust := repofs.Join(appPath, store.Default.BaseDir, "kustomization.yaml")
+			if !repofs.ExistsOrDie(baseKust) {
+				issues = append(issues, &doctorIssue{path: baseKust, msg: "missing base kustomization"})
+			}
+		}
+
+		appProjects, err := repofs.ReadDir(projectsDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, proj := range appProjects {
+			projectName := proj.Name()
+			if !proj.IsDir() || (projectsDir == appPath && projectName == store.Default.BaseDir) {
+				continue
+			}
+
+			projectPath := repofs.Join(projectsDir, projectName)
+			if !projects[projectName] {
+				issues = append(issues, &doctorIssue{
+					path: projectPath,
+					msg:  fmt.Sprintf("app '%s' is in project '%s' which does not exist", appName, projectName),
+					fix:  func(repofs fs.FS) error { return application.DeleteFromProject(repofs, appName, projectName) },
+				})
+				continue
+			}
+
+			found := false
+			for _, configFile := range configFiles {
+				configPath := repofs.Join(projectPath, configFile)
+				if !repofs.ExistsOrDie(configPath) {
+					continue
+				}
+
+				found = true
+				conf := &application.Config{}
+				if err = repofs.ReadJson(configPath, conf); err != nil {
+					issues = append(issues, &doctorIssue{path: configPath, msg: fmt.Sprintf("malformed app config: %v", err)})
+					continue
+				}
+
+				clusterName, ok := clusters[conf.DestServer]
+				if !ok {
+					issues = append(issues, &doctorIssue{
+						path: configPath,
+						msg:  fmt.Sprintf("destServer '%s' has no cluster-resources entry", conf.DestServer),
+					})
+					continue
+				}
+
+				if namespaces[clusterName] == nil {
+					namespaces[clusterName] = map[string]bool{}
+				}
+
+				namespaces[clusterName][conf.DestNamespace] = true
+			}
+
+			if !found {
+				issues = append(issues, &doctorIssue{path: projectPath, msg: "missing app config file"})
+			}
+		}
+	}
+
+	return issues, nil
+}
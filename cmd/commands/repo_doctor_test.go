@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/application"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	gitmocks "github.com/argoproj-labs/argocd-autopilot/pkg/git/mocks"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+)
+
+func TestRunRepoDoctor(t *testing.T) {
+	tests := map[string]struct {
+		fix      bool
+		beforeFn func(t *testing.T, repofs fs.FS)
+		gitFn    func(r *gitmocks.MockRepository)
+		assertFn func(t *testing.T, repofs fs.FS, out string, err error)
+	}{
+		"Should report nothing on a valid repo": {
+			beforeFn: func(_ *testing.T, _ fs.FS) {},
+			assertFn: func(t *testing.T, _ fs.FS, out string, err error) {
+				assert.NoError(t, err)
+				assert.Empty(t, out)
+			},
+		},
+		"Should report all problems": {
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, billyUtils.WriteFile(repofs, "projects/broken.yaml", []byte("foo: [bar"), 0666))
+				assert.NoError(t, repofs.WriteJson("apps/app1/overlays/deleted/config.json", &application.Config{DestServer: store.Default.DestServer}))
+				assert.NoError(t, repofs.WriteJson("apps/app2/overlays/project/config.json", &application.Config{DestServer: "https://unknown.cluster"}))
+				assert.NoError(t, repofs.WriteYamls("bootstrap/cluster-resources/in-cluster/unused-ns.yaml", kube.GenerateNamespace("unused", nil)))
+				assert.NoError(t, repofs.MkdirAll("bootstrap/cluster-resources/unknown", 0666))
+			},
+			assertFn: func(t *testing.T, _ fs.FS, out string, err error) {
+				assert.EqualError(t, err, "found 7 problem(s) in the repository")
+				assert.Contains(t, out, "projects/broken.yaml: malformed project file")
+				assert.Contains(t, out, "apps/app1/base/kustomization.yaml: missing base kustomization")
+				assert.Contains(t, out, "apps/app2/base/kustomization.yaml: missing base kustomization")
+				assert.Contains(t, out, "apps/app1/overlays/deleted: app 'app1' is in project 'deleted' which does not exist")
+				assert.Contains(t, out, "apps/app2/overlays/project/config.json: destServer 'https://unknown.cluster' has no cluster-resources entry")
+				assert.Contains(t, out, "bootstrap/cluster-resources/in-cluster/unused-ns.yaml: namespace 'unused' is not used by any application on cluster 'in-cluster'")
+				assert.Contains(t, out, "bootstrap/cluster-resources/unknown: cluster resources directory has no 'unknown.json' cluster config")
+			},
+		},
+		"Should report a missing installation namespace": {
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, repofs.WriteYamls("bootstrap/argo-cd.yaml", &argocdv1alpha1.Application{}))
+			},
+			assertFn: func(t *testing.T, _ fs.FS, out string, err error) {
+				assert.EqualError(t, err, "found 2 problem(s) in the repository")
+				assert.Contains(t, out, "bootstrap/argo-cd.yaml: argo-cd application has no destination namespace")
+				assert.Contains(t, out, "bootstrap/cluster-resources/in-cluster/argocd-ns.yaml: namespace 'argocd' is not used by any application on cluster 'in-cluster'")
+			},
+		},
+		"Should report a malformed installation namespace": {
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, billyUtils.WriteFile(repofs, "bootstrap/argo-cd.yaml", []byte("foo: [bar"), 0666))
+			},
+			assertFn: func(t *testing.T, _ fs.FS, out string, err error) {
+				assert.EqualError(t, err, "found 3 problem(s) in the repository")
+				assert.Contains(t, out, "bootstrap/argo-cd.yaml: malformed application")
+				assert.Contains(t, out, "bootstrap/argo-cd.yaml: failed to get installation namespace")
+			},
+		},
+		"Should fix orphaned overlays and namespaces": {
+			fix: true,
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, repofs.WriteJson("apps/app1/deleted/config_dir.json", &application.Config{DestServer: store.Default.DestServer}))
+				assert.NoError(t, repofs.WriteYamls("bootstrap/cluster-resources/in-cluster/unused-ns.yaml", kube.GenerateNamespace("unused", nil)))
+			},
+			gitFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Autopilot Doctor fixes"}).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, out string, err error) {
+				assert.NoError(t, err)
+				assert.Contains(t, out, "apps/app1/deleted: app 'app1' is in project 'deleted' which does not exist [fixed]")
+				assert.False(t, repofs.ExistsOrDie("apps/app1"))
+				assert.False(t, repofs.ExistsOrDie("bootstrap/cluster-resources/in-cluster/unused-ns.yaml"))
+				assert.True(t, repofs.ExistsOrDie("bootstrap/cluster-resources/in-cluster/used-ns.yaml"))
+			},
+		},
+	}
+
+	origGetRepo := getRepo
+	defer func() { getRepo = origGetRepo }()
+
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			r := gitmocks.NewMockRepository(ctrl)
+			repofs := fs.Create(memfs.New())
+			assert.NoError(t, repofs.WriteYamls("bootstrap/argo-cd.yaml", &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Destination: argocdv1alpha1.ApplicationDestination{Namespace: "argocd"},
+				},
+			}))
+			assert.NoError(t, repofs.WriteYamls("bootstrap/root.yaml", &argocdv1alpha1.Application{}))
+			assert.NoError(t, repofs.WriteYamls("bootstrap/cluster-resources.yaml", &argocdv1alpha1.ApplicationSet{}))
+			assert.NoError(t, repofs.WriteJson("bootstrap/cluster-resources/in-cluster.json", &application.ClusterResConfig{Name: "in-cluster", Server: store.Default.DestServer}))
+			assert.NoError(t, repofs.WriteYamls("bootstrap/cluster-resources/in-cluster/argocd-ns.yaml", kube.GenerateNamespace("argocd", nil)))
+			assert.NoError(t, repofs.WriteYamls("bootstrap/cluster-resources/in-cluster/used-ns.yaml", kube.GenerateNamespace("used", nil)))
+			assert.NoError(t, repofs.WriteYamls("projects/project.yaml", &argocdv1alpha1.AppProject{}, &argocdv1alpha1.ApplicationSet{}))
+			assert.NoError(t, repofs.WriteYamls("apps/app/base/kustomization.yaml", &kusttypes.Kustomization{}))
+			assert.NoError(t, repofs.WriteJson("apps/app/overlays/project/config.json", &application.Config{DestServer: store.Default.DestServer, DestNamespace: "used"}))
+			tt.beforeFn(t, repofs)
+			if tt.gitFn != nil {
+				tt.gitFn(r)
+			}
+
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				return r, repofs, nil
+			}
+
+			cloneOpts := &git.CloneOptions{Repo: "https://github.com/owner/name"}
+			cloneOpts.Parse()
+			out := &bytes.Buffer{}
+			err := RunRepoDoctor(context.Background(), &RepoDoctorOptions{
+				CloneOptions: cloneOpts,
+				Fix:          tt.fix,
+				Out:          out,
+			})
+			tt.assertFn(t, repofs, out.String(), err)
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	fsutils "github.com/argoproj-labs/argocd-autopilot/pkg/fs/utils"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/spf13/cobra"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+)
+
+type (
+	RepoMigrateModeOptions struct {
+		InstallationMode string
+		AppSpecifier     string
+		Apps             map[string]string
+		SkipBootstrap    bool
+		Insecure         bool
+		CloneOptions     *git.CloneOptions
+	}
+)
+
+func NewRepoMigrateModeCommand() *cobra.Command {
+	var (
+		installationMode string
+		appSpecifier     string
+		apps             map[string]string
+		skipBootstrap    bool
+		insecure         bool
+		cloneOpts        *git.CloneOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "migrate-mode",
+		Short: "Switch the installation mode of argo-cd and applications between flat and normal",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Vendor the argo-cd manifests and the manifests of "my-app" into flat install.yaml files
+
+	<BIN> repo migrate-mode --installation-mode flat --apps my-app=
+
+# Switch argo-cd and "my-app" back to a kustomization that references a remote base
+
+	<BIN> repo migrate-mode --installation-mode normal --app github.com/argoproj-labs/argocd-autopilot/manifests/base?ref=v0.4.20 --apps my-app=github.com/some_org/some_repo/manifests
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return RunRepoMigrateMode(cmd.Context(), &RepoMigrateModeOptions{
+				InstallationMode: installationMode,
+				AppSpecifier:     appSpecifier,
+				Apps:             apps,
+				SkipBootstrap:    skipBootstrap,
+				Insecure:         insecure,
+				CloneOptions:     cloneOpts,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&installationMode, "installation-mode", "", "One of: normal|flat. The installation mode to migrate to")
+	cmd.Flags().StringVar(&appSpecifier, "app", "", "The argo-cd manifests specifier to use when migrating to normal mode, defaults to the manifests of this version")
+	cmd.Flags().StringToStringVar(&apps, "apps", nil, "Applications to migrate, with the specifier of the remote base to use when migrating to normal mode (e.g. \"app1=github.com/org/repo/manifests,app2=\")")
+	cmd.Flags().BoolVar(&skipBootstrap, "skip-bootstrap", false, "If true, will not migrate the argo-cd installation in the bootstrap directory")
+	cmd.Flags().BoolVar(&insecure, "insecure", false, "Use the insecure (no TLS) argo-cd manifests when migrating to normal mode and --app is not specified")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+
+	die(cmd.MarkFlagRequired("installation-mode"))
+
+	return cmd
+}
+
+func RunRepoMigrateMode(ctx context.Context, opts *RepoMigrateModeOptions) error {
+	switch opts.InstallationMode {
+	case installationModeFlat, installationModeNormal:
+	default:
+		return fmt.Errorf("unknown installation mode: %s", opts.InstallationMode)
+	}
+
+	r, repofs, err := prepareRepo(ctx, opts.CloneOptions, "")
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	if !opts.SkipBootstrap {
+		namespace, err := getInstallationNamespace(repofs)
+		if err != nil {
+			return err
+		}
+
+		appSpecifier := opts.AppSpecifier
+		if appSpecifier == "" {
+			appSpecifier = getBootstrapAppSpecifier(opts.Insecure)
+		}
+
+		migrated, err := migrateBootstrapMode(repofs, opts.InstallationMode, namespace, appSpecifier, opts.CloneOptions)
+		if err != nil {
+			return fmt.Errorf("failed to migrate argo-cd installation: %w", err)
+		}
+
+		changed = changed || migrated
+	}
+
+	for appName, specifier := range opts.Apps {
+		migrated, err := migrateAppMode(repofs, opts.InstallationMode, appName, specifier)
+		if err != nil {
+			return fmt.Errorf("failed to migrate app '%s': %w", appName, err)
+		}
+
+		changed = changed || migrated
+	}
+
+	if !changed {
+		log.G(ctx).Infof("nothing to migrate, already in '%s' installation mode", opts.InstallationMode)
+		return nil
+	}
+
+	log.G(ctx).Info("committing changes to gitops repo...")
+	if _, err = r.Persist(ctx, &git.PushOptions{CommitMsg: fmt.Sprintf("Autopilot migrated to '%s' installation mode", opts.InstallationMode)}); err != nil {
+		return fmt.Errorf("failed to push to repo: %w", err)
+	}
+
+	return nil
+}
+
+// migrateBootstrapMode converts the argo-cd installation in the bootstrap dir to mode. It
+// returns false if the installation is already in that mode.
+func migrateBootstrapMode(repofs fs.FS, mode, namespace, appSpecifier string, cloneOpts *git.CloneOptions) (bool, error) {
+	argocdPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName)
+	kustPath := repofs.Join(argocdPath, "kustomization.yaml")
+	installPath := repofs.Join(argocdPath, "install.yaml")
+	isNormal := repofs.ExistsOrDie(kustPath)
+	if !isNormal && !repofs.ExistsOrDie(installPath) {
+		return false, fmt.Errorf("argo-cd manifests not found in '%s'", argocdPath)
+	}
+
+	if isNormal == (mode == installationModeNormal) {
+		return false, nil
+	}
+
+	if mode == installationModeNormal {
+		if _, err := os.Stat(appSpecifier); err == nil {
+			return false, fmt.Errorf("local argo-cd manifests can not be used with a normal installation mode")
+		}
+
+		current, err := repofs.ReadFile(installPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to read '%s': %w", installPath, err)
+		}
+
+		k, err := createBootstrapKustomization(namespace, appSpecifier, cloneOpts)
+		if err != nil {
+			return false, err
+		}
+
+		if _, err = addArgoCDCustomizations(k, current); err != nil {
+			return false, err
+		}
+
+		log.G().Warnf("the argo-cd config maps and image registry mirror of '%s' are kept, but any other change to it is discarded", installPath)
+		if repofs.ExistsOrDie(repofs.Join(argocdPath, repoCredsFileName)) {
+			k.Resources = append(k.Resources, repoCredsFileName)
+		}
+
+		if err = repofs.WriteYamls(kustPath, k); err != nil {
+			return false, err
+		}
+
+		return true, repofs.Remove(installPath)
+	}
+
+	k := &kusttypes.Kustomization{}
+	if err := repofs.ReadYamls(kustPath, k); err != nil {
+		return false, fmt.Errorf("failed to read '%s': %w", kustPath, err)
+	}
+
+	// in flat mode the committed repo creds are synced as a plain file of the argo-cd dir
+	resources := make([]string, 0, len(k.Resources))
+	for _, r := range k.Resources {
+		if r != repoCredsFileName {
+			resources = append(resources, r)
+		}
+	}
+
+	k.Resources = resources
+
+	manifests, err := buildRepoKustomization(repofs, argocdPath, k)
+	if err != nil {
+		return false, err
+	}
+
+	// every file of the argo-cd dir is synced in flat mode, so the files that were built into
+	// the manifests (vendored manifests, patches and local resources) are removed
+	if err = removeKustomizationFiles(repofs, argocdPath, k); err != nil {
+		return false, err
+	}
+
+	if err = fsutils.BulkWrite(repofs, fsutils.BulkWriteRequest{Filename: installPath, Data: manifests}); err != nil {
+		return false, err
+	}
+
+	return true, repofs.Remove(kustPath)
+}
+
+// migrateAppMode converts the base of appName to mode. When migrating to normal mode, the
+// flat install.yaml is replaced by specifier. When migrating to flat mode, specifier (or the
+// current base resources, if empty) is vendored into install.yaml. It returns false if the base
+// is already in that mode.
+func migrateAppMode(repofs fs.FS, mode, appName, specifier string) (bool, error) {
+	basePath := repofs.Join(store.Default.AppsDir, appName, store.Default.BaseDir)
+	kustPath := repofs.Join(basePath, "kustomization.yaml")
+	installPath := repofs.Join(basePath, "install.yaml")
+	k := &kusttypes.Kustomization{}
+	if err := repofs.ReadYamls(kustPath, k); err != nil {
+		return false, fmt.Errorf("failed to read '%s': %w", kustPath, err)
+	}
+
+	isFlat := len(k.Resources) == 1 && k.Resources[0] == "install.yaml" && repofs.ExistsOrDie(installPath)
+	if isFlat == (mode == installationModeFlat) {
+		return false, nil
+	}
+
+	if mode == installationModeNormal {
+		if specifier == "" {
+			return false, fmt.Errorf("a remote base specifier is required to migrate to normal mode")
+		}
+
+		k.Resources = []string{specifier}
+		if err := repofs.WriteYamls(kustPath, k); err != nil {
+			return false, err
+		}
+
+		return true, repofs.Remove(installPath)
+	}
+
+	if specifier != "" {
+		k.Resources = []string{specifier}
+	}
+
+	manifests, err := buildRepoKustomization(repofs, basePath, k)
+	if err != nil {
+		return false, err
+	}
+
+	if err = removeKustomizationFiles(repofs, basePath, k); err != nil {
+		return false, err
+	}
+
+	// the manifests are already patched, so the flat kustomization only references them
+	flat := &kusttypes.Kustomization{
+		TypeMeta:  k.TypeMeta,
+		Resources: []string{"install.yaml"},
+	}
+	if err = repofs.WriteYamls(kustPath, flat); err != nil {
+		return false, err
+	}
+
+	return true, fsutils.BulkWrite(repofs, fsutils.BulkWriteRequest{Filename: installPath, Data: manifests})
+}
+
+// buildRepoKustomization builds k with the files of dir in the repo
+func buildRepoKustomization(repofs fs.FS, dir string, k *kusttypes.Kustomization) ([]byte, error) {
+	files, err := readKustomizationFiles(repofs, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return runKustomizeBuild(k, files)
+}
+
+// removeKustomizationFiles removes the local files of dir that are referenced by the resources
+// and patches of k
+func removeKustomizationFiles(repofs fs.FS, dir string, k *kusttypes.Kustomization) error {
+	paths := make([]string, 0, len(k.Resources)+len(k.Patches))
+	paths = append(paths, k.Resources...)
+	for _, p := range k.Patches {
+		if p.Path != "" {
+			paths = append(paths, p.Path)
+		}
+	}
+
+	for _, p := range paths {
+		filename := repofs.Join(dir, p)
+		if !repofs.ExistsOrDie(filename) {
+			continue
+		}
+
+		if fi, err := repofs.Stat(filename); err != nil || fi.IsDir() {
+			continue
+		}
+
+		if err := repofs.Remove(filename); err != nil {
+			return fmt.Errorf("failed to remove '%s': %w", filename, err)
+		}
+	}
+
+	return nil
+}
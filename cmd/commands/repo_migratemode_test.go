@@ -0,0 +1,243 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	gitmocks "github.com/argoproj-labs/argocd-autopilot/pkg/git/mocks"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+func TestRunRepoMigrateMode(t *testing.T) {
+	tests := map[string]struct {
+		opts     *RepoMigrateModeOptions
+		beforeFn func(t *testing.T, repofs fs.FS)
+		gitFn    func(r *gitmocks.MockRepository)
+		assertFn func(t *testing.T, repofs fs.FS, err error)
+	}{
+		"Should migrate argo-cd and apps to flat mode": {
+			opts: &RepoMigrateModeOptions{
+				InstallationMode: installationModeFlat,
+				Apps:             map[string]string{"app": ""},
+			},
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, repofs.WriteYamls("bootstrap/argo-cd/kustomization.yaml", &kusttypes.Kustomization{
+					Resources: []string{"vendored-install.yaml", "repo-creds.yaml"},
+					Patches:   []kusttypes.Patch{{Path: "limits.yaml"}},
+					Images:    []kusttypes.Image{{Name: "quay.io/argoproj/argocd", NewName: "registry.local/argoproj/argocd"}},
+				}))
+				assert.NoError(t, billyUtils.WriteFile(repofs, "bootstrap/argo-cd/vendored-install.yaml", []byte("vendored"), 0666))
+				assert.NoError(t, billyUtils.WriteFile(repofs, "bootstrap/argo-cd/limits.yaml", []byte("limits"), 0666))
+				assert.NoError(t, billyUtils.WriteFile(repofs, "bootstrap/argo-cd/repo-creds.yaml", []byte("creds"), 0666))
+				assert.NoError(t, repofs.WriteYamls("apps/app/base/kustomization.yaml", &kusttypes.Kustomization{
+					Resources: []string{"github.com/foo/app"},
+					Namespace: "app",
+					Images:    []kusttypes.Image{{Name: "app", NewTag: "v2"}},
+				}))
+			},
+			gitFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Autopilot migrated to 'flat' installation mode"}).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				assert.False(t, repofs.ExistsOrDie("bootstrap/argo-cd/kustomization.yaml"))
+				assert.False(t, repofs.ExistsOrDie("bootstrap/argo-cd/vendored-install.yaml"))
+				assert.False(t, repofs.ExistsOrDie("bootstrap/argo-cd/limits.yaml"))
+				assert.True(t, repofs.ExistsOrDie("bootstrap/argo-cd/repo-creds.yaml"))
+				data, _ := repofs.ReadFile("bootstrap/argo-cd/install.yaml")
+				assert.Equal(t, "built vendored with limits", string(data))
+				data, _ = repofs.ReadFile("apps/app/base/install.yaml")
+				assert.Equal(t, "github.com/foo/app", string(data))
+				k := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls("apps/app/base/kustomization.yaml", k))
+				assert.Equal(t, &kusttypes.Kustomization{Resources: []string{"install.yaml"}}, k)
+			},
+		},
+		"Should migrate argo-cd and apps to normal mode": {
+			opts: &RepoMigrateModeOptions{
+				InstallationMode: installationModeNormal,
+				AppSpecifier:     "github.com/foo/argo-cd",
+				Apps:             map[string]string{"app": "github.com/foo/app"},
+			},
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, billyUtils.WriteFile(repofs, "bootstrap/argo-cd/install.yaml", []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: argocd-rbac-cm
+data:
+  policy.default: role:readonly
+`), 0666))
+				assert.NoError(t, billyUtils.WriteFile(repofs, "bootstrap/argo-cd/repo-creds.yaml", []byte("creds"), 0666))
+				assert.NoError(t, repofs.WriteYamls("apps/app/base/kustomization.yaml", &kusttypes.Kustomization{Resources: []string{"install.yaml"}}))
+				assert.NoError(t, billyUtils.WriteFile(repofs, "apps/app/base/install.yaml", []byte("manifests"), 0666))
+			},
+			gitFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Autopilot migrated to 'normal' installation mode"}).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				assert.False(t, repofs.ExistsOrDie("bootstrap/argo-cd/install.yaml"))
+				assert.False(t, repofs.ExistsOrDie("apps/app/base/install.yaml"))
+				k := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls("bootstrap/argo-cd/kustomization.yaml", k))
+				assert.Equal(t, []string{"github.com/foo/argo-cd", "repo-creds.yaml"}, k.Resources)
+				assert.Equal(t, "argocd", k.Namespace)
+				assert.Len(t, k.ConfigMapGenerator, 1)
+				assert.Equal(t, "argocd-rbac-cm", k.ConfigMapGenerator[0].Name)
+				assert.Equal(t, []string{"policy.default=role:readonly"}, k.ConfigMapGenerator[0].LiteralSources)
+				k = &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls("apps/app/base/kustomization.yaml", k))
+				assert.Equal(t, []string{"github.com/foo/app"}, k.Resources)
+			},
+		},
+		"Should use the insecure manifests when migrating to normal mode with --insecure": {
+			opts: &RepoMigrateModeOptions{
+				InstallationMode: installationModeNormal,
+				Insecure:         true,
+			},
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, billyUtils.WriteFile(repofs, "bootstrap/argo-cd/install.yaml", []byte("kind: Namespace"), 0666))
+			},
+			gitFn: func(r *gitmocks.MockRepository) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("revision", nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				k := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls("bootstrap/argo-cd/kustomization.yaml", k))
+				assert.Equal(t, []string{store.Get().InstallationManifestsInsecureURL}, k.Resources)
+			},
+		},
+		"Should not commit when already in the requested mode": {
+			opts: &RepoMigrateModeOptions{
+				InstallationMode: installationModeNormal,
+			},
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, repofs.WriteYamls("bootstrap/argo-cd/kustomization.yaml", &kusttypes.Kustomization{Resources: []string{"github.com/foo/argo-cd"}}))
+			},
+			assertFn: func(t *testing.T, _ fs.FS, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		"Should fail migrating an app to normal mode without a specifier": {
+			opts: &RepoMigrateModeOptions{
+				InstallationMode: installationModeNormal,
+				SkipBootstrap:    true,
+				Apps:             map[string]string{"app": ""},
+			},
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, repofs.WriteYamls("apps/app/base/kustomization.yaml", &kusttypes.Kustomization{Resources: []string{"install.yaml"}}))
+				assert.NoError(t, billyUtils.WriteFile(repofs, "apps/app/base/install.yaml", []byte("manifests"), 0666))
+			},
+			assertFn: func(t *testing.T, _ fs.FS, err error) {
+				assert.EqualError(t, err, "failed to migrate app 'app': a remote base specifier is required to migrate to normal mode")
+			},
+		},
+		"Should fail on unknown installation mode": {
+			opts: &RepoMigrateModeOptions{
+				InstallationMode: "foo",
+			},
+			beforeFn: func(_ *testing.T, _ fs.FS) {},
+			assertFn: func(t *testing.T, _ fs.FS, err error) {
+				assert.EqualError(t, err, "unknown installation mode: foo")
+			},
+		},
+	}
+
+	origGetRepo, origRunKustomizeBuild := getRepo, runKustomizeBuild
+	defer func() {
+		getRepo = origGetRepo
+		runKustomizeBuild = origRunKustomizeBuild
+	}()
+	runKustomizeBuild = func(k *kusttypes.Kustomization, files map[string][]byte) ([]byte, error) {
+		if len(k.Patches) > 0 {
+			// the local files are read from the repo
+			return []byte(fmt.Sprintf("built %s with %s", files[k.Resources[0]], files[k.Patches[0].Path])), nil
+		}
+
+		return []byte(k.Resources[0]), nil
+	}
+
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			r := gitmocks.NewMockRepository(ctrl)
+			repofs := fs.Create(memfs.New())
+			assert.NoError(t, repofs.WriteYamls("bootstrap/argo-cd.yaml", &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Destination: argocdv1alpha1.ApplicationDestination{Namespace: "argocd"},
+				},
+			}))
+			tt.beforeFn(t, repofs)
+			if tt.gitFn != nil {
+				tt.gitFn(r)
+			}
+
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				return r, repofs, nil
+			}
+
+			tt.opts.CloneOptions = &git.CloneOptions{Repo: "https://github.com/owner/name"}
+			tt.opts.CloneOptions.Parse()
+			err := RunRepoMigrateMode(context.Background(), tt.opts)
+			tt.assertFn(t, repofs, err)
+		})
+	}
+}
+
+func Test_migrateAppMode(t *testing.T) {
+	t.Chdir(t.TempDir())
+	repofs := fs.Create(memfs.New())
+	assert.NoError(t, billyUtils.WriteFile(repofs, "apps/app/base/deployment.yaml", []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.24
+`), 0666))
+	assert.NoError(t, repofs.WriteYamls("apps/app/base/kustomization.yaml", &kusttypes.Kustomization{
+		Resources: []string{"./deployment.yaml"},
+		Namespace: "app",
+		Images:    []kusttypes.Image{{Name: "nginx", NewTag: "1.25"}},
+		Patches: []kusttypes.Patch{
+			{
+				Target: &kusttypes.Selector{ResId: resid.ResId{Gvk: resid.Gvk{Kind: "Deployment"}, Name: "app"}},
+				Patch:  "- op: add\n  path: /spec/template/spec/containers/-\n  value:\n    name: sidecar\n    image: busybox\n",
+			},
+		},
+	}))
+
+	orgKust := &kusttypes.Kustomization{}
+	assert.NoError(t, repofs.ReadYamls("apps/app/base/kustomization.yaml", orgKust))
+	want, err := buildRepoKustomization(repofs, "apps/app/base", orgKust)
+	assert.NoError(t, err)
+
+	migrated, err := migrateAppMode(repofs, installationModeFlat, "app", "")
+	assert.NoError(t, err)
+	assert.True(t, migrated)
+	assert.False(t, repofs.ExistsOrDie("apps/app/base/deployment.yaml"))
+
+	// building the flat base must not apply the patches, images and namespace again
+	flatKust := &kusttypes.Kustomization{}
+	assert.NoError(t, repofs.ReadYamls("apps/app/base/kustomization.yaml", flatKust))
+	got, err := buildRepoKustomization(repofs, "apps/app/base", flatKust)
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+	assert.Equal(t, 1, strings.Count(string(got), "name: sidecar"))
+}
@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type (
+	RepoStatusOptions struct {
+		CloneOptions *git.CloneOptions
+		KubeFactory  kube.Factory
+		Out          io.Writer
+	}
+)
+
+func NewRepoStatusCommand() *cobra.Command {
+	var (
+		cloneOpts *git.CloneOptions
+		f         kube.Factory
+	)
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the live status of all the applications of an installation",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Show the sync and health status of every application of the installation
+
+	<BIN> repo status
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return RunRepoStatus(cmd.Context(), &RepoStatusOptions{
+				CloneOptions: cloneOpts,
+				KubeFactory:  f,
+				Out:          os.Stdout,
+			})
+		},
+	}
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS: memfs.New(),
+	})
+	f = kube.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func RunRepoStatus(ctx context.Context, opts *RepoStatusOptions) error {
+	r, repofs, err := prepareRepo(ctx, opts.CloneOptions, "")
+	if err != nil {
+		return err
+	}
+
+	namespace, err := getInstallationNamespace(repofs)
+	if err != nil {
+		return err
+	}
+
+	head, err := r.CurrentRevision()
+	if err != nil {
+		return err
+	}
+
+	appSets, err := getInstallationAppSets(repofs)
+	if err != nil {
+		return err
+	}
+
+	cs, err := getArgoCDClientSet(opts.KubeFactory)
+	if err != nil {
+		return fmt.Errorf("failed to create argo-cd clientset: %w", err)
+	}
+
+	apps, err := cs.ArgoprojV1alpha1().Applications(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list applications in namespace '%s': %w", namespace, err)
+	}
+
+	appsByName := map[string]*argocdv1alpha1.Application{}
+	appsByOwner := map[string][]*argocdv1alpha1.Application{}
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		appsByName[app.Name] = app
+		for _, ref := range app.OwnerReferences {
+			if ref.Kind == "ApplicationSet" {
+				appsByOwner[ref.Name] = append(appsByOwner[ref.Name], app)
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(opts.Out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "NAME\tAPPLICATIONSET\tSYNC\tHEALTH\tREVISION\tLATEST\t\n")
+
+	for _, name := range []string{store.Default.BootsrtrapAppName, store.Default.RootAppName, store.Default.ArgoCDName} {
+		app, ok := appsByName[name]
+		if !ok {
+			_, _ = fmt.Fprintf(w, "%s\t-\tMissing\t-\t-\t-\t\n", name)
+			continue
+		}
+
+		printAppStatus(w, app, "-", opts.CloneOptions.URL(), head)
+	}
+
+	for _, appSet := range appSets {
+		owned := appsByOwner[appSet]
+		sort.Slice(owned, func(i, j int) bool { return owned[i].Name < owned[j].Name })
+		for _, app := range owned {
+			printAppStatus(w, app, appSet, opts.CloneOptions.URL(), head)
+		}
+	}
+
+	return w.Flush()
+}
+
+// getInstallationAppSets returns the names of the cluster-resources ApplicationSet and of
+// every project ApplicationSet in the installation
+func getInstallationAppSets(repofs fs.FS) ([]string, error) {
+	appSets := []string{store.Default.ClusterResourcesDir}
+	matches, err := billyUtils.Glob(repofs, repofs.Join(store.Default.ProjectsDir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range matches {
+		_, appSet, err := getProjectInfoFromFile(repofs, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read project file '%s': %w", name, err)
+		}
+
+		appSets = append(appSets, appSet.Name)
+	}
+
+	return appSets, nil
+}
+
+// printAppStatus writes a single status line for app. An application that is sourced from the
+// gitops repository is considered latest only if it is synced to head.
+func printAppStatus(w io.Writer, app *argocdv1alpha1.Application, appSet, repoURL, head string) {
+	revision := app.Status.Sync.Revision
+	latest := "-"
+	if app.Spec.Source != nil && app.Spec.Source.RepoURL == repoURL {
+		latest = "yes"
+		if revision != head {
+			latest = "no"
+		}
+	}
+
+	if len(revision) > 7 {
+		revision = revision[:7]
+	}
+
+	if revision == "" {
+		revision = "-"
+	}
+
+	_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", app.Name, appSet, app.Status.Sync.Status, app.Status.Health.Status, revision, latest)
+}
@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	gitmocks "github.com/argoproj-labs/argocd-autopilot/pkg/git/mocks"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argocdcs "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned"
+	argocdfake "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned/fake"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestRunRepoStatus(t *testing.T) {
+	newApp := func(name, appSet, repoURL, revision string) *argocdv1alpha1.Application {
+		app := &argocdv1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "argocd",
+			},
+			Spec: argocdv1alpha1.ApplicationSpec{
+				Source: &argocdv1alpha1.ApplicationSource{RepoURL: repoURL},
+			},
+			Status: argocdv1alpha1.ApplicationStatus{
+				Sync: argocdv1alpha1.SyncStatus{
+					Status:   argocdv1alpha1.SyncStatusCodeSynced,
+					Revision: revision,
+				},
+				Health: argocdv1alpha1.AppHealthStatus{Status: "Healthy"},
+			},
+		}
+		if appSet != "" {
+			app.OwnerReferences = []metav1.OwnerReference{{Kind: "ApplicationSet", Name: appSet}}
+		}
+
+		return app
+	}
+
+	tests := map[string]struct {
+		apps     []*argocdv1alpha1.Application
+		assertFn func(t *testing.T, out string, err error)
+	}{
+		"Should print the status of all installation applications": {
+			apps: []*argocdv1alpha1.Application{
+				newApp(store.Default.BootsrtrapAppName, "", "https://github.com/owner/name.git", "1234567890"),
+				newApp(store.Default.RootAppName, "", "https://github.com/owner/name.git", "1234567890"),
+				newApp(store.Default.ArgoCDName, "", "https://github.com/owner/name.git", "0987654321"),
+				newApp("cluster-resources-in-cluster", store.Default.ClusterResourcesDir, "https://github.com/owner/name.git", "1234567890"),
+				newApp("project-app", "project", "https://github.com/other/repo.git", "abc"),
+				newApp("unrelated", "", "https://github.com/owner/name.git", "1234567890"),
+			},
+			assertFn: func(t *testing.T, out string, err error) {
+				assert.NoError(t, err)
+				assert.Contains(t, out, "autopilot-bootstrap")
+				assert.Regexp(t, `argo-cd\s+-\s+Synced\s+Healthy\s+0987654\s+no`, out)
+				assert.Regexp(t, `root\s+-\s+Synced\s+Healthy\s+1234567\s+yes`, out)
+				assert.Regexp(t, `cluster-resources-in-cluster\s+cluster-resources\s+Synced\s+Healthy\s+1234567\s+yes`, out)
+				assert.Regexp(t, `project-app\s+project\s+Synced\s+Healthy\s+abc\s+-`, out)
+				assert.NotContains(t, out, "unrelated")
+			},
+		},
+		"Should report missing bootstrap applications": {
+			apps: []*argocdv1alpha1.Application{},
+			assertFn: func(t *testing.T, out string, err error) {
+				assert.NoError(t, err)
+				assert.Regexp(t, `autopilot-bootstrap\s+-\s+Missing`, out)
+				assert.Regexp(t, `argo-cd\s+-\s+Missing`, out)
+			},
+		},
+	}
+
+	origGetRepo, origGetArgoCDClientSet := getRepo, getArgoCDClientSet
+	defer func() {
+		getRepo = origGetRepo
+		getArgoCDClientSet = origGetArgoCDClientSet
+	}()
+
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			r := gitmocks.NewMockRepository(ctrl)
+			r.EXPECT().CurrentRevision().Return("1234567890", nil)
+			repofs := fs.Create(memfs.New())
+			assert.NoError(t, repofs.WriteYamls(repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"), &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Destination: argocdv1alpha1.ApplicationDestination{Namespace: "argocd"},
+				},
+			}))
+			assert.NoError(t, repofs.WriteYamls(repofs.Join(store.Default.ProjectsDir, "project.yaml"), &argocdv1alpha1.AppProject{
+				ObjectMeta: metav1.ObjectMeta{Name: "project"},
+			}, &argocdv1alpha1.ApplicationSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "project"},
+			}))
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				return r, repofs, nil
+			}
+
+			objs := []runtime.Object{}
+			for _, app := range tt.apps {
+				objs = append(objs, app)
+			}
+
+			getArgoCDClientSet = func(_ kube.Factory) (argocdcs.Interface, error) {
+				return argocdfake.NewSimpleClientset(objs...), nil
+			}
+
+			cloneOpts := &git.CloneOptions{Repo: "https://github.com/owner/name"}
+			cloneOpts.Parse()
+			out := &bytes.Buffer{}
+			err := RunRepoStatus(context.Background(), &RepoStatusOptions{
+				CloneOptions: cloneOpts,
+				Out:          out,
+			})
+			tt.assertFn(t, out.String(), err)
+		})
+	}
+}
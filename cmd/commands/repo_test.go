@@ -1,11 +1,19 @@
 package commands
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/argoproj-labs/argocd-autopilot/pkg/argocd"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
@@ -14,9 +22,12 @@ import (
 	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
 	kubemocks "github.com/argoproj-labs/argocd-autopilot/pkg/kube/mocks"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
 
 	argocdcommon "github.com/argoproj/argo-cd/v3/common"
 	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	argocdcs "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned"
+	argocdfake "github.com/argoproj/argo-cd/v3/pkg/client/clientset/versioned/fake"
 	"github.com/go-git/go-billy/v5/memfs"
 	billyUtils "github.com/go-git/go-billy/v5/util"
 	"github.com/golang/mock/gomock"
@@ -82,6 +93,83 @@ func Test_setBootstrapOptsDefaults(t *testing.T) {
 				assert.Equal(t, "manifests/insecure", opts.AppSpecifier)
 			},
 		},
+		"Unknown repo creds mode": {
+			opts: &RepoBootstrapOptions{
+				CloneOptions: &git.CloneOptions{},
+				RepoCreds:    RepoCredsOptions{Mode: "foo"},
+			},
+			assertFn: func(t *testing.T, _ *RepoBootstrapOptions, ret error) {
+				assert.EqualError(t, ret, "unknown repo creds mode: foo")
+			},
+		},
+		"External secret without a store": {
+			opts: &RepoBootstrapOptions{
+				CloneOptions: &git.CloneOptions{},
+				RepoCreds:    RepoCredsOptions{Mode: repoCredsModeExternalSecret, RemoteKey: "key"},
+			},
+			assertFn: func(t *testing.T, _ *RepoBootstrapOptions, ret error) {
+				assert.EqualError(t, ret, "--repo-creds-secret-store and --repo-creds-remote-key are required in external-secret mode")
+			},
+		},
+		"Sealed secret without a certificate": {
+			opts: &RepoBootstrapOptions{
+				CloneOptions: &git.CloneOptions{},
+				RepoCreds:    RepoCredsOptions{Mode: repoCredsModeSealedSecret},
+			},
+			assertFn: func(t *testing.T, _ *RepoBootstrapOptions, ret error) {
+				assert.EqualError(t, ret, "--sealed-secrets-cert is required in sealed-secret mode")
+			},
+		},
+		"Generate deploy key without ssh key": {
+			opts: &RepoBootstrapOptions{
+				CloneOptions:      &git.CloneOptions{},
+				GenerateDeployKey: true,
+			},
+			assertFn: func(t *testing.T, _ *RepoBootstrapOptions, ret error) {
+				assert.EqualError(t, ret, "--generate-deploy-key requires the --git-ssh-key flag")
+			},
+		},
+		"Generate deploy key with dry run": {
+			opts: &RepoBootstrapOptions{
+				CloneOptions: &git.CloneOptions{
+					Auth: git.Auth{SSHKeyFile: "id_ed25519"},
+				},
+				GenerateDeployKey: true,
+				DryRun:            true,
+			},
+			assertFn: func(t *testing.T, _ *RepoBootstrapOptions, ret error) {
+				assert.EqualError(t, ret, "--generate-deploy-key cannot be used together with --dry-run")
+			},
+		},
+		"Generate deploy key without git token": {
+			opts: &RepoBootstrapOptions{
+				CloneOptions: &git.CloneOptions{
+					Auth: git.Auth{SSHKeyFile: "id_ed25519"},
+				},
+				GenerateDeployKey: true,
+			},
+			assertFn: func(t *testing.T, _ *RepoBootstrapOptions, ret error) {
+				assert.EqualError(t, ret, "--generate-deploy-key requires a git token, in order to add the deploy key to the repository")
+			},
+		},
+		"Image registry mirror without airgap": {
+			opts: &RepoBootstrapOptions{
+				CloneOptions:        &git.CloneOptions{},
+				ImageRegistryMirror: "registry.local",
+			},
+			assertFn: func(t *testing.T, _ *RepoBootstrapOptions, ret error) {
+				assert.EqualError(t, ret, "--image-registry-mirror can only be used together with --airgap")
+			},
+		},
+		"Output dir without dry run": {
+			opts: &RepoBootstrapOptions{
+				CloneOptions: &git.CloneOptions{},
+				OutputDir:    "out",
+			},
+			assertFn: func(t *testing.T, _ *RepoBootstrapOptions, ret error) {
+				assert.EqualError(t, ret, "--output-dir can only be used together with --dry-run")
+			},
+		},
 	}
 
 	orgCurrentKubeContext := currentKubeContext
@@ -132,6 +220,33 @@ func Test_validateRepo(t *testing.T) {
 	}
 }
 
+func Test_writeManifestsToDir(t *testing.T) {
+	manifests := &bootstrapManifests{
+		applyManifests:   []byte("install"),
+		argocdApp:        []byte("argocd-app"),
+		rootApp:          []byte("root-app"),
+		clusterResAppSet: []byte("cluster-resources"),
+		clusterResConfig: []byte("{}"),
+	}
+
+	t.Run("Should write the files under the installation path", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, writeManifestsToDir(dir, "path/to/installation", manifests, installationModeFlat, "argocd"))
+		data, err := os.ReadFile(filepath.Join(dir, "path/to/installation", store.Default.BootsrtrapDir, store.Default.ArgoCDName, "install.yaml"))
+		assert.NoError(t, err)
+		assert.Equal(t, "install", string(data))
+		assert.FileExists(t, filepath.Join(dir, "path/to/installation", store.Default.BootsrtrapDir, store.Default.RootAppName+".yaml"))
+		assert.FileExists(t, filepath.Join(dir, "path/to/installation", store.Default.AppsDir, "README.md"))
+	})
+
+	t.Run("Should fail if the output directory already has an installation", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, store.Default.BootsrtrapDir), 0755))
+		err := writeManifestsToDir(dir, "", manifests, installationModeFlat, "argocd")
+		assert.ErrorContains(t, err, fmt.Sprintf("folder %s already exist", store.Default.BootsrtrapDir))
+	})
+}
+
 func Test_buildBootstrapManifests(t *testing.T) {
 	type args struct {
 		namespace           string
@@ -139,11 +254,14 @@ func Test_buildBootstrapManifests(t *testing.T) {
 		cloneOpts           *git.CloneOptions
 		argoCDLabels        map[string]string
 		bootstrapAppsLabels map[string]string
+		airgap              bool
+		imageRegistryMirror string
 	}
 	tests := map[string]struct {
-		args     args
-		preFn    func()
-		assertFn func(t *testing.T, b *bootstrapManifests, ret error)
+		args           args
+		preFn          func()
+		kustomizeBuild func(t *testing.T, k *kusttypes.Kustomization, files map[string][]byte) ([]byte, error)
+		assertFn       func(t *testing.T, b *bootstrapManifests, ret error)
 	}{
 		"Basic": {
 			args: args{
@@ -210,18 +328,57 @@ func Test_buildBootstrapManifests(t *testing.T) {
 				assert.Equal(t, store.Default.GitHubUsername, creds.StringData["username"])
 			},
 		},
+		"Airgap": {
+			args: args{
+				namespace:    "foo",
+				appSpecifier: "github.com/foo/bar/manifests",
+				cloneOpts: &git.CloneOptions{
+					Repo: "https://github.com/foo/bar/installation1?ref=main",
+					Auth: git.Auth{Password: "test"},
+				},
+				airgap:              true,
+				imageRegistryMirror: "registry.local",
+			},
+			kustomizeBuild: func(t *testing.T, k *kusttypes.Kustomization, files map[string][]byte) ([]byte, error) {
+				if k.Resources[0] == "github.com/foo/bar/manifests" {
+					assert.Empty(t, k.Namespace)
+					return []byte("kind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n      - image: quay.io/argoproj/argocd:v3\n"), nil
+				}
+
+				// the vendored manifests are built from the file next to the kustomization
+				assert.Equal(t, "vendored-install.yaml", k.Resources[0])
+				assert.Contains(t, string(files["vendored-install.yaml"]), "quay.io/argoproj/argocd:v3")
+				return []byte("test"), nil
+			},
+			assertFn: func(t *testing.T, b *bootstrapManifests, ret error) {
+				assert.NoError(t, ret)
+				assert.Equal(t, []byte("test"), b.applyManifests)
+				assert.Contains(t, string(b.vendoredManifests), "quay.io/argoproj/argocd:v3")
+
+				k := &kusttypes.Kustomization{}
+				assert.NoError(t, yaml.Unmarshal(b.bootstrapKustomization, k))
+				assert.Equal(t, []string{"vendored-install.yaml"}, k.Resources)
+				assert.Equal(t, "foo", k.Namespace)
+				assert.Equal(t, []kusttypes.Image{
+					{Name: "quay.io/argoproj/argocd", NewName: "registry.local/argoproj/argocd"},
+				}, k.Images)
+			},
+		},
 	}
 
 	orgRunKustomizeBuild := runKustomizeBuild
 	defer func() { runKustomizeBuild = orgRunKustomizeBuild }()
 
-	runKustomizeBuild = func(k *kusttypes.Kustomization) ([]byte, error) {
-		return []byte("test"), nil
-	}
-
 	for tname, tt := range tests {
 		t.Run(tname, func(t *testing.T) {
 			tt.args.cloneOpts.Parse()
+			runKustomizeBuild = func(k *kusttypes.Kustomization, files map[string][]byte) ([]byte, error) {
+				if tt.kustomizeBuild != nil {
+					return tt.kustomizeBuild(t, k, files)
+				}
+
+				return []byte("test"), nil
+			}
 
 			b, ret := buildBootstrapManifests(
 				tt.args.namespace,
@@ -230,6 +387,11 @@ func Test_buildBootstrapManifests(t *testing.T) {
 				tt.args.argoCDLabels,
 				tt.args.bootstrapAppsLabels,
 				nil,
+				nil,
+				nil,
+				nil,
+				tt.args.airgap,
+				tt.args.imageRegistryMirror,
 			)
 
 			tt.assertFn(t, b, ret)
@@ -239,6 +401,7 @@ func Test_buildBootstrapManifests(t *testing.T) {
 
 func TestRunRepoBootstrap(t *testing.T) {
 	exitCalled := false
+	outputDir := t.TempDir()
 	tests := map[string]struct {
 		opts     *RepoBootstrapOptions
 		beforeFn func(*gitmocks.MockRepository, *kubemocks.MockFactory)
@@ -260,6 +423,46 @@ func TestRunRepoBootstrap(t *testing.T) {
 				assert.True(t, exitCalled)
 			},
 		},
+		"DryRun with output dir": {
+			opts: &RepoBootstrapOptions{
+				DryRun:           true,
+				OutputDir:        outputDir,
+				InstallationMode: installationModeNormal,
+				Namespace:        "bar",
+				CloneOptions: &git.CloneOptions{
+					Repo: "https://github.com/foo/bar/installation1?ref=main",
+					Auth: git.Auth{Password: "test"},
+				},
+			},
+			beforeFn: func(r *gitmocks.MockRepository, f *kubemocks.MockFactory) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				f.EXPECT().Apply(gomock.Any(), gomock.Any()).Times(0)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, ret error) {
+				assert.NoError(t, ret)
+				assert.True(t, exitCalled)
+				assert.False(t, repofs.ExistsOrDie(store.Default.BootsrtrapDir))
+
+				for _, name := range []string{
+					filepath.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, "kustomization.yaml"),
+					filepath.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"),
+					filepath.Join(store.Default.BootsrtrapDir, store.Default.RootAppName+".yaml"),
+					filepath.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir+".yaml"),
+					filepath.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, store.Default.ClusterContextName+".json"),
+					filepath.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, store.Default.ClusterContextName, "README.md"),
+					filepath.Join(store.Default.ProjectsDir, "README.md"),
+					filepath.Join(store.Default.AppsDir, "README.md"),
+				} {
+					assert.FileExists(t, filepath.Join(outputDir, name))
+				}
+
+				rootApp := &argocdv1alpha1.Application{}
+				data, err := os.ReadFile(filepath.Join(outputDir, store.Default.BootsrtrapDir, store.Default.RootAppName+".yaml"))
+				assert.NoError(t, err)
+				assert.NoError(t, yaml.Unmarshal(data, rootApp))
+				assert.Equal(t, store.Default.ProjectsDir, rootApp.Spec.Source.Path)
+			},
+		},
 		"Flat installation": {
 			opts: &RepoBootstrapOptions{
 				InstallationMode: installationModeFlat,
@@ -376,6 +579,52 @@ func TestRunRepoBootstrap(t *testing.T) {
 				)))
 			},
 		},
+		"Normal installation with external-secret repo creds": {
+			opts: &RepoBootstrapOptions{
+				InstallationMode: installationModeNormal,
+				Namespace:        "bar",
+				CloneOptions: &git.CloneOptions{
+					Repo: "https://github.com/foo/bar/installation1?ref=main",
+					Auth: git.Auth{Password: "test"},
+				},
+				RepoCreds: RepoCredsOptions{
+					Mode:        repoCredsModeExternalSecret,
+					SecretStore: "ClusterSecretStore/vault",
+					RemoteKey:   "git-token",
+				},
+			},
+			beforeFn: func(r *gitmocks.MockRepository, f *kubemocks.MockFactory) {
+				mockCS := fake.NewSimpleClientset(&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "argocd-initial-admin-secret",
+						Namespace: "bar",
+					},
+					Data: map[string][]byte{
+						"password": []byte("foo"),
+					},
+				})
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Autopilot Bootstrap"}).Return("revision", nil)
+				f.EXPECT().Apply(gomock.Any(), gomock.Any()).
+					Times(2).
+					Return(nil)
+				f.EXPECT().Wait(gomock.Any(), gomock.Any()).Return(nil)
+				f.EXPECT().KubernetesClientSetOrDie().Return(mockCS)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, ret error) {
+				assert.NoError(t, ret)
+				argocdPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName)
+
+				k := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(repofs.Join(argocdPath, "kustomization.yaml"), k))
+				assert.Contains(t, k.Resources, repoCredsFileName)
+
+				es := &kube.ExternalSecret{}
+				assert.NoError(t, repofs.ReadYamls(repofs.Join(argocdPath, repoCredsFileName), es))
+				assert.Equal(t, "ExternalSecret", es.Kind)
+				assert.Equal(t, kube.SecretStoreRef{Kind: "ClusterSecretStore", Name: "vault"}, es.Spec.SecretStoreRef)
+				assert.Equal(t, "{{ .password }}", es.Spec.Target.Template.Data["password"])
+			},
+		},
 	}
 
 	origExit, origGetRepo, origRunKustomizeBuild, origArgoLogin := exit, getRepo, runKustomizeBuild, argocdLogin
@@ -386,7 +635,7 @@ func TestRunRepoBootstrap(t *testing.T) {
 		argocdLogin = origArgoLogin
 	}()
 	exit = func(_ int) { exitCalled = true }
-	runKustomizeBuild = func(k *kusttypes.Kustomization) ([]byte, error) { return []byte("test"), nil }
+	runKustomizeBuild = func(k *kusttypes.Kustomization, _ map[string][]byte) ([]byte, error) { return []byte("test"), nil }
 	argocdLogin = func(opts *argocd.LoginOptions) error { return nil }
 
 	for tname, tt := range tests {
@@ -413,6 +662,7 @@ func Test_getRepoCredsSecret(t *testing.T) {
 	tests := map[string]struct {
 		username  string
 		token     string
+		sshKey    string
 		namespace string
 		repoURL   string
 		assertFn  func(t *testing.T, secret []byte, err error)
@@ -454,22 +704,147 @@ func Test_getRepoCredsSecret(t *testing.T) {
 				assert.Equal(t, "glpat-xxxx", secret.StringData["password"])
 			},
 		},
+		"SSH key": {
+			username:  "testuser",
+			token:     "testtoken",
+			sshKey:    "private-key",
+			namespace: "argocd",
+			repoURL:   "git@github.com:owner/repo.git",
+			assertFn: func(t *testing.T, secretBytes []byte, err error) {
+				assert.NoError(t, err)
+
+				secret := &v1.Secret{}
+				assert.NoError(t, yaml.Unmarshal(secretBytes, secret))
+				assert.Equal(t, "argocd-repo-creds", secret.Name)
+				assert.Equal(t, "git@github.com:", secret.StringData["url"])
+				assert.Equal(t, "private-key", secret.StringData["sshPrivateKey"])
+				assert.NotContains(t, secret.StringData, "username")
+				assert.NotContains(t, secret.StringData, "password")
+			},
+		},
 	}
 
 	for tname, tt := range tests {
 		t.Run(tname, func(t *testing.T) {
-			secret, err := getRepoCredsSecret(tt.username, tt.token, tt.namespace, tt.repoURL)
+			auth := &git.Auth{
+				Username: tt.username,
+				Password: tt.token,
+			}
+			if tt.sshKey != "" {
+				auth.SSHKeyFile = filepath.Join(t.TempDir(), "id_ed25519")
+				assert.NoError(t, os.WriteFile(auth.SSHKeyFile, []byte(tt.sshKey), 0600))
+			}
+
+			secret, err := getRepoCredsSecret(auth, tt.namespace, tt.repoURL)
 			tt.assertFn(t, secret, err)
 		})
 	}
 }
 
+func Test_getRepoCredsManifest(t *testing.T) {
+	tests := map[string]struct {
+		auth     git.Auth
+		opts     *RepoCredsOptions
+		wantErr  string
+		assertFn func(t *testing.T, manifest []byte)
+	}{
+		"Should return a plain secret without options": {
+			auth: git.Auth{Username: "user", Password: "s3cr3t"},
+			assertFn: func(t *testing.T, manifest []byte) {
+				secret := &v1.Secret{}
+				assert.NoError(t, yaml.Unmarshal(manifest, secret))
+				assert.Equal(t, "Secret", secret.Kind)
+				assert.Equal(t, "s3cr3t", secret.StringData["password"])
+			},
+		},
+		"Should return an ExternalSecret that reads the token from the store": {
+			auth: git.Auth{Username: "user", Password: "s3cr3t"},
+			opts: &RepoCredsOptions{
+				Mode:        repoCredsModeExternalSecret,
+				SecretStore: "store",
+				RemoteKey:   "git/token",
+			},
+			assertFn: func(t *testing.T, manifest []byte) {
+				assert.NotContains(t, string(manifest), "s3cr3t")
+				es := &kube.ExternalSecret{}
+				assert.NoError(t, yaml.Unmarshal(manifest, es))
+				assert.Equal(t, "ExternalSecret", es.Kind)
+				assert.Equal(t, "argocd", es.Namespace)
+				assert.Equal(t, kube.SecretStoreRef{Kind: "SecretStore", Name: "store"}, es.Spec.SecretStoreRef)
+				assert.Equal(t, "argocd-repo-creds", es.Spec.Target.Name)
+				assert.Equal(t, "repo-creds", es.Spec.Target.Template.Metadata.Labels["argocd.argoproj.io/secret-type"])
+				assert.Equal(t, "user", es.Spec.Target.Template.Data["username"])
+				assert.Equal(t, "{{ .password }}", es.Spec.Target.Template.Data["password"])
+				assert.Equal(t, []kube.ExternalSecretData{
+					{SecretKey: "password", RemoteRef: kube.ExternalSecretRemoteRef{Key: "git/token"}},
+				}, es.Spec.Data)
+			},
+		},
+		"Should fail if the sealed-secrets certificate is missing": {
+			auth: git.Auth{Username: "user", Password: "s3cr3t"},
+			opts: &RepoCredsOptions{
+				Mode:        repoCredsModeSealedSecret,
+				SealingCert: "/does/not/exist",
+			},
+			wantErr: "failed to read sealed-secrets certificate '/does/not/exist': open /does/not/exist: no such file or directory",
+		},
+		"Should return a SealedSecret": {
+			auth: git.Auth{Username: "user", Password: "s3cr3t"},
+			opts: &RepoCredsOptions{
+				Mode: repoCredsModeSealedSecret,
+			},
+			assertFn: func(t *testing.T, manifest []byte) {
+				assert.NotContains(t, string(manifest), "s3cr3t")
+				sealed := &kube.SealedSecret{}
+				assert.NoError(t, yaml.Unmarshal(manifest, sealed))
+				assert.Equal(t, "SealedSecret", sealed.Kind)
+				assert.Equal(t, "argocd-repo-creds", sealed.Name)
+				assert.Equal(t, "argocd", sealed.Namespace)
+				assert.Equal(t, "repo-creds", sealed.Spec.Template.Labels["argocd.argoproj.io/secret-type"])
+				assert.Len(t, sealed.Spec.EncryptedData, 4)
+			},
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			if tt.opts != nil && tt.opts.Mode == repoCredsModeSealedSecret && tt.opts.SealingCert == "" {
+				tt.opts.SealingCert = filepath.Join(t.TempDir(), "cert.pem")
+				assert.NoError(t, os.WriteFile(tt.opts.SealingCert, generateTestCert(t), 0600))
+			}
+
+			manifest, err := getRepoCredsManifest(&tt.auth, "argocd", "https://github.com/owner/repo.git", tt.opts)
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			tt.assertFn(t, manifest)
+		})
+	}
+}
+
+func generateTestCert(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
 func TestRunRepoBootstrapRecovery(t *testing.T) {
 	exitCalled := false
 	tests := map[string]struct {
-		opts     *RepoBootstrapOptions
-		beforeFn func(*gitmocks.MockRepository, *kubemocks.MockFactory)
-		assertFn func(*testing.T, fs.FS, error)
+		opts      *RepoBootstrapOptions
+		repoCreds []byte
+		beforeFn  func(*gitmocks.MockRepository, *kubemocks.MockFactory)
+		assertFn  func(*testing.T, fs.FS, error)
 	}{
 		"Recovery installation": {
 			opts: &RepoBootstrapOptions{
@@ -503,6 +878,41 @@ func TestRunRepoBootstrapRecovery(t *testing.T) {
 				assert.False(t, exitCalled)
 			},
 		},
+		"Recovery with committed repo creds": {
+			opts: &RepoBootstrapOptions{
+				InstallationMode: installationModeNormal,
+				Namespace:        "bar",
+				Recover:          true,
+				CloneOptions: &git.CloneOptions{
+					Repo: "https://github.com/foo/bar/installation1?ref=main",
+				},
+			},
+			repoCreds: []byte("kind: SealedSecret"),
+			beforeFn: func(r *gitmocks.MockRepository, f *kubemocks.MockFactory) {
+				mockCS := fake.NewSimpleClientset(&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "argocd-initial-admin-secret",
+						Namespace: "bar",
+					},
+					Data: map[string][]byte{
+						"password": []byte("foo"),
+					},
+				})
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				f.EXPECT().Apply(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, manifests []byte) error {
+						assert.Contains(t, string(manifests), "kind: SealedSecret")
+						assert.NotContains(t, string(manifests), "argocd-repo-creds")
+						return nil
+					})
+				f.EXPECT().Apply(gomock.Any(), gomock.Any()).Return(nil)
+				f.EXPECT().Wait(gomock.Any(), gomock.Any()).Return(nil)
+				f.EXPECT().KubernetesClientSetOrDie().Return(mockCS)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, ret error) {
+				assert.NoError(t, ret)
+			},
+		},
 	}
 
 	origExit, origGetRepo, origRunKustomizeBuild, origArgoLogin := exit, getRepo, runKustomizeBuild, argocdLogin
@@ -513,7 +923,7 @@ func TestRunRepoBootstrapRecovery(t *testing.T) {
 		argocdLogin = origArgoLogin
 	}()
 	exit = func(_ int) { exitCalled = true }
-	runKustomizeBuild = func(k *kusttypes.Kustomization) ([]byte, error) { return []byte("test"), nil }
+	runKustomizeBuild = func(k *kusttypes.Kustomization, _ map[string][]byte) ([]byte, error) { return []byte("test"), nil }
 	argocdLogin = func(opts *argocd.LoginOptions) error { return nil }
 
 	for tname, tt := range tests {
@@ -524,6 +934,9 @@ func TestRunRepoBootstrapRecovery(t *testing.T) {
 			repofs := fs.Create(memfs.New())
 			_ = repofs.MkdirAll("bootstrap", 0666)
 			_ = repofs.MkdirAll("projects", 0666)
+			if tt.repoCreds != nil {
+				_ = billyUtils.WriteFile(repofs, repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, repoCredsFileName), tt.repoCreds, 0666)
+			}
 
 			exitCalled = false
 
@@ -702,10 +1115,231 @@ func Test_deleteClusterResources(t *testing.T) {
 	}
 }
 
+func Test_printUninstallPlan(t *testing.T) {
+	tests := map[string]struct {
+		clusterOnly bool
+		wantErr     string
+		beforeFn    func(*kubemocks.MockFactory)
+		assertFn    func(*testing.T, string)
+	}{
+		"Should list repo paths and cluster resources by label selector": {
+			beforeFn: func(f *kubemocks.MockFactory) {
+				f.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, opts *kube.ListOptions) ([]kube.ObjectRef, error) {
+					if opts.LabelSelector != store.Default.LabelKeyAppManagedBy+"="+store.Default.LabelValueManagedBy {
+						return nil, nil
+					}
+
+					return []kube.ObjectRef{
+						{Kind: "Secret", Namespace: "argocd", Name: "autopilot-secret"},
+						{Kind: "Application", Namespace: "argocd", Name: "root"},
+						{Kind: "Application", Namespace: "argocd", Name: "autopilot-bootstrap"},
+						{Kind: "ClusterRole", Name: "some-role"},
+					}, nil
+				}).Times(4)
+			},
+			assertFn: func(t *testing.T, out string) {
+				assert.Contains(t, out, "Repository paths to delete (https://github.com/owner/name.git):\n"+
+					"  apps/app1/base/kustomization.yaml\n"+
+					"  bootstrap/argo-cd.yaml\n"+
+					"  projects/prod.yaml\n")
+				assert.Contains(t, out, "Cluster resources to delete (app.kubernetes.io/managed-by=argocd-autopilot, context: context):\n"+
+					"  KIND         NAMESPACE  NAME                 \n"+
+					"  Application  argocd     autopilot-bootstrap  \n"+
+					"  Application  argocd     root                 \n"+
+					"  ClusterRole  -          some-role            \n"+
+					"  Secret       argocd     autopilot-secret     \n")
+				assert.Contains(t, out, "Cluster resources to delete (app.kubernetes.io/instance=argo-cd, context: context):\n  <none>\n")
+				assert.NotContains(t, out, "README.md")
+			},
+		},
+		"Should not list repo paths with --clusterOnly": {
+			clusterOnly: true,
+			beforeFn: func(f *kubemocks.MockFactory) {
+				f.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, nil).Times(4)
+			},
+			assertFn: func(t *testing.T, out string) {
+				assert.NotContains(t, out, "Repository paths to delete")
+			},
+		},
+		"Should fail if List fails": {
+			wantErr: "failed listing resources with label selector 'app.kubernetes.io/managed-by=argocd-autopilot': some error",
+			beforeFn: func(f *kubemocks.MockFactory) {
+				f.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, errors.New("some error"))
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := kubemocks.NewMockFactory(gomock.NewController(t))
+			tt.beforeFn(f)
+			var repofs fs.FS
+			if !tt.clusterOnly {
+				repofs = fs.Create(memfs.New())
+				_ = billyUtils.WriteFile(repofs, "README.md", []byte{}, 0666)
+				_ = billyUtils.WriteFile(repofs, repofs.Join(store.Default.AppsDir, "app1", "base", "kustomization.yaml"), []byte{}, 0666)
+				_ = billyUtils.WriteFile(repofs, repofs.Join(store.Default.BootsrtrapDir, "argo-cd.yaml"), []byte{}, 0666)
+				_ = billyUtils.WriteFile(repofs, repofs.Join(store.Default.ProjectsDir, "prod.yaml"), []byte{}, 0666)
+			}
+
+			opts := &RepoUninstallOptions{
+				CloneOptions: &git.CloneOptions{
+					Repo: "https://github.com/owner/name",
+				},
+				KubeContextName: "context",
+				KubeFactory:     f,
+				ClusterOnly:     tt.clusterOnly,
+				Out:             &bytes.Buffer{},
+			}
+			opts.CloneOptions.Parse()
+			err := printUninstallPlan(context.Background(), opts, repofs)
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			tt.assertFn(t, opts.Out.(*bytes.Buffer).String())
+		})
+	}
+}
+
+func Test_orphanWorkloads(t *testing.T) {
+	finalizer := argocdv1alpha1.ResourcesFinalizerName
+	newApp := func(name string, finalizers ...string) *argocdv1alpha1.Application {
+		return &argocdv1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  "argocd",
+				Finalizers: finalizers,
+			},
+			Spec: argocdv1alpha1.ApplicationSpec{
+				SyncPolicy: &argocdv1alpha1.SyncPolicy{
+					Automated: &argocdv1alpha1.SyncPolicyAutomated{SelfHeal: true},
+				},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		clusterOnly bool
+		beforeFn    func(*gitmocks.MockRepository, *kubemocks.MockFactory)
+		assertFn    func(*testing.T, fs.FS, argocdcs.Interface, error)
+	}{
+		"Should preserve appsets resources in the repo and remove apps finalizers": {
+			beforeFn: func(r *gitmocks.MockRepository, f *kubemocks.MockFactory) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Autopilot Uninstall, orphan workloads"}).
+					Return("revision", nil)
+				f.EXPECT().Wait(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, cs argocdcs.Interface, err error) {
+				assert.NoError(t, err)
+				_, appSet, err := getProjectInfoFromFile(repofs, repofs.Join(store.Default.ProjectsDir, "project.yaml"))
+				assert.NoError(t, err)
+				assert.True(t, appSet.Spec.SyncPolicy.PreserveResourcesOnDeletion)
+
+				liveAppSet, err := cs.ArgoprojV1alpha1().ApplicationSets("argocd").Get(context.Background(), "project", metav1.GetOptions{})
+				assert.NoError(t, err)
+				assert.True(t, liveAppSet.Spec.SyncPolicy.PreserveResourcesOnDeletion)
+
+				app, err := cs.ArgoprojV1alpha1().Applications("argocd").Get(context.Background(), "project-app", metav1.GetOptions{})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"other-finalizer"}, app.Finalizers)
+
+				app, err = cs.ArgoprojV1alpha1().Applications("argocd").Get(context.Background(), store.Default.BootsrtrapAppName, metav1.GetOptions{})
+				assert.NoError(t, err)
+				assert.Empty(t, app.Finalizers)
+
+				app, err = cs.ArgoprojV1alpha1().Applications("argocd").Get(context.Background(), store.Default.RootAppName, metav1.GetOptions{})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{finalizer}, app.Finalizers)
+				assert.True(t, app.Spec.SyncPolicy.Automated.SelfHeal)
+			},
+		},
+		"Should only patch the live resources with --clusterOnly": {
+			clusterOnly: true,
+			assertFn: func(t *testing.T, repofs fs.FS, cs argocdcs.Interface, err error) {
+				assert.NoError(t, err)
+				_, appSet, err := getProjectInfoFromFile(repofs, repofs.Join(store.Default.ProjectsDir, "project.yaml"))
+				assert.NoError(t, err)
+				assert.Nil(t, appSet.Spec.SyncPolicy)
+
+				liveAppSet, err := cs.ArgoprojV1alpha1().ApplicationSets("argocd").Get(context.Background(), "project", metav1.GetOptions{})
+				assert.NoError(t, err)
+				assert.True(t, liveAppSet.Spec.SyncPolicy.PreserveResourcesOnDeletion)
+
+				app, err := cs.ArgoprojV1alpha1().Applications("argocd").Get(context.Background(), "project-app", metav1.GetOptions{})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"other-finalizer"}, app.Finalizers)
+				assert.NotNil(t, app.Spec.SyncPolicy.Automated)
+
+				for _, name := range []string{store.Default.BootsrtrapAppName, store.Default.RootAppName} {
+					app, err = cs.ArgoprojV1alpha1().Applications("argocd").Get(context.Background(), name, metav1.GetOptions{})
+					assert.NoError(t, err)
+					assert.Nil(t, app.Spec.SyncPolicy.Automated, name+" should not be synced automatically")
+				}
+			},
+		},
+		"Should fail if Persist fails": {
+			beforeFn: func(r *gitmocks.MockRepository, _ *kubemocks.MockFactory) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("", errors.New("some error"))
+			},
+			assertFn: func(t *testing.T, _ fs.FS, _ argocdcs.Interface, err error) {
+				assert.EqualError(t, err, "some error")
+			},
+		},
+	}
+
+	origGetArgoCDClientSet := getArgoCDClientSet
+	defer func() { getArgoCDClientSet = origGetArgoCDClientSet }()
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			r := gitmocks.NewMockRepository(ctrl)
+			f := kubemocks.NewMockFactory(ctrl)
+			if tt.beforeFn != nil {
+				tt.beforeFn(r, f)
+			}
+
+			repofs := fs.Create(memfs.New())
+			assert.NoError(t, repofs.WriteYamls(repofs.Join(store.Default.ProjectsDir, "project.yaml"), &argocdv1alpha1.AppProject{
+				ObjectMeta: metav1.ObjectMeta{Name: "project"},
+			}, &argocdv1alpha1.ApplicationSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "project"},
+			}))
+
+			cs := argocdfake.NewSimpleClientset(
+				newApp(store.Default.BootsrtrapAppName, finalizer),
+				newApp(store.Default.RootAppName, finalizer),
+				newApp("project-app", finalizer, "other-finalizer"),
+				&argocdv1alpha1.ApplicationSet{
+					ObjectMeta: metav1.ObjectMeta{Name: "project", Namespace: "argocd"},
+				},
+			)
+			getArgoCDClientSet = func(_ kube.Factory) (argocdcs.Interface, error) {
+				return cs, nil
+			}
+
+			opts := &RepoUninstallOptions{
+				Namespace:   "argocd",
+				KubeFactory: f,
+				ClusterOnly: tt.clusterOnly,
+			}
+			var err error
+			if tt.clusterOnly {
+				err = orphanWorkloads(context.Background(), opts, nil, nil)
+			} else {
+				err = orphanWorkloads(context.Background(), opts, r, repofs)
+			}
+
+			tt.assertFn(t, repofs, cs, err)
+		})
+	}
+}
+
 func TestRunRepoUninstall(t *testing.T) {
 	tests := map[string]struct {
 		currentKubeContextErr error
 		getRepoErr            error
+		plan                  bool
 		wantErr               string
 		beforeFn              func(*gitmocks.MockRepository, *kubemocks.MockFactory)
 	}{
@@ -799,6 +1433,15 @@ func TestRunRepoUninstall(t *testing.T) {
 				}
 			},
 		},
+		"Should not change anything with --plan": {
+			plan: true,
+			beforeFn: func(r *gitmocks.MockRepository, f *kubemocks.MockFactory) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Times(0)
+				f.EXPECT().Wait(gomock.Any(), gomock.Any()).Times(0)
+				f.EXPECT().Delete(gomock.Any(), gomock.Any()).Times(0)
+				f.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, nil).Times(4)
+			},
+		},
 		"Should succeed if no errors": {
 			beforeFn: func(r *gitmocks.MockRepository, f *kubemocks.MockFactory) {
 				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Autopilot Uninstall"}).
@@ -874,6 +1517,8 @@ func TestRunRepoUninstall(t *testing.T) {
 				},
 				KubeFactory: f,
 				FastExit:    true,
+				Plan:        tt.plan,
+				Out:         &bytes.Buffer{},
 			}
 			opts.CloneOptions.Parse()
 			if err := RunRepoUninstall(context.Background(), opts); err != nil || tt.wantErr != "" {
@@ -882,3 +1527,156 @@ func TestRunRepoUninstall(t *testing.T) {
 		})
 	}
 }
+
+func Test_addArgoCDConfigs(t *testing.T) {
+	tests := map[string]struct {
+		configs  func(t *testing.T) map[string][]string
+		assertFn func(t *testing.T, k *kusttypes.Kustomization, err error)
+	}{
+		"Should merge literals and files into the config maps": {
+			configs: func(t *testing.T) map[string][]string {
+				policy := filepath.Join(t.TempDir(), "policy.csv")
+				assert.NoError(t, os.WriteFile(policy, []byte("g, admins, role:admin"), 0644))
+				return map[string][]string{
+					argocdcommon.ArgoCDConfigMapName:          {"admin.enabled=false", "url=https://argocd.example.com"},
+					argocdcommon.ArgoCDRBACConfigMapName:      {policy},
+					argocdcommon.ArgoCDCmdParamsConfigMapName: nil,
+				}
+			},
+			assertFn: func(t *testing.T, k *kusttypes.Kustomization, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, k.ConfigMapGenerator, 2)
+				assert.Equal(t, argocdcommon.ArgoCDConfigMapName, k.ConfigMapGenerator[0].Name)
+				assert.Equal(t, kusttypes.BehaviorMerge.String(), k.ConfigMapGenerator[0].Behavior)
+				assert.Equal(t, []string{"admin.enabled=false", "url=https://argocd.example.com"}, k.ConfigMapGenerator[0].LiteralSources)
+				assert.Equal(t, argocdcommon.ArgoCDRBACConfigMapName, k.ConfigMapGenerator[1].Name)
+				assert.Equal(t, []string{"policy.csv=g, admins, role:admin"}, k.ConfigMapGenerator[1].LiteralSources)
+			},
+		},
+		"Should fail on a missing key": {
+			configs: func(_ *testing.T) map[string][]string {
+				return map[string][]string{argocdcommon.ArgoCDConfigMapName: {"=false"}}
+			},
+			assertFn: func(t *testing.T, _ *kusttypes.Kustomization, err error) {
+				assert.EqualError(t, err, "failed to get 'argocd-cm' config: missing key in '=false'")
+			},
+		},
+		"Should fail on a missing file": {
+			configs: func(_ *testing.T) map[string][]string {
+				return map[string][]string{argocdcommon.ArgoCDConfigMapName: {"/does/not/exist"}}
+			},
+			assertFn: func(t *testing.T, _ *kusttypes.Kustomization, err error) {
+				assert.ErrorContains(t, err, "failed to read config file '/does/not/exist'")
+			},
+		},
+	}
+
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			k := &kusttypes.Kustomization{}
+			err := addArgoCDConfigs(k, tt.configs(t))
+			tt.assertFn(t, k, err)
+		})
+	}
+}
+
+func Test_addArgoCDPatches(t *testing.T) {
+	tests := map[string]struct {
+		patches  func(dir string) []string
+		assertFn func(t *testing.T, k *kusttypes.Kustomization, files map[string][]byte, err error)
+	}{
+		"Should add strategic merge and JSON6902 patches": {
+			patches: func(dir string) []string {
+				return []string{
+					filepath.Join(dir, "limits.yaml"),
+					"Deployment/argocd-repo-server=" + filepath.Join(dir, "replicas.yaml"),
+				}
+			},
+			assertFn: func(t *testing.T, k *kusttypes.Kustomization, files map[string][]byte, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, k.Patches, 2)
+				assert.Equal(t, "limits.yaml", k.Patches[0].Path)
+				assert.Nil(t, k.Patches[0].Target)
+				assert.Equal(t, "replicas.yaml", k.Patches[1].Path)
+				assert.Equal(t, "Deployment", k.Patches[1].Target.Kind)
+				assert.Equal(t, "argocd-repo-server", k.Patches[1].Target.Name)
+				assert.Equal(t, "limits.yaml", string(files["limits.yaml"]))
+				assert.Equal(t, "replicas.yaml", string(files["replicas.yaml"]))
+			},
+		},
+		"Should fail on an invalid target": {
+			patches: func(dir string) []string {
+				return []string{"Deployment=" + filepath.Join(dir, "limits.yaml")}
+			},
+			assertFn: func(t *testing.T, _ *kusttypes.Kustomization, _ map[string][]byte, err error) {
+				assert.EqualError(t, err, "invalid patch target 'Deployment', expected <kind>/<name>")
+			},
+		},
+		"Should fail on duplicate file names": {
+			patches: func(dir string) []string {
+				return []string{filepath.Join(dir, "limits.yaml"), "Deployment/argocd-server=" + filepath.Join(dir, "limits.yaml")}
+			},
+			assertFn: func(t *testing.T, _ *kusttypes.Kustomization, _ map[string][]byte, err error) {
+				assert.EqualError(t, err, "duplicate patch file name 'limits.yaml'")
+			},
+		},
+	}
+
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range []string{"limits.yaml", "replicas.yaml"} {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+			}
+
+			k := &kusttypes.Kustomization{}
+			files, err := addArgoCDPatches(k, tt.patches(dir))
+			tt.assertFn(t, k, files, err)
+		})
+	}
+}
+
+func Test_getArgoCDComponents(t *testing.T) {
+	deployment := func(name string) []byte {
+		return []byte(fmt.Sprintf("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: %s\n", name))
+	}
+	core := []string{
+		"applications.argoproj.io",
+		"applicationsets.argoproj.io",
+		"appprojects.argoproj.io",
+		"argocd-repo-server",
+		"argocd-application-controller",
+	}
+	tests := map[string]struct {
+		manifests []byte
+		want      []string
+	}{
+		"Should wait for the optional components that are in the manifests": {
+			manifests: util.JoinManifests(
+				deployment("argocd-repo-server"),
+				deployment("argocd-server"),
+				deployment("argocd-redis-ha-haproxy"),
+				[]byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: argocd-dex-server\n"),
+			),
+			want: append(core, "argocd-server", "argocd-redis-ha-haproxy"),
+		},
+		"Should only wait for the core components of a core installation": {
+			manifests: util.JoinManifests(deployment("argocd-repo-server"), deployment("argocd-redis")),
+			want:      append(core, "argocd-redis"),
+		},
+		"Should wait for all of the components without manifests": {
+			want: append(core, "argocd-server", "argocd-applicationset-controller", "argocd-dex-server", "argocd-redis", "argocd-redis-ha-haproxy"),
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			resources := getArgoCDComponents("argocd", tt.manifests)
+			got := make([]string, 0, len(resources))
+			for _, r := range resources {
+				got = append(got, r.Name)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
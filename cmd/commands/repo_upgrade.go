@@ -0,0 +1,266 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/application"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	fsutils "github.com/argoproj-labs/argocd-autopilot/pkg/fs/utils"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/spf13/cobra"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+)
+
+type (
+	RepoUpgradeOptions struct {
+		AppSpecifier    string
+		Namespace       string
+		KubeContextName string
+		Insecure        bool
+		Timeout         time.Duration
+		KubeFactory     kube.Factory
+		CloneOptions    *git.CloneOptions
+	}
+)
+
+func NewRepoUpgradeCommand() *cobra.Command {
+	var (
+		appSpecifier string
+		insecure     bool
+		cloneOpts    *git.CloneOptions
+		f            kube.Factory
+	)
+
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade the Argo-CD installation to a new version",
+		Example: util.Doc(`
+# To run this command you need to create a personal access token for your git provider,
+# and have a bootstrapped GitOps repository, and provide them using:
+
+		export GIT_TOKEN=<token>
+		export GIT_REPO=<repo_url>
+
+# or with the flags:
+
+		--git-token <token> --repo <repo_url>
+
+# Upgrade argo-cd to the version that matches this <BIN> version
+
+	<BIN> repo upgrade
+
+# Upgrade argo-cd to a specific version
+
+	<BIN> repo upgrade --app github.com/argoproj-labs/argocd-autopilot/manifests?ref=v0.4.20
+`),
+		PreRun: func(_ *cobra.Command, _ []string) { cloneOpts.Parse() },
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return RunRepoUpgrade(cmd.Context(), &RepoUpgradeOptions{
+				AppSpecifier:    appSpecifier,
+				KubeContextName: cmd.Flag("context").Value.String(),
+				Insecure:        insecure,
+				Timeout:         util.MustParseDuration(cmd.Flag("request-timeout").Value.String()),
+				KubeFactory:     f,
+				CloneOptions:    cloneOpts,
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&appSpecifier, "app", "", "The application specifier (e.g. github.com/argoproj-labs/argocd-autopilot/manifests?ref=v0.2.5), overrides the default installation argo-cd manifests")
+	cmd.Flags().BoolVar(&insecure, "insecure", false, "Use the insecure (no TLS) argo-cd manifests when --app is not specified")
+
+	cloneOpts = git.AddFlags(cmd, &git.AddFlagsOptions{
+		FS:            memfs.New(),
+		CloneForWrite: true,
+	})
+	f = kube.AddFlags(cmd.Flags())
+
+	return cmd
+}
+
+func RunRepoUpgrade(ctx context.Context, opts *RepoUpgradeOptions) error {
+	var err error
+
+	if opts, err = setUpgradeOptsDefaults(*opts); err != nil {
+		return err
+	}
+
+	r, repofs, err := prepareRepo(ctx, opts.CloneOptions, "")
+	if err != nil {
+		return err
+	}
+
+	opts.Namespace, err = getInstallationNamespace(repofs)
+	if err != nil {
+		return err
+	}
+
+	log.G(ctx).WithFields(log.Fields{
+		"repo-url":      opts.CloneOptions.URL(),
+		"revision":      opts.CloneOptions.Revision(),
+		"namespace":     opts.Namespace,
+		"kube-context":  opts.KubeContextName,
+		"app-specifier": opts.AppSpecifier,
+	}).Debug("starting with options: ")
+
+	if err = upgradeArgoCDManifests(repofs, opts.Namespace, opts.AppSpecifier, opts.CloneOptions); err != nil {
+		return fmt.Errorf("failed to upgrade argo-cd manifests: %w", err)
+	}
+
+	log.G(ctx).Infof("pushing upgraded argo-cd manifests to repo")
+	revision, err := r.Persist(ctx, &git.PushOptions{CommitMsg: "Autopilot Upgrade to " + opts.AppSpecifier})
+	if err != nil {
+		return err
+	}
+
+	stop := util.WithSpinner(ctx, fmt.Sprintf("waiting for '%s' to finish syncing", store.Default.ArgoCDName))
+	if err = waitAppSynced(ctx, opts.KubeFactory, opts.Timeout, store.Default.ArgoCDName, opts.Namespace, revision, false); err != nil {
+		stop()
+		return fmt.Errorf("failed waiting for '%s' application to sync: %w", store.Default.ArgoCDName, err)
+	}
+
+	stop()
+
+	stop = util.WithSpinner(ctx, "waiting for argo-cd to be ready")
+	if err = waitClusterReady(ctx, opts.KubeFactory, opts.Timeout, opts.Namespace, nil); err != nil {
+		stop()
+		return err
+	}
+
+	stop()
+
+	log.G(ctx).Infof("argo-cd upgraded to: %s", opts.AppSpecifier)
+	return nil
+}
+
+func setUpgradeOptsDefaults(opts RepoUpgradeOptions) (*RepoUpgradeOptions, error) {
+	var err error
+
+	if opts.AppSpecifier == "" {
+		opts.AppSpecifier = getBootstrapAppSpecifier(opts.Insecure)
+	}
+
+	if opts.KubeContextName == "" {
+		opts.KubeContextName, err = currentKubeContext()
+		if err != nil {
+			return &opts, err
+		}
+	}
+
+	return &opts, nil
+}
+
+// upgradeArgoCDManifests rewrites the argo-cd installation in the bootstrap dir to use appSpecifier.
+// In normal mode only the argo-cd resource of the existing kustomization is replaced (or vendored
+// again, in an airgapped installation), so any other customization is kept. In flat mode the
+// install.yaml is re-rendered from the new specifier, keeping the argo-cd config maps and the image
+// registry mirror of the current installation.
+func upgradeArgoCDManifests(repofs fs.FS, namespace, appSpecifier string, cloneOpts *git.CloneOptions) error {
+	argocdPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName)
+	kustPath := repofs.Join(argocdPath, "kustomization.yaml")
+	if repofs.ExistsOrDie(kustPath) {
+		if _, err := os.Stat(appSpecifier); err == nil {
+			return fmt.Errorf("local argo-cd manifests can not be used with a normal installation mode")
+		}
+
+		k := &kusttypes.Kustomization{}
+		if err := repofs.ReadYamls(kustPath, k); err != nil {
+			return fmt.Errorf("failed to read '%s': %w", kustPath, err)
+		}
+
+		i := getArgoCDResourceIndex(repofs, k)
+		if i < 0 {
+			return fmt.Errorf("no argo-cd resource found in '%s'", kustPath)
+		}
+
+		if k.Resources[i] == vendoredManifestsFileName {
+			return revendorArgoCDManifests(repofs, k, appSpecifier)
+		}
+
+		k.Resources[i] = appSpecifier
+		return repofs.WriteYamls(kustPath, k)
+	}
+
+	installPath := repofs.Join(argocdPath, "install.yaml")
+	if !repofs.ExistsOrDie(installPath) {
+		return fmt.Errorf("argo-cd manifests not found in '%s'", argocdPath)
+	}
+
+	current, err := repofs.ReadFile(installPath)
+	if err != nil {
+		return fmt.Errorf("failed to read '%s': %w", installPath, err)
+	}
+
+	k, err := createBootstrapKustomization(namespace, appSpecifier, cloneOpts)
+	if err != nil {
+		return err
+	}
+
+	upstream, err := addArgoCDCustomizations(k, current)
+	if err != nil {
+		return err
+	}
+
+	log.G().Warnf("re-rendering '%s': the argo-cd config maps and image registry mirror are kept, but any other change to it is discarded. Use the normal installation mode to keep patches across upgrades", installPath)
+	k.Resources = []string{vendoredManifestsFileName}
+	manifests, err := runKustomizeBuild(k, map[string][]byte{vendoredManifestsFileName: upstream})
+	if err != nil {
+		return err
+	}
+
+	return fsutils.BulkWrite(repofs, fsutils.BulkWriteRequest{Filename: installPath, Data: manifests})
+}
+
+// getArgoCDResourceIndex returns the index of the argo-cd manifests in the resources of k, which is
+// the first resource that is not one of the local files of the argo-cd dir (like the committed repo
+// creds, or resources that were added by the user). The vendored manifests of an airgapped
+// installation are returned as well. Returns -1 if there is no such resource.
+func getArgoCDResourceIndex(repofs fs.FS, k *kusttypes.Kustomization) int {
+	argocdPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName)
+	for i, r := range k.Resources {
+		if r == vendoredManifestsFileName || !repofs.ExistsOrDie(repofs.Join(argocdPath, r)) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// revendorArgoCDManifests replaces the vendored argo-cd manifests of an airgapped installation with
+// the manifests of appSpecifier. If the images of k were mirrored, the new images are mirrored to
+// the same registry.
+func revendorArgoCDManifests(repofs fs.FS, k *kusttypes.Kustomization, appSpecifier string) error {
+	argocdPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName)
+	mirror := application.GetImageRegistryMirror(k.Images)
+	if len(k.Images) > 0 && mirror == "" {
+		log.G().Warn("could not detect the image registry mirror of the installation, keeping the current images")
+	}
+
+	upstream := &kusttypes.Kustomization{
+		TypeMeta:  k.TypeMeta,
+		Resources: []string{appSpecifier},
+	}
+	vendored, err := vendorArgoCDManifests(upstream, mirror)
+	if err != nil {
+		return err
+	}
+
+	if mirror != "" {
+		k.Images = upstream.Images
+	}
+
+	if err = repofs.WriteYamls(repofs.Join(argocdPath, "kustomization.yaml"), k); err != nil {
+		return err
+	}
+
+	return fsutils.BulkWrite(repofs, fsutils.BulkWriteRequest{Filename: repofs.Join(argocdPath, vendoredManifestsFileName), Data: vendored})
+}
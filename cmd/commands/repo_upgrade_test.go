@@ -0,0 +1,248 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/git"
+	gitmocks "github.com/argoproj-labs/argocd-autopilot/pkg/git/mocks"
+	kubemocks "github.com/argoproj-labs/argocd-autopilot/pkg/kube/mocks"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/go-git/go-billy/v5/memfs"
+	billyUtils "github.com/go-git/go-billy/v5/util"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+)
+
+func Test_upgradeArgoCDManifests(t *testing.T) {
+	tests := map[string]struct {
+		appSpecifier string
+		beforeFn     func(t *testing.T, repofs fs.FS)
+		assertFn     func(t *testing.T, repofs fs.FS, err error)
+	}{
+		"Normal installation": {
+			appSpecifier: "github.com/foo/bar/manifests?ref=v2",
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, repofs.WriteYamls(repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, "kustomization.yaml"), &kusttypes.Kustomization{
+					Resources: []string{"github.com/foo/bar/manifests?ref=v1"},
+					Namespace: "argocd",
+				}))
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				k := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, "kustomization.yaml"), k))
+				assert.Equal(t, []string{"github.com/foo/bar/manifests?ref=v2"}, k.Resources)
+				assert.Equal(t, "argocd", k.Namespace)
+			},
+		},
+		"Airgapped normal installation": {
+			appSpecifier: "github.com/foo/bar/manifests?ref=v2",
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				argocdPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName)
+				assert.NoError(t, repofs.WriteYamls(repofs.Join(argocdPath, "kustomization.yaml"), &kusttypes.Kustomization{
+					Resources: []string{"vendored-install.yaml", "repo-creds.yaml"},
+					Namespace: "argocd",
+					Images: []kusttypes.Image{
+						{Name: "quay.io/argoproj/argocd", NewName: "registry.local/argoproj/argocd"},
+					},
+				}))
+				assert.NoError(t, billyUtils.WriteFile(repofs, repofs.Join(argocdPath, "vendored-install.yaml"), []byte("old"), 0666))
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				argocdPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName)
+				k := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(repofs.Join(argocdPath, "kustomization.yaml"), k))
+				assert.Equal(t, []string{"vendored-install.yaml", "repo-creds.yaml"}, k.Resources)
+				assert.Equal(t, "argocd", k.Namespace)
+				assert.Equal(t, []kusttypes.Image{
+					{Name: "ghcr.io/dexidp/dex", NewName: "registry.local/dexidp/dex"},
+					{Name: "quay.io/argoproj/argocd", NewName: "registry.local/argoproj/argocd"},
+				}, k.Images)
+				data, err := repofs.ReadFile(repofs.Join(argocdPath, "vendored-install.yaml"))
+				assert.NoError(t, err)
+				assert.Contains(t, string(data), "quay.io/argoproj/argocd:v2")
+			},
+		},
+		"Normal installation with user resources": {
+			appSpecifier: "github.com/foo/bar/manifests?ref=v2",
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				argocdPath := repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName)
+				assert.NoError(t, repofs.WriteYamls(repofs.Join(argocdPath, "kustomization.yaml"), &kusttypes.Kustomization{
+					Resources: []string{"ingress.yaml", "github.com/foo/bar/manifests?ref=v1", "repo-creds.yaml"},
+					Patches:   []kusttypes.Patch{{Path: "limits.yaml"}},
+				}))
+				assert.NoError(t, billyUtils.WriteFile(repofs, repofs.Join(argocdPath, "ingress.yaml"), []byte("ingress"), 0666))
+				assert.NoError(t, billyUtils.WriteFile(repofs, repofs.Join(argocdPath, "repo-creds.yaml"), []byte("creds"), 0666))
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				k := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, "kustomization.yaml"), k))
+				assert.Equal(t, []string{"ingress.yaml", "github.com/foo/bar/manifests?ref=v2", "repo-creds.yaml"}, k.Resources)
+				assert.Equal(t, []kusttypes.Patch{{Path: "limits.yaml"}}, k.Patches)
+			},
+		},
+		"Flat installation": {
+			appSpecifier: "github.com/foo/bar/manifests?ref=v2",
+			beforeFn: func(t *testing.T, repofs fs.FS) {
+				assert.NoError(t, billyUtils.WriteFile(repofs, repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, "install.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: argocd-cm
+data:
+  admin.enabled: "false"
+  url: https://argocd.example.com
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other-cm
+data:
+  key: value
+---
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - image: registry.local/argoproj/argocd:v1
+      - image: registry.local/dexidp/dex:v1
+`), 0666))
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				k := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, "install.yaml"), k))
+				assert.Equal(t, []string{"vendored-install.yaml"}, k.Resources)
+				assert.Equal(t, "argocd", k.Namespace)
+				assert.Len(t, k.ConfigMapGenerator, 1)
+				assert.Equal(t, "argocd-cm", k.ConfigMapGenerator[0].Name)
+				assert.Equal(t, []string{"admin.enabled=false", "url=https://argocd.example.com"}, k.ConfigMapGenerator[0].LiteralSources)
+				assert.Equal(t, []kusttypes.Image{
+					{Name: "ghcr.io/dexidp/dex", NewName: "registry.local/dexidp/dex"},
+					{Name: "quay.io/argoproj/argocd", NewName: "registry.local/argoproj/argocd"},
+				}, k.Images)
+			},
+		},
+		"Missing argo-cd manifests": {
+			appSpecifier: "github.com/foo/bar/manifests?ref=v2",
+			beforeFn:     func(_ *testing.T, _ fs.FS) {},
+			assertFn: func(t *testing.T, _ fs.FS, err error) {
+				assert.EqualError(t, err, "argo-cd manifests not found in 'bootstrap/argo-cd'")
+			},
+		},
+	}
+
+	orgRunKustomizeBuild := runKustomizeBuild
+	defer func() { runKustomizeBuild = orgRunKustomizeBuild }()
+
+	runKustomizeBuild = func(k *kusttypes.Kustomization, files map[string][]byte) ([]byte, error) {
+		if files != nil {
+			// the flat installation is rendered from the vendored manifests
+			assert.Contains(t, string(files["vendored-install.yaml"]), "quay.io/argoproj/argocd:v2")
+			return yaml.Marshal(k)
+		}
+
+		if len(k.Resources) == 1 && k.Namespace == "" {
+			// fetching the remote manifests to vendor them
+			return []byte(`kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - image: quay.io/argoproj/argocd:v2
+      - image: ghcr.io/dexidp/dex:v2
+`), nil
+		}
+
+		return []byte(k.Resources[0]), nil
+	}
+
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := fs.Create(memfs.New())
+			tt.beforeFn(t, repofs)
+			cloneOpts := &git.CloneOptions{Repo: "https://github.com/foo/bar"}
+			cloneOpts.Parse()
+
+			err := upgradeArgoCDManifests(repofs, "argocd", tt.appSpecifier, cloneOpts)
+			tt.assertFn(t, repofs, err)
+		})
+	}
+}
+
+func TestRunRepoUpgrade(t *testing.T) {
+	tests := map[string]struct {
+		opts     *RepoUpgradeOptions
+		beforeFn func(*gitmocks.MockRepository, *kubemocks.MockFactory)
+		assertFn func(*testing.T, fs.FS, error)
+	}{
+		"Should commit the new specifier and wait for argo-cd": {
+			opts: &RepoUpgradeOptions{
+				AppSpecifier:    "github.com/foo/bar/manifests?ref=v2",
+				KubeContextName: "context",
+			},
+			beforeFn: func(r *gitmocks.MockRepository, f *kubemocks.MockFactory) {
+				r.EXPECT().Persist(gomock.Any(), &git.PushOptions{CommitMsg: "Autopilot Upgrade to github.com/foo/bar/manifests?ref=v2"}).Return("revision", nil)
+				f.EXPECT().Wait(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				k := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, "kustomization.yaml"), k))
+				assert.Equal(t, "github.com/foo/bar/manifests?ref=v2", k.Resources[0])
+			},
+		},
+		"Should fail if persist fails": {
+			opts: &RepoUpgradeOptions{
+				AppSpecifier:    "github.com/foo/bar/manifests?ref=v2",
+				KubeContextName: "context",
+			},
+			beforeFn: func(r *gitmocks.MockRepository, _ *kubemocks.MockFactory) {
+				r.EXPECT().Persist(gomock.Any(), gomock.Any()).Return("", errors.New("some error"))
+			},
+			assertFn: func(t *testing.T, _ fs.FS, err error) {
+				assert.EqualError(t, err, "some error")
+			},
+		},
+	}
+
+	origGetRepo := getRepo
+	defer func() { getRepo = origGetRepo }()
+
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			r := gitmocks.NewMockRepository(ctrl)
+			f := kubemocks.NewMockFactory(ctrl)
+			repofs := fs.Create(memfs.New())
+			assert.NoError(t, repofs.WriteYamls(repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName+".yaml"), &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Destination: argocdv1alpha1.ApplicationDestination{Namespace: "argocd"},
+				},
+			}))
+			assert.NoError(t, repofs.WriteYamls(repofs.Join(store.Default.BootsrtrapDir, store.Default.ArgoCDName, "kustomization.yaml"), &kusttypes.Kustomization{
+				Resources: []string{"github.com/foo/bar/manifests?ref=v1"},
+			}))
+
+			tt.beforeFn(r, f)
+			tt.opts.KubeFactory = f
+			tt.opts.CloneOptions = &git.CloneOptions{Repo: "https://github.com/foo/bar"}
+			tt.opts.CloneOptions.Parse()
+			getRepo = func(_ context.Context, _ *git.CloneOptions) (git.Repository, fs.FS, error) {
+				return r, repofs, nil
+			}
+
+			err := RunRepoUpgrade(context.Background(), tt.opts)
+			tt.assertFn(t, repofs, err)
+		})
+	}
+}
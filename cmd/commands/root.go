@@ -36,6 +36,7 @@ variables in advanced to simplify the use of those commands.
 	cmd.AddCommand(NewVersionCommand())
 	cmd.AddCommand(NewRepoCommand())
 	cmd.AddCommand(NewProjectCommand())
+	cmd.AddCommand(NewClusterCommand())
 	cmd.AddCommand(NewAppCommand())
 
 	cobra.OnInitialize(func() { postInitCommands(cmd.Commands()) })
@@ -0,0 +1,169 @@
+package application
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+)
+
+// the fields that hold a list of containers, in any kind of resource
+var containerFields = map[string]bool{
+	"containers":          true,
+	"initContainers":      true,
+	"ephemeralContainers": true,
+}
+
+// GenerateImageMirrors returns a kustomize images entry for every container image in manifests,
+// that changes the image registry to mirror. The image path and tag are kept, so
+// quay.io/argoproj/argocd:v2 is pulled from <mirror>/argoproj/argocd:v2
+func GenerateImageMirrors(manifests []byte, mirror string) ([]kusttypes.Image, error) {
+	mirror = strings.TrimSuffix(mirror, "/")
+	names, err := getImageNames(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]kusttypes.Image, 0, len(names))
+	for name := range names {
+		images = append(images, kusttypes.Image{
+			Name:    name,
+			NewName: mirrorImageName(name, mirror),
+		})
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Name < images[j].Name
+	})
+
+	return images, nil
+}
+
+// GetImageRegistryMirror returns the registry mirror that images were generated with by
+// GenerateImageMirrors, or an empty string if they do not all share the same mirror
+func GetImageRegistryMirror(images []kusttypes.Image) string {
+	mirror := ""
+	for _, image := range images {
+		path := mirrorImageName(image.Name, "")
+		if !strings.HasSuffix(image.NewName, path) {
+			return ""
+		}
+
+		m := strings.TrimSuffix(image.NewName, path)
+		if m == "" || (mirror != "" && m != mirror) {
+			return ""
+		}
+
+		mirror = m
+	}
+
+	return mirror
+}
+
+// DetectImageRegistryMirror returns the registry mirror that the images of upstream are pulled
+// from in manifests, or an empty string if they are not mirrored
+func DetectImageRegistryMirror(manifests, upstream []byte) (string, error) {
+	current, err := getImageNames(manifests)
+	if err != nil {
+		return "", err
+	}
+
+	upstreamNames, err := getImageNames(upstream)
+	if err != nil {
+		return "", err
+	}
+
+	mirror := ""
+	for name := range upstreamNames {
+		if current[name] {
+			// with a mirror, none of the upstream images are pulled from their own registry
+			return "", nil
+		}
+
+		path := mirrorImageName(name, "")
+		for c := range current {
+			if !strings.HasSuffix(c, path) {
+				continue
+			}
+
+			m := strings.TrimSuffix(c, path)
+			if m == "" || (mirror != "" && m != mirror) {
+				return "", nil
+			}
+
+			mirror = m
+		}
+	}
+
+	return mirror, nil
+}
+
+func getImageNames(manifests []byte) (map[string]bool, error) {
+	names := map[string]bool{}
+	for _, manifest := range util.SplitManifests(manifests) {
+		var obj interface{}
+		if err := yaml.Unmarshal(manifest, &obj); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+		}
+
+		collectImageNames(obj, names)
+	}
+
+	return names, nil
+}
+
+func collectImageNames(obj interface{}, names map[string]bool) {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		for k, v := range o {
+			containers, ok := v.([]interface{})
+			if !ok || !containerFields[k] {
+				collectImageNames(v, names)
+				continue
+			}
+
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				if image, ok := container["image"].(string); ok && image != "" {
+					names[imageName(image)] = true
+				}
+			}
+		}
+	case []interface{}:
+		for _, v := range o {
+			collectImageNames(v, names)
+		}
+	}
+}
+
+// imageName returns the image without its tag or digest
+func imageName(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+
+	return image
+}
+
+// mirrorImageName replaces the registry of the image name with mirror. Images without a
+// registry (e.g. "redis" or "library/redis") are moved under the mirror as is
+func mirrorImageName(name, mirror string) string {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		name = parts[1]
+	}
+
+	return mirror + "/" + name
+}
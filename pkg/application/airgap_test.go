@@ -0,0 +1,174 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+)
+
+func TestGenerateImageMirrors(t *testing.T) {
+	manifests := []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: argocd-server
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: copyutil
+        image: quay.io/argoproj/argocd:v3.1.5
+      containers:
+      - name: server
+        image: quay.io/argoproj/argocd:v3.1.5
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: argocd-redis
+spec:
+  template:
+    spec:
+      containers:
+      - name: redis
+        image: redis:7.2@sha256:abcdef
+      - name: exporter
+        image: localhost:5000/exporter
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: job
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: job
+            image: ghcr.io/dexidp/dex:v2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  image: not-an-image
+`)
+
+	images, err := GenerateImageMirrors(manifests, "registry.local/")
+	assert.NoError(t, err)
+	assert.Equal(t, []kusttypes.Image{
+		{Name: "ghcr.io/dexidp/dex", NewName: "registry.local/dexidp/dex"},
+		{Name: "localhost:5000/exporter", NewName: "registry.local/exporter"},
+		{Name: "quay.io/argoproj/argocd", NewName: "registry.local/argoproj/argocd"},
+		{Name: "redis", NewName: "registry.local/redis"},
+	}, images)
+}
+
+func TestGenerateImageMirrors_invalidYaml(t *testing.T) {
+	_, err := GenerateImageMirrors([]byte("kind: [\n"), "registry.local")
+	assert.ErrorContains(t, err, "failed to unmarshal manifest")
+}
+
+func TestGetImageRegistryMirror(t *testing.T) {
+	tests := map[string]struct {
+		images []kusttypes.Image
+		want   string
+	}{
+		"Should return the mirror of generated images": {
+			images: []kusttypes.Image{
+				{Name: "ghcr.io/dexidp/dex", NewName: "registry.local/dexidp/dex"},
+				{Name: "redis", NewName: "registry.local/redis"},
+			},
+			want: "registry.local",
+		},
+		"Should return empty when images use different mirrors": {
+			images: []kusttypes.Image{
+				{Name: "ghcr.io/dexidp/dex", NewName: "registry.local/dexidp/dex"},
+				{Name: "redis", NewName: "other.local/redis"},
+			},
+		},
+		"Should return empty when an image is not mirrored": {
+			images: []kusttypes.Image{
+				{Name: "redis", NewTag: "7"},
+			},
+		},
+		"Should return empty without images": {},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetImageRegistryMirror(tt.images))
+		})
+	}
+}
+
+func TestDetectImageRegistryMirror(t *testing.T) {
+	upstream := []byte(`kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - image: quay.io/argoproj/argocd:v3.1.5
+      - image: redis:7.2
+`)
+	tests := map[string]struct {
+		manifests string
+		want      string
+	}{
+		"Should detect the mirror of all images": {
+			manifests: `kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - image: registry.local/argoproj/argocd:v3.0.0
+      - image: registry.local/redis:7.0
+`,
+			want: "registry.local",
+		},
+		"Should return empty when images are not mirrored": {
+			manifests: `kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - image: quay.io/argoproj/argocd:v3.0.0
+      - image: public.ecr.aws/docker/library/redis:7.0
+`,
+		},
+		"Should return empty when images use different mirrors": {
+			manifests: `kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - image: registry.local/argoproj/argocd:v3.0.0
+      - image: other.local/redis:7.0
+`,
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			got, err := DetectImageRegistryMirror([]byte(tt.manifests), upstream)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_mirrorImageName(t *testing.T) {
+	tests := map[string]string{
+		"redis":                      "mirror/redis",
+		"library/redis":              "mirror/library/redis",
+		"docker.io/library/redis":    "mirror/library/redis",
+		"quay.io/argoproj/argocd":    "mirror/argoproj/argocd",
+		"registry:5000/team/service": "mirror/team/service",
+		"localhost/service":          "mirror/service",
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, want, mirrorImageName(name, "mirror"))
+		})
+	}
+}
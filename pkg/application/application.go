@@ -9,11 +9,13 @@ import (
 	"reflect"
 
 	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	fsutils "github.com/argoproj-labs/argocd-autopilot/pkg/fs/utils"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
 
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	billyUtils "github.com/go-git/go-billy/v5/util"
 	"github.com/spf13/cobra"
 	v1 "k8s.io/api/core/v1"
@@ -43,6 +45,8 @@ var (
 	ErrAppAlreadyInstalledOnProject = errors.New("application already installed on project")
 	ErrAppCollisionWithExistingBase = errors.New("an application with the same name and a different base already exists, consider choosing a different name")
 	ErrUnknownAppType               = errors.New("unknown application type")
+	ErrAirgapNotSupported           = errors.New("airgap mode is only supported for kustomize applications")
+	ErrImageMirrorWithoutAirgap     = errors.New("image registry mirror can only be used in airgap mode")
 )
 
 type (
@@ -62,24 +66,32 @@ type (
 		SrcTargetRevision string            `json:"srcTargetRevision"`
 		Labels            map[string]string `json:"labels"`
 		Annotations       map[string]string `json:"annotations"`
+		// SyncPolicy replaces the sync policy of the project for this app, if set
+		SyncPolicy *argocdv1alpha1.SyncPolicy `json:"syncPolicy,omitempty"`
 	}
 
 	ClusterResConfig struct {
 		Name   string `json:"name"`
 		Server string `json:"server"`
+		// Labels are the labels of the argo-cd cluster secret
+		Labels map[string]string `json:"labels,omitempty"`
 	}
 
 	CreateOptions struct {
-		AppName          string
-		AppType          string
-		AppSpecifier     string
-		DestNamespace    string
-		DestServer       string
-		InstallationMode string
-		Labels           map[string]string
-		Annotations      map[string]string
-		Exclude          string
-		Include          string
+		AppName             string
+		AppType             string
+		AppSpecifier        string
+		DestNamespace       string
+		DestServer          string
+		InstallationMode    string
+		Labels              map[string]string
+		Annotations         map[string]string
+		Exclude             string
+		Include             string
+		Airgap              bool
+		ImageRegistryMirror string
+		// SyncPolicy replaces the sync policy of the project for this app, if not nil
+		SyncPolicy *argocdv1alpha1.SyncPolicy
 	}
 
 	baseApp struct {
@@ -120,6 +132,8 @@ func AddFlags(cmd *cobra.Command) *CreateOptions {
 	cmd.Flags().StringToStringVar(&opts.Annotations, "annotations", nil, "Optional annotations that will be set on the Application resource. (e.g. \"{{ placeholder }}=my-org\"")
 	cmd.Flags().StringVar(&opts.Include, "include", "", "Optional glob for files to include")
 	cmd.Flags().StringVar(&opts.Exclude, "exclude", "", "Optional glob for files to exclude")
+	cmd.Flags().BoolVar(&opts.Airgap, "airgap", false, "If true, will build the application manifests locally and commit them (flat installation mode), so no remote resources are needed")
+	cmd.Flags().StringVar(&opts.ImageRegistryMirror, "image-registry-mirror", "", "Used with --airgap, the registry that all of the application images will be pulled from (e.g. registry.local)")
 
 	return opts
 }
@@ -179,6 +193,72 @@ func DeleteFromProject(repofs fs.FS, appName, projectName string) error {
 	return nil
 }
 
+// RenameInProject moves the app from projectName to newProjectName, and updates the
+// source path of the app, if it is an overlay in this repo
+func RenameInProject(repofs fs.FS, appName, projectName, newProjectName string) error {
+	srcDir, copied, err := copyToProject(repofs, appName, projectName, newProjectName)
+	if err != nil || !copied {
+		return err
+	}
+
+	if err = billyUtils.RemoveAll(repofs, srcDir); err != nil {
+		return fmt.Errorf("failed to delete directory '%s': %w", srcDir, err)
+	}
+
+	return nil
+}
+
+// CopyToProject copies the app from projectName to newProjectName, including any patches in
+// its overlay, and updates the source path of the copy, if it is an overlay in this repo
+func CopyToProject(repofs fs.FS, appName, projectName, newProjectName string) error {
+	_, _, err := copyToProject(repofs, appName, projectName, newProjectName)
+	return err
+}
+
+// copyToProject returns the directory of the app in projectName, and whether it was copied
+func copyToProject(repofs fs.FS, appName, projectName, newProjectName string) (string, bool, error) {
+	appDir := repofs.Join(store.Default.AppsDir, appName)
+	srcDir := repofs.Join(appDir, projectName)
+	dstDir := repofs.Join(appDir, newProjectName)
+	if overlays := repofs.Join(appDir, store.Default.OverlaysDir); repofs.ExistsOrDie(overlays) {
+		// kustApp
+		srcDir = repofs.Join(overlays, projectName)
+		dstDir = repofs.Join(overlays, newProjectName)
+	} else if repofs.ExistsOrDie(repofs.Join(srcDir, "config.json")) {
+		// kustApp, with the overlay in the apps repo
+		return "", false, fmt.Errorf("the overlays of app '%s' are in another repository, which can not be updated", appName)
+	}
+
+	if !repofs.ExistsOrDie(srcDir) {
+		return srcDir, false, nil
+	}
+
+	if repofs.ExistsOrDie(dstDir) {
+		return "", false, fmt.Errorf("app '%s' already exists in project '%s'", appName, newProjectName)
+	}
+
+	if err := fsutils.CopyDir(repofs, srcDir, dstDir); err != nil {
+		return "", false, fmt.Errorf("failed to copy '%s' to '%s': %w", srcDir, dstDir, err)
+	}
+
+	configPath := repofs.Join(dstDir, "config.json")
+	if !repofs.ExistsOrDie(configPath) {
+		return srcDir, true, nil
+	}
+
+	// keep any other field in the config as is
+	config := map[string]interface{}{}
+	if err := repofs.ReadJson(configPath, &config); err != nil {
+		return "", false, fmt.Errorf("failed to read '%s': %w", configPath, err)
+	}
+
+	if srcPath, ok := config["srcPath"].(string); ok && path.Base(srcPath) == projectName {
+		config["srcPath"] = path.Join(path.Dir(srcPath), newProjectName)
+	}
+
+	return srcDir, true, repofs.WriteJson(configPath, config)
+}
+
 func isInProject(allProjects []os.FileInfo, projectName string) bool {
 	for _, project := range allProjects {
 		if project.Name() == projectName {
@@ -192,13 +272,16 @@ func isInProject(allProjects []os.FileInfo, projectName string) bool {
 // GenerateManifests writes the in-memory kustomization to disk, fixes relative resources and
 // runs kustomize build, then returns the generated manifests.
 //
+// Each of files is written next to the kustomization.yaml, so the kustomization can reference
+// it by its name (as a resource, or a patch path).
+//
 // If there is a namespace on 'k' a namespace.yaml file with the namespace object will be
 // written next to the persisted kustomization.yaml.
 //
 // To include the namespace in the generated
 // manifests just add 'namespace.yaml' to the resources of the kustomization
-func GenerateManifests(k *kusttypes.Kustomization) ([]byte, error) {
-	return generateManifests(k)
+func GenerateManifests(k *kusttypes.Kustomization, files map[string][]byte) ([]byte, error) {
+	return generateManifests(k, files)
 }
 
 /* CreateOptions impl */
@@ -208,6 +291,10 @@ func (o *CreateOptions) Parse(projectName, repoURL, targetRevision, repoRoot str
 	case AppTypeKustomize:
 		return newKustApp(o, projectName, repoURL, targetRevision, repoRoot)
 	case AppTypeDirectory:
+		if o.Airgap {
+			return nil, ErrAirgapNotSupported
+		}
+
 		return newDirApp(o), nil
 	default:
 		return nil, ErrUnknownAppType
@@ -246,6 +333,15 @@ func newKustApp(o *CreateOptions, projectName, repoURL, targetRevision, repoRoot
 		return nil, fmt.Errorf("unknown installation mode: %s", o.InstallationMode)
 	}
 
+	if o.ImageRegistryMirror != "" && !o.Airgap {
+		return nil, ErrImageMirrorWithoutAirgap
+	}
+
+	if o.Airgap && o.InstallationMode != InstallationModeFlat {
+		log.G().Info("using flat installation mode in airgap mode")
+		o.InstallationMode = InstallationModeFlat
+	}
+
 	// if app specifier is a local file
 	if _, err := os.Stat(o.AppSpecifier); err == nil {
 		log.G().Warn("using flat installation mode because base is a local file")
@@ -266,12 +362,18 @@ func newKustApp(o *CreateOptions, projectName, repoURL, targetRevision, repoRoot
 
 	if o.InstallationMode == InstallationModeFlat {
 		log.G().Info("building manifests...")
-		app.manifests, err = generateManifests(app.base)
+		app.manifests, err = generateManifests(app.base, nil)
 		if err != nil {
 			return nil, err
 		}
 
 		app.base.Resources[0] = "install.yaml"
+		if o.ImageRegistryMirror != "" {
+			app.base.Images, err = GenerateImageMirrors(app.manifests, o.ImageRegistryMirror)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	app.overlay = &kusttypes.Kustomization{
@@ -299,6 +401,7 @@ func newKustApp(o *CreateOptions, projectName, repoURL, targetRevision, repoRoot
 		SrcTargetRevision: targetRevision,
 		Labels:            o.Labels,
 		Annotations:       o.Annotations,
+		SyncPolicy:        o.SyncPolicy,
 	}
 
 	return app, nil
@@ -428,6 +531,7 @@ func newDirApp(opts *CreateOptions) *dirApp {
 			SrcTargetRevision: gitRef,
 			Labels:            opts.Labels,
 			Annotations:       opts.Annotations,
+			SyncPolicy:        opts.SyncPolicy,
 		},
 		Exclude: opts.Exclude,
 		Include: opts.Include,
@@ -485,11 +589,42 @@ func checkBaseCollision(repofs fs.FS, orgBasePath string, newBase *kusttypes.Kus
 }
 
 // fixResourcesPaths adjusts all relative paths in the kustomization file to the specified
-// newKustDir.
-func fixResourcesPaths(k *kusttypes.Kustomization, newKustDir string) error {
+// newKustDir. Local files are copied into newKustDir, since kustomize only loads files from
+// within the kustomization dir. Resources that are one of files are left as is.
+func fixResourcesPaths(k *kusttypes.Kustomization, newKustDir string, files map[string][]byte) error {
+	copied := map[string]bool{}
 	for i, path := range k.Resources {
+		if _, ok := files[filepath.Clean(path)]; ok {
+			continue
+		}
+
 		// if path is a remote resource ignore it
-		if _, err := os.Stat(path); err != nil && os.IsNotExist(err) {
+		fi, err := os.Stat(path)
+		if err != nil && os.IsNotExist(err) {
+			continue
+		}
+
+		if fi != nil && !fi.IsDir() {
+			name := filepath.Base(path)
+			if _, ok := files[name]; ok || copied[name] {
+				return fmt.Errorf("duplicate resource file name \"%s\"", name)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			if err = os.WriteFile(filepath.Join(newKustDir, name), data, 0400); err != nil {
+				return err
+			}
+
+			log.G().WithFields(log.Fields{
+				"from": path,
+				"to":   name,
+			}).Debug("copying local resource file into kustomization dir")
+			copied[name] = true
+			k.Resources[i] = name
 			continue
 		}
 
@@ -511,7 +646,7 @@ func fixResourcesPaths(k *kusttypes.Kustomization, newKustDir string) error {
 	return nil
 }
 
-var generateManifests = func(k *kusttypes.Kustomization) ([]byte, error) {
+var generateManifests = func(k *kusttypes.Kustomization, files map[string][]byte) ([]byte, error) {
 	td, err := os.MkdirTemp(".", "auto-pilot")
 	if err != nil {
 		return nil, fmt.Errorf("failed creating temp dir: %w", err)
@@ -523,10 +658,20 @@ var generateManifests = func(k *kusttypes.Kustomization) ([]byte, error) {
 		return nil, fmt.Errorf("failed getting abs path for \"%s\": %w", td, err)
 	}
 
-	if err = fixResourcesPaths(k, absTd); err != nil {
+	if err = fixResourcesPaths(k, absTd, files); err != nil {
 		return nil, fmt.Errorf("failed fixing resources paths: %w", err)
 	}
 
+	for name, data := range files {
+		if filepath.Base(name) != name {
+			return nil, fmt.Errorf("invalid kustomization file name \"%s\"", name)
+		}
+
+		if err = os.WriteFile(filepath.Join(td, name), data, 0400); err != nil {
+			return nil, fmt.Errorf("failed writing file to \"%s\": %w", filepath.Join(td, name), err)
+		}
+	}
+
 	kyaml, err := yaml.Marshal(k)
 	if err != nil {
 		return nil, fmt.Errorf("failed marshaling yaml: %w", err)
@@ -3,6 +3,7 @@ package application
 import (
 	"errors"
 	"io"
+	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
@@ -12,6 +13,7 @@ import (
 	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
 
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	"github.com/go-git/go-billy/v5/memfs"
 	billyUtils "github.com/go-git/go-billy/v5/util"
 	"github.com/golang/mock/gomock"
@@ -32,7 +34,7 @@ func bootstrapMockFS() fs.FS {
 func Test_newKustApp(t *testing.T) {
 	orgGenerateManifests := generateManifests
 	defer func() { generateManifests = orgGenerateManifests }()
-	generateManifests = func(k *kusttypes.Kustomization) ([]byte, error) {
+	generateManifests = func(k *kusttypes.Kustomization, _ map[string][]byte) ([]byte, error) {
 		return []byte("foo"), nil
 	}
 
@@ -73,6 +75,32 @@ func Test_newKustApp(t *testing.T) {
 			projectName: "project",
 			wantErr:     "unknown installation mode: foo",
 		},
+		"Should fail when an image registry mirror is set without airgap": {
+			opts: &CreateOptions{
+				AppSpecifier:        "app",
+				AppName:             "name",
+				ImageRegistryMirror: "registry.local",
+			},
+			projectName: "project",
+			wantErr:     ErrImageMirrorWithoutAirgap.Error(),
+		},
+		"Should use flat installation mode in airgap mode": {
+			opts: &CreateOptions{
+				AppSpecifier:        "app",
+				AppName:             "name",
+				Airgap:              true,
+				ImageRegistryMirror: "registry.local",
+			},
+			srcRepoURL:        "github.com/owner/repo",
+			srcTargetRevision: "branch",
+			projectName:       "project",
+			assertFn: func(t *testing.T, a *kustApp) {
+				assert.Equal(t, InstallationModeFlat, a.opts.InstallationMode)
+				assert.Equal(t, "install.yaml", a.base.Resources[0])
+				assert.Equal(t, []byte("foo"), a.manifests)
+				assert.Empty(t, a.base.Images)
+			},
+		},
 		"Should create a correct base kustomization and config.json": {
 			opts: &CreateOptions{
 				AppSpecifier: "app",
@@ -701,6 +729,150 @@ func TestDeleteFromProject(t *testing.T) {
 	}
 }
 
+func TestRenameInProject(t *testing.T) {
+	tests := map[string]struct {
+		wantErr  string
+		beforeFn func() fs.FS
+		assertFn func(*testing.T, fs.FS)
+	}{
+		"Should move the overlay and update its source path": {
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = billyUtils.WriteFile(repofs, "apps/app/overlays/project/kustomization.yaml", []byte("resources:\n- ../../base\n"), 0666)
+				_ = repofs.WriteJson("apps/app/overlays/project/config.json", &Config{AppName: "app", SrcPath: "path/apps/app/overlays/project"})
+				_ = repofs.MkdirAll("apps/app/overlays/project2", 0666)
+				return repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.False(t, repofs.ExistsOrDie("apps/app/overlays/project"))
+				assert.True(t, repofs.ExistsOrDie("apps/app/overlays/project2"))
+				data, err := repofs.ReadFile("apps/app/overlays/renamed/kustomization.yaml")
+				assert.NoError(t, err)
+				assert.Equal(t, "resources:\n- ../../base\n", string(data))
+				conf := &Config{}
+				assert.NoError(t, repofs.ReadJson("apps/app/overlays/renamed/config.json", conf))
+				assert.Equal(t, "app", conf.AppName)
+				assert.Equal(t, "path/apps/app/overlays/renamed", conf.SrcPath)
+			},
+		},
+		"Should move directory apps and keep their source path": {
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteJson("apps/app/project/config_dir.json", &dirConfig{Config: Config{AppName: "app", SrcPath: "manifests"}, Include: "*.yaml"})
+				return repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.False(t, repofs.ExistsOrDie("apps/app/project"))
+				conf := &dirConfig{}
+				assert.NoError(t, repofs.ReadJson("apps/app/renamed/config_dir.json", conf))
+				assert.Equal(t, "manifests", conf.SrcPath)
+				assert.Equal(t, "*.yaml", conf.Include)
+			},
+		},
+		"Should not change anything, if app is not in project": {
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.MkdirAll("apps/app/overlays/project2", 0666)
+				return repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.True(t, repofs.ExistsOrDie("apps/app/overlays/project2"))
+				assert.False(t, repofs.ExistsOrDie("apps/app/overlays/renamed"))
+			},
+		},
+		"Should fail if the overlay is in another repo": {
+			wantErr: "the overlays of app 'app' are in another repository, which can not be updated",
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteJson("apps/app/project/config.json", &Config{AppName: "app", SrcPath: "apps/app/overlays/project"})
+				return repofs
+			},
+		},
+		"Should fail if the app already exists in the new project": {
+			wantErr: "app 'app' already exists in project 'renamed'",
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.MkdirAll("apps/app/overlays/project", 0666)
+				_ = repofs.MkdirAll("apps/app/overlays/renamed", 0666)
+				return repofs
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repofs := tt.beforeFn()
+			if err := RenameInProject(repofs, "app", "project", "renamed"); err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			if tt.assertFn != nil {
+				tt.assertFn(t, repofs)
+			}
+		})
+	}
+}
+
+func TestCopyToProject(t *testing.T) {
+	tests := map[string]struct {
+		wantErr  string
+		beforeFn func() fs.FS
+		assertFn func(*testing.T, fs.FS)
+	}{
+		"Should copy the overlay with its patches": {
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = billyUtils.WriteFile(repofs, "apps/app/overlays/project/kustomization.yaml", []byte("resources:\n- ../../base\npatches:\n- path: patch.yaml\n"), 0666)
+				_ = billyUtils.WriteFile(repofs, "apps/app/overlays/project/patch.yaml", []byte("kind: Deployment\n"), 0666)
+				_ = repofs.WriteJson("apps/app/overlays/project/config.json", &Config{AppName: "app", SrcPath: "apps/app/overlays/project"})
+				return repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.True(t, repofs.ExistsOrDie("apps/app/overlays/project/patch.yaml"))
+				data, err := repofs.ReadFile("apps/app/overlays/cloned/patch.yaml")
+				assert.NoError(t, err)
+				assert.Equal(t, "kind: Deployment\n", string(data))
+				conf := &Config{}
+				assert.NoError(t, repofs.ReadJson("apps/app/overlays/project/config.json", conf))
+				assert.Equal(t, "apps/app/overlays/project", conf.SrcPath)
+				assert.NoError(t, repofs.ReadJson("apps/app/overlays/cloned/config.json", conf))
+				assert.Equal(t, "apps/app/overlays/cloned", conf.SrcPath)
+			},
+		},
+		"Should copy directory apps": {
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteJson("apps/app/project/config_dir.json", &dirConfig{Config: Config{AppName: "app", SrcPath: "manifests"}})
+				return repofs
+			},
+			assertFn: func(t *testing.T, repofs fs.FS) {
+				assert.True(t, repofs.ExistsOrDie("apps/app/project/config_dir.json"))
+				assert.True(t, repofs.ExistsOrDie("apps/app/cloned/config_dir.json"))
+			},
+		},
+		"Should fail if the app already exists in the new project": {
+			wantErr: "app 'app' already exists in project 'cloned'",
+			beforeFn: func() fs.FS {
+				repofs := fs.Create(memfs.New())
+				_ = repofs.MkdirAll("apps/app/project", 0666)
+				_ = repofs.MkdirAll("apps/app/cloned", 0666)
+				return repofs
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repofs := tt.beforeFn()
+			if err := CopyToProject(repofs, "app", "project", "cloned"); err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			tt.assertFn(t, repofs)
+		})
+	}
+}
+
 func Test_newDirApp(t *testing.T) {
 	tests := map[string]struct {
 		opts *CreateOptions
@@ -747,6 +919,24 @@ func Test_newDirApp(t *testing.T) {
 				},
 			},
 		},
+		"Should set the sync policy of the app": {
+			opts: &CreateOptions{
+				AppName:      "fooapp",
+				AppSpecifier: "github.com/foo/bar",
+				SyncPolicy:   &argocdv1alpha1.SyncPolicy{SyncOptions: argocdv1alpha1.SyncOptions{"ServerSideApply=true"}},
+			},
+			want: &dirApp{
+				dirConfig: &dirConfig{
+					Config: Config{
+						AppName:       "fooapp",
+						UserGivenName: "fooapp",
+						SrcRepoURL:    "https://github.com/foo/bar.git",
+						SrcPath:       ".",
+						SyncPolicy:    &argocdv1alpha1.SyncPolicy{SyncOptions: argocdv1alpha1.SyncOptions{"ServerSideApply=true"}},
+					},
+				},
+			},
+		},
 	}
 	for tname, tt := range tests {
 		t.Run(tname, func(t *testing.T) {
@@ -857,3 +1047,131 @@ func Test_dirApp_CreateFiles(t *testing.T) {
 		})
 	}
 }
+
+func Test_fixResourcesPaths(t *testing.T) {
+	dir := t.TempDir()
+	resPath := filepath.Join(dir, "install.yaml")
+	resDir := filepath.Join(dir, "manifests")
+	kustDir := filepath.Join(dir, "kust")
+	assert.NoError(t, os.WriteFile(resPath, []byte("manifests"), 0644))
+	assert.NoError(t, os.Mkdir(resDir, 0755))
+	assert.NoError(t, os.Mkdir(kustDir, 0755))
+	k := &kusttypes.Kustomization{
+		Resources: []string{"github.com/owner/repo/manifests", resPath, resDir, "vendored.yaml", "./vendored.yaml"},
+	}
+
+	assert.NoError(t, fixResourcesPaths(k, kustDir, map[string][]byte{"vendored.yaml": {}}))
+	assert.Equal(t, "github.com/owner/repo/manifests", k.Resources[0])
+	assert.Equal(t, "install.yaml", k.Resources[1])
+	assert.Equal(t, filepath.Join("..", "manifests"), k.Resources[2])
+	assert.Equal(t, "vendored.yaml", k.Resources[3])
+	assert.Equal(t, "./vendored.yaml", k.Resources[4])
+	data, err := os.ReadFile(filepath.Join(kustDir, "install.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "manifests", string(data))
+	assert.EqualError(t, fixResourcesPaths(&kusttypes.Kustomization{Resources: []string{resPath}}, kustDir, map[string][]byte{"install.yaml": {}}), "duplicate resource file name \"install.yaml\"")
+}
+
+func Test_generateManifests(t *testing.T) {
+	cm := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  key: value
+`)
+	patch := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  other: value
+`)
+	tests := map[string]struct {
+		k        *kusttypes.Kustomization
+		files    map[string][]byte
+		beforeFn func(t *testing.T, dir string)
+		wantErr  string
+		assertFn func(t *testing.T, got []byte)
+	}{
+		"Should apply patches from files": {
+			k: &kusttypes.Kustomization{
+				Resources: []string{"install.yaml"},
+				Patches:   []kusttypes.Patch{{Path: "patch.yaml"}},
+			},
+			files: map[string][]byte{
+				"install.yaml": cm,
+				"patch.yaml":   patch,
+			},
+			assertFn: func(t *testing.T, got []byte) {
+				assert.Contains(t, string(got), "key: value")
+				assert.Contains(t, string(got), "other: value")
+			},
+		},
+		"Should prefer files over local files with the same name": {
+			k: &kusttypes.Kustomization{
+				Resources: []string{"install.yaml"},
+			},
+			files: map[string][]byte{
+				"install.yaml": cm,
+			},
+			beforeFn: func(t *testing.T, dir string) {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "install.yaml"), patch, 0644))
+			},
+			assertFn: func(t *testing.T, got []byte) {
+				assert.Contains(t, string(got), "key: value")
+				assert.NotContains(t, string(got), "other: value")
+			},
+		},
+		"Should not load patch files from outside the kustomization dir": {
+			k: &kusttypes.Kustomization{
+				Resources: []string{"install.yaml"},
+				Patches:   []kusttypes.Patch{{Path: "../patch.yaml"}},
+			},
+			files: map[string][]byte{
+				"install.yaml": cm,
+			},
+			beforeFn: func(t *testing.T, dir string) {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "patch.yaml"), patch, 0644))
+			},
+			wantErr: "failed running kustomization",
+		},
+		"Should copy local resource files into the kustomization dir": {
+			k: &kusttypes.Kustomization{
+				Resources: []string{"../install.yaml"},
+			},
+			beforeFn: func(t *testing.T, dir string) {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "..", "install.yaml"), cm, 0644))
+			},
+			assertFn: func(t *testing.T, got []byte) {
+				assert.Contains(t, string(got), "key: value")
+			},
+		},
+		"Should fail when a file name is a path": {
+			k: &kusttypes.Kustomization{
+				Resources: []string{"install.yaml"},
+			},
+			files: map[string][]byte{
+				"../install.yaml": cm,
+			},
+			wantErr: "invalid kustomization file name \"../install.yaml\"",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			if tt.beforeFn != nil {
+				tt.beforeFn(t, dir)
+			}
+
+			got, err := generateManifests(tt.k, tt.files)
+			if err != nil || tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			tt.assertFn(t, got)
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	kustutil "sigs.k8s.io/kustomize/api/pkg/util"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+var (
+	// Errors
+	ErrImportMultiSource       = errors.New("applications with multiple sources can not be imported")
+	ErrImportHelmChart         = errors.New("applications with a helm source can not be imported, only kustomize and directory sources are supported")
+	ErrImportPlugin            = errors.New("applications with a plugin source can not be imported, only kustomize and directory sources are supported")
+	ErrImportUnknownSourceType = errors.New("the source type of the application is unknown, wait for argo-cd to reconcile it and try again")
+	ErrImportKustomizeOption   = errors.New("kustomize option can not be imported")
+)
+
+// NewImportedApp returns an Application with the source, destination and sync policy of a live
+// Argo-CD Application in projectName. A directory source is imported as a dir app that references
+// the original source. A kustomize source is imported as a kustomize app, with the original source
+// as a remote base, and the kustomize options of the Application in its overlay
+func NewImportedApp(repofs fs.FS, appName string, app *argocdv1alpha1.Application, projectName, repoURL, targetRevision, repoRoot string) (Application, error) {
+	if appName == "" {
+		return nil, ErrEmptyAppName
+	}
+
+	if projectName == "" {
+		return nil, ErrEmptyProjectName
+	}
+
+	srcType, err := getImportSourceType(app)
+	if err != nil {
+		return nil, err
+	}
+
+	destServer := app.Spec.Destination.Server
+	if destServer == "" && app.Spec.Destination.Name != "" {
+		destServer, err = clusterNameToServer(repofs, app.Spec.Destination.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get server of cluster '%s': %w", app.Spec.Destination.Name, err)
+		}
+
+		if destServer == "" {
+			return nil, fmt.Errorf("cluster '%s' is not configured yet, you need to create a project that uses this cluster first", app.Spec.Destination.Name)
+		}
+	}
+
+	// an empty sync policy still replaces the sync policy of the project, so an app that is
+	// synced manually stays that way
+	syncPolicy := app.Spec.SyncPolicy.DeepCopy()
+	if syncPolicy == nil {
+		syncPolicy = &argocdv1alpha1.SyncPolicy{}
+	}
+
+	src := app.Spec.GetSource()
+	opts := &CreateOptions{
+		AppName:          appName,
+		AppSpecifier:     getImportedAppSpecifier(&src),
+		DestNamespace:    app.Spec.Destination.Namespace,
+		DestServer:       destServer,
+		InstallationMode: InstallationModeNormal,
+		SyncPolicy:       syncPolicy,
+	}
+	if srcType == argocdv1alpha1.ApplicationSourceTypeDirectory {
+		return newImportedDirApp(opts, &src)
+	}
+
+	importedApp, err := newKustApp(opts, projectName, repoURL, targetRevision, repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = setKustomizeOptions(importedApp.overlay, src.Kustomize); err != nil {
+		return nil, err
+	}
+
+	return importedApp, nil
+}
+
+// getImportSourceType returns the source type that argo-cd detected for the application, or the
+// type of its explicit source options, if it was not reconciled yet
+func getImportSourceType(app *argocdv1alpha1.Application) (argocdv1alpha1.ApplicationSourceType, error) {
+	if app.Spec.HasMultipleSources() {
+		return "", ErrImportMultiSource
+	}
+
+	src := app.Spec.GetSource()
+	if src.IsHelm() {
+		return "", ErrImportHelmChart
+	}
+
+	srcType := app.Status.SourceType
+	if srcType == "" {
+		explicitType, err := src.ExplicitType()
+		if err != nil {
+			return "", err
+		}
+
+		if explicitType == nil {
+			return "", ErrImportUnknownSourceType
+		}
+
+		srcType = *explicitType
+	}
+
+	switch srcType {
+	case argocdv1alpha1.ApplicationSourceTypeDirectory, argocdv1alpha1.ApplicationSourceTypeKustomize:
+		return srcType, nil
+	case argocdv1alpha1.ApplicationSourceTypeHelm:
+		return "", ErrImportHelmChart
+	case argocdv1alpha1.ApplicationSourceTypePlugin:
+		return "", ErrImportPlugin
+	default:
+		return "", fmt.Errorf("%w: %s", ErrImportUnknownSourceType, srcType)
+	}
+}
+
+// getImportedAppSpecifier returns the remote kustomize resource of the source
+// (e.g. https://github.com/owner/repo//path?ref=v1)
+func getImportedAppSpecifier(src *argocdv1alpha1.ApplicationSource) string {
+	specifier := src.RepoURL
+	if srcPath := strings.Trim(path.Clean(src.Path), "/"); srcPath != "." && srcPath != "" {
+		specifier += "//" + srcPath
+	}
+
+	if src.TargetRevision != "" && src.TargetRevision != "HEAD" {
+		specifier += "?ref=" + src.TargetRevision
+	}
+
+	return specifier
+}
+
+func newImportedDirApp(opts *CreateOptions, src *argocdv1alpha1.ApplicationSource) (*dirApp, error) {
+	dir := src.Directory
+	if dir == nil {
+		dir = &argocdv1alpha1.ApplicationSourceDirectory{}
+	}
+
+	if !dir.Jsonnet.IsZero() {
+		return nil, errors.New("directory applications with jsonnet options can not be imported")
+	}
+
+	if !dir.Recurse {
+		log.G().Warnf("the directory of application '%s' will be read recursively", opts.AppName)
+	}
+
+	srcPath := src.Path
+	if srcPath == "" {
+		srcPath = "."
+	}
+
+	return &dirApp{
+		baseApp: baseApp{opts},
+		dirConfig: &dirConfig{
+			Config: Config{
+				AppName:           opts.AppName,
+				UserGivenName:     opts.AppName,
+				DestNamespace:     opts.DestNamespace,
+				DestServer:        opts.DestServer,
+				SrcRepoURL:        src.RepoURL,
+				SrcPath:           srcPath,
+				SrcTargetRevision: src.TargetRevision,
+				SyncPolicy:        opts.SyncPolicy,
+			},
+			Exclude: dir.Exclude,
+			Include: dir.Include,
+		},
+	}, nil
+}
+
+// setKustomizeOptions sets the kustomize options of an application on the overlay k. Like
+// argo-cd, the overlay only sets the namespace of the resources if the options set one
+func setKustomizeOptions(k *kusttypes.Kustomization, opts *argocdv1alpha1.ApplicationSourceKustomize) error {
+	k.Namespace = ""
+	if opts == nil {
+		return nil
+	}
+
+	unsupported := []struct {
+		name string
+		set  bool
+	}{
+		{"version", opts.Version != ""},
+		{"components", len(opts.Components) > 0},
+		{"forceCommonLabels", opts.ForceCommonLabels},
+		{"forceCommonAnnotations", opts.ForceCommonAnnotations},
+		{"commonAnnotationsEnvsubst", opts.CommonAnnotationsEnvsubst},
+		{"labelWithoutSelector", opts.LabelWithoutSelector},
+		{"labelIncludeTemplates", opts.LabelIncludeTemplates},
+		{"kubeVersion", opts.KubeVersion != ""},
+		{"apiVersions", len(opts.APIVersions) > 0},
+	}
+	for _, option := range unsupported {
+		if option.set {
+			return fmt.Errorf("%w: %s", ErrImportKustomizeOption, option.name)
+		}
+	}
+
+	k.NamePrefix = opts.NamePrefix
+	k.NameSuffix = opts.NameSuffix
+	k.Namespace = opts.Namespace
+	k.CommonLabels = opts.CommonLabels
+	k.CommonAnnotations = opts.CommonAnnotations
+	for _, image := range opts.Images {
+		k.Images = append(k.Images, parseKustomizeImage(string(image)))
+	}
+
+	for _, replica := range opts.Replicas {
+		count, err := replica.GetIntCount()
+		if err != nil {
+			return fmt.Errorf("failed to parse the replicas of '%s': %w", replica.Name, err)
+		}
+
+		k.Replicas = append(k.Replicas, kusttypes.Replica{Name: replica.Name, Count: int64(count)})
+	}
+
+	for _, patch := range opts.Patches {
+		if patch.Path != "" {
+			return fmt.Errorf("%w: patches with a path", ErrImportKustomizeOption)
+		}
+
+		p := kusttypes.Patch{Patch: patch.Patch, Options: patch.Options}
+		if t := patch.Target; t != nil {
+			p.Target = &kusttypes.Selector{
+				ResId: resid.ResId{
+					Gvk:       resid.Gvk{Group: t.Group, Version: t.Version, Kind: t.Kind},
+					Name:      t.Name,
+					Namespace: t.Namespace,
+				},
+				AnnotationSelector: t.AnnotationSelector,
+				LabelSelector:      t.LabelSelector,
+			}
+		}
+
+		k.Patches = append(k.Patches, p)
+	}
+
+	return nil
+}
+
+// parseKustomizeImage parses an argo-cd kustomize image override, in the format of
+// `kustomize edit set image` (e.g. name=newName:tag, name@digest)
+func parseKustomizeImage(image string) kusttypes.Image {
+	name, override, found := strings.Cut(image, "=")
+	if !found {
+		name, tag, digest := kustutil.SplitImageName(image)
+		return kusttypes.Image{Name: name, NewTag: tag, Digest: digest}
+	}
+
+	newName, tag, digest := kustutil.SplitImageName(override)
+	return kusttypes.Image{Name: name, NewName: newName, NewTag: tag, Digest: digest}
+}
@@ -0,0 +1,296 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+
+	argocdv1alpha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+func TestNewImportedApp(t *testing.T) {
+	kustomizeApp := func(kustomize *argocdv1alpha1.ApplicationSourceKustomize) *argocdv1alpha1.Application {
+		return &argocdv1alpha1.Application{
+			Spec: argocdv1alpha1.ApplicationSpec{
+				Source: &argocdv1alpha1.ApplicationSource{
+					RepoURL:        "https://github.com/owner/apps",
+					Path:           "payments",
+					TargetRevision: "v1",
+					Kustomize:      kustomize,
+				},
+				Destination: argocdv1alpha1.ApplicationDestination{
+					Server:    "https://kubernetes.default.svc",
+					Namespace: "payments",
+				},
+			},
+		}
+	}
+	tests := map[string]struct {
+		app      *argocdv1alpha1.Application
+		assertFn func(t *testing.T, repofs fs.FS, app Application, err error)
+	}{
+		"Should import a kustomize source as a remote base": {
+			app: func() *argocdv1alpha1.Application {
+				app := kustomizeApp(nil)
+				app.Status.SourceType = argocdv1alpha1.ApplicationSourceTypeKustomize
+				return app
+			}(),
+			assertFn: func(t *testing.T, repofs fs.FS, app Application, err error) {
+				assert.NoError(t, err)
+				kustApp := app.(*kustApp)
+				assert.Equal(t, []string{"https://github.com/owner/apps//payments?ref=v1"}, kustApp.base.Resources)
+				assert.Equal(t, []string{"../../base"}, kustApp.overlay.Resources)
+				assert.Empty(t, kustApp.overlay.Namespace)
+				assert.Equal(t, &Config{
+					AppName:           "app",
+					UserGivenName:     "app",
+					DestNamespace:     "payments",
+					DestServer:        "https://kubernetes.default.svc",
+					SrcRepoURL:        "https://github.com/owner/gitops",
+					SrcPath:           "apps/app/overlays/project",
+					SrcTargetRevision: "main",
+					SyncPolicy:        &argocdv1alpha1.SyncPolicy{},
+				}, kustApp.config)
+
+				assert.NoError(t, app.CreateFiles(repofs, repofs, "project"))
+				assert.True(t, repofs.ExistsOrDie("apps/app/base/kustomization.yaml"))
+				assert.True(t, repofs.ExistsOrDie("apps/app/overlays/project/kustomization.yaml"))
+				assert.True(t, repofs.ExistsOrDie("apps/app/overlays/project/config.json"))
+				assert.True(t, repofs.ExistsOrDie("bootstrap/cluster-resources/in-cluster/payments-ns.yaml"))
+			},
+		},
+		"Should keep the kustomize options and the sync policy": {
+			app: func() *argocdv1alpha1.Application {
+				app := kustomizeApp(&argocdv1alpha1.ApplicationSourceKustomize{
+					NamePrefix:   "prod-",
+					Namespace:    "payments-prod",
+					Images:       argocdv1alpha1.KustomizeImages{"payments=registry.local/payments:v2"},
+					CommonLabels: map[string]string{"team": "payments"},
+					Replicas:     argocdv1alpha1.KustomizeReplicas{{Name: "payments", Count: intstr.FromString("3")}},
+					Patches: argocdv1alpha1.KustomizePatches{{
+						Patch:  "- op: remove\n  path: /spec/template/spec/tolerations",
+						Target: &argocdv1alpha1.KustomizeSelector{KustomizeResId: argocdv1alpha1.KustomizeResId{KustomizeGvk: argocdv1alpha1.KustomizeGvk{Kind: "Deployment"}}},
+					}},
+				})
+				app.Spec.SyncPolicy = &argocdv1alpha1.SyncPolicy{Automated: &argocdv1alpha1.SyncPolicyAutomated{}}
+				return app
+			}(),
+			assertFn: func(t *testing.T, _ fs.FS, app Application, err error) {
+				assert.NoError(t, err)
+				kustApp := app.(*kustApp)
+				assert.Equal(t, "prod-", kustApp.overlay.NamePrefix)
+				assert.Equal(t, "payments-prod", kustApp.overlay.Namespace)
+				assert.Equal(t, []kusttypes.Image{{Name: "payments", NewName: "registry.local/payments", NewTag: "v2"}}, kustApp.overlay.Images)
+				assert.Equal(t, map[string]string{"team": "payments"}, kustApp.overlay.CommonLabels)
+				assert.Equal(t, []kusttypes.Replica{{Name: "payments", Count: 3}}, kustApp.overlay.Replicas)
+				assert.Equal(t, []kusttypes.Patch{{
+					Patch:  "- op: remove\n  path: /spec/template/spec/tolerations",
+					Target: &kusttypes.Selector{ResId: resid.ResId{Gvk: resid.Gvk{Kind: "Deployment"}}},
+				}}, kustApp.overlay.Patches)
+				assert.Equal(t, &argocdv1alpha1.SyncPolicy{Automated: &argocdv1alpha1.SyncPolicyAutomated{}}, kustApp.config.SyncPolicy)
+			},
+		},
+		"Should fail on an unsupported kustomize option": {
+			app: kustomizeApp(&argocdv1alpha1.ApplicationSourceKustomize{Components: []string{"../components/ha"}}),
+			assertFn: func(t *testing.T, _ fs.FS, _ Application, err error) {
+				assert.ErrorIs(t, err, ErrImportKustomizeOption)
+				assert.EqualError(t, err, "kustomize option can not be imported: components")
+			},
+		},
+		"Should fail on a kustomize patch with a path": {
+			app: kustomizeApp(&argocdv1alpha1.ApplicationSourceKustomize{Patches: argocdv1alpha1.KustomizePatches{{Path: "patch.yaml"}}}),
+			assertFn: func(t *testing.T, _ fs.FS, _ Application, err error) {
+				assert.ErrorIs(t, err, ErrImportKustomizeOption)
+			},
+		},
+		"Should import a directory source into a dir config": {
+			app: &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Source: &argocdv1alpha1.ApplicationSource{
+						RepoURL:   "https://github.com/owner/apps",
+						Directory: &argocdv1alpha1.ApplicationSourceDirectory{Recurse: true, Include: "*.yaml"},
+					},
+					Destination: argocdv1alpha1.ApplicationDestination{Server: "https://kubernetes.default.svc"},
+				},
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, app Application, err error) {
+				assert.NoError(t, err)
+				dirApp := app.(*dirApp)
+				assert.Equal(t, ".", dirApp.dirConfig.SrcPath)
+				assert.Equal(t, "https://github.com/owner/apps", dirApp.dirConfig.SrcRepoURL)
+				assert.Equal(t, "*.yaml", dirApp.dirConfig.Include)
+				assert.Equal(t, &argocdv1alpha1.SyncPolicy{}, dirApp.dirConfig.SyncPolicy)
+
+				assert.NoError(t, app.CreateFiles(repofs, repofs, "project"))
+				assert.True(t, repofs.ExistsOrDie("apps/app/project/config_dir.json"))
+			},
+		},
+		"Should use the detected source type": {
+			app: &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Source:      &argocdv1alpha1.ApplicationSource{RepoURL: "https://github.com/owner/apps", Path: "payments"},
+					Destination: argocdv1alpha1.ApplicationDestination{Server: "https://kubernetes.default.svc"},
+				},
+				Status: argocdv1alpha1.ApplicationStatus{SourceType: argocdv1alpha1.ApplicationSourceTypeDirectory},
+			},
+			assertFn: func(t *testing.T, _ fs.FS, app Application, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, "payments", app.(*dirApp).dirConfig.SrcPath)
+			},
+		},
+		"Should resolve the destination server by cluster name": {
+			app: &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Source:      &argocdv1alpha1.ApplicationSource{RepoURL: "https://github.com/owner/apps"},
+					Destination: argocdv1alpha1.ApplicationDestination{Name: store.Default.ClusterContextName},
+				},
+				Status: argocdv1alpha1.ApplicationStatus{SourceType: argocdv1alpha1.ApplicationSourceTypeKustomize},
+			},
+			assertFn: func(t *testing.T, _ fs.FS, app Application, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, store.Default.DestServer, app.(*kustApp).config.DestServer)
+			},
+		},
+		"Should fail on an unknown cluster name": {
+			app: &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Source:      &argocdv1alpha1.ApplicationSource{RepoURL: "https://github.com/owner/apps"},
+					Destination: argocdv1alpha1.ApplicationDestination{Name: "other"},
+				},
+				Status: argocdv1alpha1.ApplicationStatus{SourceType: argocdv1alpha1.ApplicationSourceTypeKustomize},
+			},
+			assertFn: func(t *testing.T, _ fs.FS, _ Application, err error) {
+				assert.EqualError(t, err, "cluster 'other' is not configured yet, you need to create a project that uses this cluster first")
+			},
+		},
+		"Should fail on a helm chart source": {
+			app: &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Source: &argocdv1alpha1.ApplicationSource{RepoURL: "https://charts.example.com", Chart: "payments"},
+				},
+			},
+			assertFn: func(t *testing.T, _ fs.FS, _ Application, err error) {
+				assert.ErrorIs(t, err, ErrImportHelmChart)
+			},
+		},
+		"Should fail on a detected helm source": {
+			app: &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Source: &argocdv1alpha1.ApplicationSource{RepoURL: "https://github.com/owner/apps", Path: "charts/payments"},
+				},
+				Status: argocdv1alpha1.ApplicationStatus{SourceType: argocdv1alpha1.ApplicationSourceTypeHelm},
+			},
+			assertFn: func(t *testing.T, _ fs.FS, _ Application, err error) {
+				assert.ErrorIs(t, err, ErrImportHelmChart)
+			},
+		},
+		"Should fail on a plugin source": {
+			app: &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Source: &argocdv1alpha1.ApplicationSource{
+						RepoURL: "https://github.com/owner/apps",
+						Plugin:  &argocdv1alpha1.ApplicationSourcePlugin{Name: "cdk8s"},
+					},
+				},
+			},
+			assertFn: func(t *testing.T, _ fs.FS, _ Application, err error) {
+				assert.ErrorIs(t, err, ErrImportPlugin)
+			},
+		},
+		"Should fail on a source of an unknown type": {
+			app: &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Source: &argocdv1alpha1.ApplicationSource{RepoURL: "https://github.com/owner/apps"},
+				},
+			},
+			assertFn: func(t *testing.T, _ fs.FS, _ Application, err error) {
+				assert.ErrorIs(t, err, ErrImportUnknownSourceType)
+			},
+		},
+		"Should fail on multiple sources": {
+			app: &argocdv1alpha1.Application{
+				Spec: argocdv1alpha1.ApplicationSpec{
+					Sources: argocdv1alpha1.ApplicationSources{
+						{RepoURL: "https://github.com/owner/apps"},
+						{RepoURL: "https://github.com/owner/values"},
+					},
+				},
+			},
+			assertFn: func(t *testing.T, _ fs.FS, _ Application, err error) {
+				assert.ErrorIs(t, err, ErrImportMultiSource)
+			},
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			repofs := fs.Create(memfs.New())
+			assert.NoError(t, repofs.WriteJson(repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, "in-cluster.json"), &ClusterResConfig{
+				Name:   store.Default.ClusterContextName,
+				Server: store.Default.DestServer,
+			}))
+
+			app, err := NewImportedApp(repofs, "app", tt.app, "project", "https://github.com/owner/gitops", "main", "")
+			tt.assertFn(t, repofs, app, err)
+		})
+	}
+}
+
+func Test_getImportedAppSpecifier(t *testing.T) {
+	tests := map[string]struct {
+		src  *argocdv1alpha1.ApplicationSource
+		want string
+	}{
+		"Should add the path and the revision": {
+			src:  &argocdv1alpha1.ApplicationSource{RepoURL: "https://github.com/owner/apps", Path: "payments/", TargetRevision: "v1"},
+			want: "https://github.com/owner/apps//payments?ref=v1",
+		},
+		"Should omit the root path": {
+			src:  &argocdv1alpha1.ApplicationSource{RepoURL: "https://github.com/owner/apps", Path: ".", TargetRevision: "v1"},
+			want: "https://github.com/owner/apps?ref=v1",
+		},
+		"Should omit the HEAD revision": {
+			src:  &argocdv1alpha1.ApplicationSource{RepoURL: "git@github.com:owner/apps.git", Path: "payments", TargetRevision: "HEAD"},
+			want: "git@github.com:owner/apps.git//payments",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			assert.Equal(t, tt.want, getImportedAppSpecifier(tt.src))
+		})
+	}
+}
+
+func Test_parseKustomizeImage(t *testing.T) {
+	tests := map[string]struct {
+		image string
+		want  kusttypes.Image
+	}{
+		"Should parse a tag": {
+			image: "payments:v2",
+			want:  kusttypes.Image{Name: "payments", NewTag: "v2"},
+		},
+		"Should parse a digest": {
+			image: "payments@sha256:24a0c4b4",
+			want:  kusttypes.Image{Name: "payments", Digest: "sha256:24a0c4b4"},
+		},
+		"Should parse a new name": {
+			image: "payments=registry.local:5000/payments",
+			want:  kusttypes.Image{Name: "payments", NewName: "registry.local:5000/payments"},
+		},
+		"Should parse a new name and tag": {
+			image: "payments=registry.local/payments:v2",
+			want:  kusttypes.Image{Name: "payments", NewName: "registry.local/payments", NewTag: "v2"},
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseKustomizeImage(tt.image))
+		})
+	}
+}
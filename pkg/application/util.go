@@ -7,17 +7,33 @@ import (
 	billyUtils "github.com/go-git/go-billy/v5/util"
 )
 
-func serverToClusterName(repofs fs.FS, server string) (string, error) {
+// ListClusters returns the configs of all of the clusters in the cluster-resources dir
+func ListClusters(repofs fs.FS) ([]*ClusterResConfig, error) {
 	confs, err := billyUtils.Glob(repofs, repofs.Join(store.Default.BootsrtrapDir, store.Default.ClusterResourcesDir, "*.json"))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	clusters := make([]*ClusterResConfig, 0, len(confs))
 	for _, confFile := range confs {
 		conf := &ClusterResConfig{}
 		if err = repofs.ReadYamls(confFile, conf); err != nil {
-			return "", err
+			return nil, err
 		}
+
+		clusters = append(clusters, conf)
+	}
+
+	return clusters, nil
+}
+
+func serverToClusterName(repofs fs.FS, server string) (string, error) {
+	clusters, err := ListClusters(repofs)
+	if err != nil {
+		return "", err
+	}
+
+	for _, conf := range clusters {
 		if conf.Server == server {
 			return conf.Name, nil
 		}
@@ -25,3 +41,18 @@ func serverToClusterName(repofs fs.FS, server string) (string, error) {
 
 	return "", nil
 }
+
+func clusterNameToServer(repofs fs.FS, name string) (string, error) {
+	clusters, err := ListClusters(repofs)
+	if err != nil {
+		return "", err
+	}
+
+	for _, conf := range clusters {
+		if conf.Name == name {
+			return conf.Server, nil
+		}
+	}
+
+	return "", nil
+}
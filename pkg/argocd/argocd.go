@@ -7,7 +7,6 @@ import (
 
 	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
 	"github.com/argoproj-labs/argocd-autopilot/pkg/log"
-	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
 
 	"github.com/argoproj/argo-cd/v3/cmd/argocd/commands"
 	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
@@ -18,16 +17,6 @@ import (
 )
 
 type (
-	// AddClusterCmd when executed calls the 'argocd cluster add' command
-	AddClusterCmd interface {
-		Execute(ctx context.Context, clusterName string) error
-	}
-
-	addClusterImpl struct {
-		cmd  *cobra.Command
-		args []string
-	}
-
 	LoginOptions struct {
 		Namespace   string
 		Username    string
@@ -36,36 +25,35 @@ type (
 		KubeContext string
 		Insecure    bool
 	}
-)
 
-func AddClusterAddFlags(cmd *cobra.Command) (AddClusterCmd, error) {
-	root := commands.NewCommand()
-	args := []string{"cluster", "add"}
-	addcmd, _, err := root.Find(args)
-	if err != nil {
-		return nil, err
+	ProjectRoleTokenOptions struct {
+		// Namespace is the argo-cd namespace, the argo-cd server is reached with a port-forward to it
+		Namespace   string
+		KubeConfig  string
+		KubeContext string
+		Project     string
+		Role        string
+		// ExpiresIn is the duration before the token expires (e.g. "24h"), empty for no expiration
+		ExpiresIn string
+		TokenOnly bool
 	}
+)
 
-	fs, err := util.StealFlags(addcmd, []string{"logformat", "loglevel", "namespace", "kube-context"})
+// ClientSet returns an argo-cd clientset for the cluster that f is configured with
+func ClientSet(f kube.Factory) (argocdcs.Interface, error) {
+	rc, err := f.ToRESTConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	cmd.Flags().AddFlagSet(fs)
-
-	return &addClusterImpl{root, args}, nil
+	return argocdcs.NewForConfig(rc)
 }
 
 // GetAppSyncWaitFunc returns a WaitFunc that will return true when the Application
 // is in Sync + Healthy state, and at the specific revision (if supplied. If revision is "", no revision check is made)
 func GetAppSyncWaitFunc(revision string, waitForCreation bool) kube.WaitFunc {
 	return func(ctx context.Context, f kube.Factory, ns, name string) (bool, error) {
-		rc, err := f.ToRESTConfig()
-		if err != nil {
-			return false, err
-		}
-
-		c, err := argocdcs.NewForConfig(rc)
+		c, err := ClientSet(f)
 		if err != nil {
 			return false, err
 		}
@@ -92,11 +80,6 @@ func GetAppSyncWaitFunc(revision string, waitForCreation bool) kube.WaitFunc {
 	}
 }
 
-func (a *addClusterImpl) Execute(ctx context.Context, clusterName string) error {
-	a.cmd.SetArgs(append(a.args, clusterName))
-	return a.cmd.ExecuteContext(ctx)
-}
-
 func Login(opts *LoginOptions) error {
 	root := commands.NewCommand()
 	args := []string{
@@ -112,14 +95,6 @@ func Login(opts *LoginOptions) error {
 		"autopilot",
 	}
 
-	if opts.KubeConfig != "" {
-		origKubeConfig := os.Getenv("KUBECONFIG")
-		defer func() { os.Setenv("KUBECONFIG", origKubeConfig) }()
-		if err := os.Setenv("KUBECONFIG", opts.KubeConfig); err != nil {
-			return fmt.Errorf("failed to set KUBECONFIG env var: %w", err)
-		}
-	}
-
 	if opts.Insecure {
 		args = append(args, "--plaintext")
 	}
@@ -128,6 +103,47 @@ func Login(opts *LoginOptions) error {
 		args = append(args, "--kube-context", opts.KubeContext)
 	}
 
+	return executeCommand(root, args, opts.KubeConfig)
+}
+
+// CreateProjectRoleToken issues a JWT for a project role through the argo-cd api, and prints it
+func CreateProjectRoleToken(opts *ProjectRoleTokenOptions) error {
+	root := commands.NewCommand()
+	args := []string{
+		"proj",
+		"role",
+		"create-token",
+		opts.Project,
+		opts.Role,
+		"--port-forward",
+		"--port-forward-namespace",
+		opts.Namespace,
+	}
+
+	if opts.ExpiresIn != "" {
+		args = append(args, "--expires-in", opts.ExpiresIn)
+	}
+
+	if opts.TokenOnly {
+		args = append(args, "--token-only")
+	}
+
+	if opts.KubeContext != "" {
+		args = append(args, "--kube-context", opts.KubeContext)
+	}
+
+	return executeCommand(root, args, opts.KubeConfig)
+}
+
+func executeCommand(root *cobra.Command, args []string, kubeConfig string) error {
+	if kubeConfig != "" {
+		origKubeConfig := os.Getenv("KUBECONFIG")
+		defer func() { os.Setenv("KUBECONFIG", origKubeConfig) }()
+		if err := os.Setenv("KUBECONFIG", kubeConfig); err != nil {
+			return fmt.Errorf("failed to set KUBECONFIG env var: %w", err)
+		}
+	}
+
 	root.SetArgs(args)
 	return root.Execute()
 }
@@ -0,0 +1,228 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+
+	"github.com/argoproj/argo-cd/v3/common"
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	restclient "k8s.io/client-go/rest"
+)
+
+const (
+	DefaultClusterManagerServiceAccount = "argocd-manager"
+	DefaultClusterManagerNamespace      = "kube-system"
+)
+
+type (
+	ClusterManagerOptions struct {
+		// Namespace is the namespace of the service account, in the managed cluster
+		Namespace      string
+		ServiceAccount string
+		WaitInterval   time.Duration
+		Timeout        time.Duration
+	}
+
+	ClusterSecretOptions struct {
+		// Name is the name of the cluster in argo-cd
+		Name      string
+		Server    string
+		Namespace string
+		Config    v1alpha1.ClusterConfig
+		// Labels are added to the secret, to select the cluster in ApplicationSet cluster generators
+		Labels map[string]string
+	}
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// ClusterSecretName returns the name of the argo-cd cluster secret of the provided cluster
+func ClusterSecretName(clusterName string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(clusterName), "-")
+	return "cluster-" + strings.Trim(name, "-.")
+}
+
+// GenerateClusterSecret returns the declarative argo-cd cluster secret, which makes
+// argo-cd manage the cluster at opts.Server with the credentials in opts.Config
+func GenerateClusterSecret(opts *ClusterSecretOptions) (*corev1.Secret, error) {
+	config, err := json.Marshal(&opts.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cluster config: %w", err)
+	}
+
+	labels := map[string]string{}
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+
+	labels[common.LabelKeySecretType] = common.LabelValueSecretTypeCluster
+	labels[store.Default.LabelKeyAppManagedBy] = store.Default.LabelValueManagedBy
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ClusterSecretName(opts.Name),
+			Namespace: opts.Namespace,
+			Labels:    labels,
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			"name":   opts.Name,
+			"server": opts.Server,
+			"config": string(config),
+		},
+	}, nil
+}
+
+// ClusterConfigFromREST returns the argo-cd cluster config that connects to the server in rc,
+// with the provided bearer token. Any CA file is read, so the config is self contained
+func ClusterConfigFromREST(rc *restclient.Config, bearerToken string) (v1alpha1.ClusterConfig, error) {
+	if err := restclient.LoadTLSFiles(rc); err != nil {
+		return v1alpha1.ClusterConfig{}, fmt.Errorf("failed to read tls files: %w", err)
+	}
+
+	return v1alpha1.ClusterConfig{
+		BearerToken: bearerToken,
+		TLSClientConfig: v1alpha1.TLSClientConfig{
+			Insecure:   rc.Insecure,
+			ServerName: rc.ServerName,
+			CAData:     rc.CAData,
+		},
+	}, nil
+}
+
+// InstallClusterManager creates the service account that argo-cd uses to manage the cluster
+// f is configured with, together with its cluster role and a long lived token, and returns
+// the token. Existing resources are updated
+func InstallClusterManager(ctx context.Context, f kube.Factory, opts *ClusterManagerOptions) (string, error) {
+	cs, err := f.KubernetesClientSet()
+	if err != nil {
+		return "", err
+	}
+
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.ServiceAccount,
+			Namespace: opts.Namespace,
+		},
+	}
+	if _, err = cs.CoreV1().ServiceAccounts(opts.Namespace).Create(ctx, sa, metav1.CreateOptions{}); err != nil && !kerrors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("failed to create service account '%s': %w", opts.ServiceAccount, err)
+	}
+
+	role := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: opts.ServiceAccount + "-role",
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{"*"},
+				Resources: []string{"*"},
+				Verbs:     []string{"*"},
+			},
+			{
+				NonResourceURLs: []string{"*"},
+				Verbs:           []string{"*"},
+			},
+		},
+	}
+	if _, err = cs.RbacV1().ClusterRoles().Create(ctx, role, metav1.CreateOptions{}); err != nil {
+		if !kerrors.IsAlreadyExists(err) {
+			return "", fmt.Errorf("failed to create cluster role '%s': %w", role.Name, err)
+		}
+
+		if _, err = cs.RbacV1().ClusterRoles().Update(ctx, role, metav1.UpdateOptions{}); err != nil {
+			return "", fmt.Errorf("failed to update cluster role '%s': %w", role.Name, err)
+		}
+	}
+
+	binding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: opts.ServiceAccount + "-role-binding",
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     role.Name,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      opts.ServiceAccount,
+				Namespace: opts.Namespace,
+			},
+		},
+	}
+	if _, err = cs.RbacV1().ClusterRoleBindings().Create(ctx, binding, metav1.CreateOptions{}); err != nil {
+		if !kerrors.IsAlreadyExists(err) {
+			return "", fmt.Errorf("failed to create cluster role binding '%s': %w", binding.Name, err)
+		}
+
+		if _, err = cs.RbacV1().ClusterRoleBindings().Update(ctx, binding, metav1.UpdateOptions{}); err != nil {
+			return "", fmt.Errorf("failed to update cluster role binding '%s': %w", binding.Name, err)
+		}
+	}
+
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.ServiceAccount + "-long-lived-token",
+			Namespace: opts.Namespace,
+			Annotations: map[string]string{
+				corev1.ServiceAccountNameKey: opts.ServiceAccount,
+			},
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+	}
+	if _, err = cs.CoreV1().Secrets(opts.Namespace).Create(ctx, tokenSecret, metav1.CreateOptions{}); err != nil && !kerrors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("failed to create service account token secret: %w", err)
+	}
+
+	err = f.Wait(ctx, &kube.WaitOptions{
+		Interval: opts.WaitInterval,
+		Timeout:  opts.Timeout,
+		Resources: []kube.Resource{
+			{
+				Name:      tokenSecret.Name,
+				Namespace: opts.Namespace,
+				WaitFunc:  waitTokenPopulated,
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed waiting for the service account token: %w", err)
+	}
+
+	secret, err := cs.CoreV1().Secrets(opts.Namespace).Get(ctx, tokenSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret.Data[corev1.ServiceAccountTokenKey]), nil
+}
+
+func waitTokenPopulated(ctx context.Context, f kube.Factory, ns, name string) (bool, error) {
+	cs, err := f.KubernetesClientSet()
+	if err != nil {
+		return false, err
+	}
+
+	secret, err := cs.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return len(secret.Data[corev1.ServiceAccountTokenKey]) > 0, nil
+}
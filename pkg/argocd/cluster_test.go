@@ -0,0 +1,115 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	kubemocks "github.com/argoproj-labs/argocd-autopilot/pkg/kube/mocks"
+
+	"github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	kubetesting "k8s.io/client-go/testing"
+)
+
+func TestInstallClusterManager(t *testing.T) {
+	tests := map[string]struct {
+		objects   []runtime.Object
+		waitErr   error
+		wantToken string
+		wantErr   string
+	}{
+		"should create the service account, role, binding and token": {
+			wantToken: "token",
+		},
+		"should update an existing cluster role": {
+			objects: []runtime.Object{
+				&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "argocd-manager", Namespace: "kube-system"}},
+				&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "argocd-manager-role"}},
+			},
+			wantToken: "token",
+		},
+		"should fail if the token is not populated": {
+			waitErr: errors.New("timeout"),
+			wantErr: "failed waiting for the service account token: timeout",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			cs := kubefake.NewSimpleClientset(tt.objects...)
+			cs.PrependReactor("create", "secrets", func(action kubetesting.Action) (bool, runtime.Object, error) {
+				secret := action.(kubetesting.CreateAction).GetObject().(*corev1.Secret)
+				secret.Data = map[string][]byte{corev1.ServiceAccountTokenKey: []byte("token")}
+				return false, secret, nil
+			})
+
+			f := kubemocks.NewMockFactory(gomock.NewController(t))
+			f.EXPECT().KubernetesClientSet().Return(cs, nil).AnyTimes()
+			f.EXPECT().Wait(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, opts *kube.WaitOptions) error {
+					if tt.waitErr != nil {
+						return tt.waitErr
+					}
+
+					r := opts.Resources[0]
+					ready, err := r.WaitFunc(ctx, f, r.Namespace, r.Name)
+					assert.NoError(t, err)
+					assert.True(t, ready)
+					return nil
+				})
+
+			token, err := InstallClusterManager(context.Background(), f, &ClusterManagerOptions{
+				Namespace:      "kube-system",
+				ServiceAccount: "argocd-manager",
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantToken, token)
+
+			role, err := cs.RbacV1().ClusterRoles().Get(context.Background(), "argocd-manager-role", metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Len(t, role.Rules, 2)
+
+			binding, err := cs.RbacV1().ClusterRoleBindings().Get(context.Background(), "argocd-manager-role-binding", metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, "argocd-manager", binding.Subjects[0].Name)
+			assert.Equal(t, "kube-system", binding.Subjects[0].Namespace)
+
+			_, err = cs.CoreV1().ServiceAccounts("kube-system").Get(context.Background(), "argocd-manager", metav1.GetOptions{})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestGenerateClusterSecret(t *testing.T) {
+	secret, err := GenerateClusterSecret(&ClusterSecretOptions{
+		Name:      "arn:aws:eks:us-east-1:123:cluster/Prod",
+		Server:    "https://prod.example.com",
+		Namespace: "argocd",
+		Config:    v1alpha1.ClusterConfig{BearerToken: "token"},
+		Labels:    map[string]string{"env": "prod", "argocd.argoproj.io/secret-type": "other"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "cluster-arn-aws-eks-us-east-1-123-cluster-prod", secret.Name)
+	assert.Equal(t, "argocd", secret.Namespace)
+	assert.Equal(t, "cluster", secret.Labels["argocd.argoproj.io/secret-type"])
+	assert.Equal(t, "prod", secret.Labels["env"])
+	assert.Equal(t, "arn:aws:eks:us-east-1:123:cluster/Prod", secret.StringData["name"])
+	assert.Equal(t, "https://prod.example.com", secret.StringData["server"])
+
+	config := &v1alpha1.ClusterConfig{}
+	assert.NoError(t, json.Unmarshal([]byte(secret.StringData["config"]), config))
+	assert.Equal(t, "token", config.BearerToken)
+}
@@ -36,3 +36,38 @@ func BulkWrite(fsys fs.FS, writes ...BulkWriteRequest) error {
 	}
 	return nil
 }
+
+// CopyDir copies the src directory, with all of its files and sub directories, to dst
+func CopyDir(fsys fs.FS, src, dst string) error {
+	infos, err := fsys.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	if err = fsys.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		srcPath := fsys.Join(src, info.Name())
+		dstPath := fsys.Join(dst, info.Name())
+		if info.IsDir() {
+			if err = CopyDir(fsys, srcPath, dstPath); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		data, err := billyUtils.ReadFile(fsys, srcPath)
+		if err != nil {
+			return err
+		}
+
+		if err = billyUtils.WriteFile(fsys, dstPath, data, info.Mode()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -116,3 +116,41 @@ func (mr *MockbbUserMockRecorder) Profile() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Profile", reflect.TypeOf((*MockbbUser)(nil).Profile))
 }
+
+// MockbbDeployKeys is a mock of bbDeployKeys interface.
+type MockbbDeployKeys struct {
+	ctrl     *gomock.Controller
+	recorder *MockbbDeployKeysMockRecorder
+}
+
+// MockbbDeployKeysMockRecorder is the mock recorder for MockbbDeployKeys.
+type MockbbDeployKeysMockRecorder struct {
+	mock *MockbbDeployKeys
+}
+
+// NewMockbbDeployKeys creates a new mock instance.
+func NewMockbbDeployKeys(ctrl *gomock.Controller) *MockbbDeployKeys {
+	mock := &MockbbDeployKeys{ctrl: ctrl}
+	mock.recorder = &MockbbDeployKeysMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockbbDeployKeys) EXPECT() *MockbbDeployKeysMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockbbDeployKeys) Create(opt *bitbucket.DeployKeyOptions) (*bitbucket.DeployKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", opt)
+	ret0, _ := ret[0].(*bitbucket.DeployKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockbbDeployKeysMockRecorder) Create(opt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockbbDeployKeys)(nil).Create), opt)
+}
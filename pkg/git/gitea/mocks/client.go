@@ -34,6 +34,22 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// CreateDeployKey mocks base method.
+func (m *MockClient) CreateDeployKey(user, repo string, opt gitea.CreateKeyOption) (*gitea.DeployKey, *gitea.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeployKey", user, repo, opt)
+	ret0, _ := ret[0].(*gitea.DeployKey)
+	ret1, _ := ret[1].(*gitea.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateDeployKey indicates an expected call of CreateDeployKey.
+func (mr *MockClientMockRecorder) CreateDeployKey(user, repo, opt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeployKey", reflect.TypeOf((*MockClient)(nil).CreateDeployKey), user, repo, opt)
+}
+
 // CreateOrgRepo mocks base method.
 func (m *MockClient) CreateOrgRepo(org string, opt gitea.CreateRepoOption) (*gitea.Repository, *gitea.Response, error) {
 	m.ctrl.T.Helper()
@@ -34,6 +34,27 @@ func (m *MockGitlabClient) EXPECT() *MockGitlabClientMockRecorder {
 	return m.recorder
 }
 
+// AddDeployKey mocks base method.
+func (m *MockGitlabClient) AddDeployKey(pid interface{}, opt *gitlab.AddDeployKeyOptions, options ...gitlab.RequestOptionFunc) (*gitlab.ProjectDeployKey, *gitlab.Response, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{pid, opt}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AddDeployKey", varargs...)
+	ret0, _ := ret[0].(*gitlab.ProjectDeployKey)
+	ret1, _ := ret[1].(*gitlab.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AddDeployKey indicates an expected call of AddDeployKey.
+func (mr *MockGitlabClientMockRecorder) AddDeployKey(pid, opt interface{}, options ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{pid, opt}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeployKey", reflect.TypeOf((*MockGitlabClient)(nil).AddDeployKey), varargs...)
+}
+
 // CreateProject mocks base method.
 func (m *MockGitlabClient) CreateProject(opt *gitlab.CreateProjectOptions, options ...gitlab.RequestOptionFunc) (*gitlab.Project, *gitlab.Response, error) {
 	m.ctrl.T.Helper()
@@ -34,6 +34,20 @@ func (m *MockProvider) EXPECT() *MockProviderMockRecorder {
 	return m.recorder
 }
 
+// AddDeployKey mocks base method.
+func (m *MockProvider) AddDeployKey(ctx context.Context, orgRepo, title, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDeployKey", ctx, orgRepo, title, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDeployKey indicates an expected call of AddDeployKey.
+func (mr *MockProviderMockRecorder) AddDeployKey(ctx, orgRepo, title, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeployKey", reflect.TypeOf((*MockProvider)(nil).AddDeployKey), ctx, orgRepo, title, key)
+}
+
 // CreateRepository mocks base method.
 func (m *MockProvider) CreateRepository(ctx context.Context, orgRepo string) (string, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +50,21 @@ func (mr *MockRepositoryMockRecorder) CurrentBranch() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentBranch", reflect.TypeOf((*MockRepository)(nil).CurrentBranch))
 }
 
+// CurrentRevision mocks base method.
+func (m *MockRepository) CurrentRevision() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CurrentRevision")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CurrentRevision indicates an expected call of CurrentRevision.
+func (mr *MockRepositoryMockRecorder) CurrentRevision() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentRevision", reflect.TypeOf((*MockRepository)(nil).CurrentRevision))
+}
+
 // Persist mocks base method.
 func (m *MockRepository) Persist(ctx context.Context, opts *git.PushOptions) (string, error) {
 	m.ctrl.T.Helper()
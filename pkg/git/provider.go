@@ -25,12 +25,17 @@ type (
 		// GetAuthor gets the authenticated user's name and email address, for making git commits.
 		// Returns empty strings if not implemented
 		GetAuthor(ctx context.Context) (username, email string, err error)
+
+		// AddDeployKey adds the public key as a deploy key with write access to the
+		// repository
+		AddDeployKey(ctx context.Context, orgRepo, title, key string) error
 	}
 
 	Auth struct {
-		Username string
-		Password string
-		CertFile string
+		Username   string
+		Password   string
+		CertFile   string
+		SSHKeyFile string
 	}
 
 	// ProviderOptions for a new git provider
@@ -60,6 +65,9 @@ var (
 	ErrAuthenticationFailed = func(err error) error {
 		return fmt.Errorf("authentication failed, make sure credentials are correct: %w", err)
 	}
+	ErrDeployKeysNotSupported = func(providerType string) error {
+		return fmt.Errorf("git provider '%s' does not support deploy keys", providerType)
+	}
 
 	supportedProviders = map[string]func(*ProviderOptions) (Provider, error){
 		"bitbucket":     newBitbucket,
@@ -136,3 +144,11 @@ func (a *Auth) GetCertificate() ([]byte, error) {
 
 	return os.ReadFile(a.CertFile)
 }
+
+func (a *Auth) GetSSHPrivateKey() ([]byte, error) {
+	if a.SSHKeyFile == "" {
+		return nil, nil
+	}
+
+	return os.ReadFile(a.SSHKeyFile)
+}
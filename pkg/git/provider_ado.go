@@ -112,6 +112,11 @@ func (g *adoGit) GetAuthor(ctx context.Context) (username, email string, err err
 	return
 }
 
+func (g *adoGit) AddDeployKey(_ context.Context, _, _, _ string) error {
+	// azure devops only supports ssh keys that belong to a user
+	return ErrDeployKeysNotSupported(Azure)
+}
+
 func (a *adoGitUrl) GetProjectName() string {
 	return a.projectName
 }
@@ -63,6 +63,16 @@ type (
 		Links         Links  `json:"links"`
 	}
 
+	accessKey struct {
+		Text  string `json:"text"`
+		Label string `json:"label"`
+	}
+
+	addAccessKeyBody struct {
+		Key        accessKey `json:"key"`
+		Permission string    `json:"permission"`
+	}
+
 	userResponse struct {
 		Slug         string `json:"slug"`
 		Name         string `json:"name"`
@@ -167,6 +177,28 @@ func (bbs *bitbucketServer) GetAuthor(ctx context.Context) (username, email stri
 	return
 }
 
+func (bbs *bitbucketServer) AddDeployKey(ctx context.Context, orgRepo, title, key string) error {
+	noun, owner, name, err := splitOrgRepo(orgRepo)
+	if err != nil {
+		return err
+	}
+
+	// access keys of personal repositories are managed under the "~user" project
+	if noun == "users" {
+		owner = "~" + owner
+	}
+
+	path := fmt.Sprintf("rest/keys/1.0/projects/%s/repos/%s/ssh", owner, name)
+	_, err = bbs.request(ctx, http.MethodPost, path, &addAccessKeyBody{
+		Key: accessKey{
+			Text:  key,
+			Label: title,
+		},
+		Permission: "REPO_WRITE",
+	})
+	return err
+}
+
 func (bbs *bitbucketServer) whoAmI(ctx context.Context) (string, error) {
 	data, err := bbs.request(ctx, http.MethodGet, "/plugins/servlet/applinks/whoami", nil)
 	if err != nil {
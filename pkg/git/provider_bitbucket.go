@@ -8,13 +8,14 @@ import (
 	bb "github.com/ktrysmt/go-bitbucket"
 )
 
-//go:generate mockgen -destination=./bitbucket/mocks/client.go -package=mocks -source=./provider_bitbucket.go bbRepo bbUser
+//go:generate mockgen -destination=./bitbucket/mocks/client.go -package=mocks -source=./provider_bitbucket.go bbRepo bbUser bbDeployKeys
 
 type (
 	bitbucket struct {
 		opts       *ProviderOptions
 		Repository bbRepo
 		User       bbUser
+		DeployKeys bbDeployKeys
 	}
 
 	bbRepo interface {
@@ -26,6 +27,10 @@ type (
 		Profile() (*bb.User, error)
 		Emails() (interface{}, error)
 	}
+
+	bbDeployKeys interface {
+		Create(opt *bb.DeployKeyOptions) (*bb.DeployKey, error)
+	}
 )
 
 func newBitbucket(opts *ProviderOptions) (Provider, error) {
@@ -44,6 +49,7 @@ func newBitbucket(opts *ProviderOptions) (Provider, error) {
 		opts:       opts,
 		Repository: c.Repositories.Repository,
 		User:       c.User,
+		DeployKeys: c.Repositories.DeployKeys,
 	}
 
 	return g, nil
@@ -117,6 +123,27 @@ func (g *bitbucket) GetAuthor(_ context.Context) (username, email string, err er
 	return
 }
 
+func (g *bitbucket) AddDeployKey(ctx context.Context, orgRepo, title, key string) error {
+	opts, err := getDefaultRepoOptions(orgRepo)
+	if err != nil {
+		return err
+	}
+
+	deployKeyOpts := &bb.DeployKeyOptions{
+		Owner:    opts.Owner,
+		RepoSlug: opts.Name,
+		Label:    title,
+		Key:      key,
+	}
+
+	_, err = g.DeployKeys.Create(deployKeyOpts.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed adding deploy key to the repository \"%s\" under \"%s\": %w", opts.Name, opts.Owner, err)
+	}
+
+	return nil
+}
+
 func (g *bitbucket) getAuthenticatedUser() (*bb.User, error) {
 	user, err := g.User.Profile()
 
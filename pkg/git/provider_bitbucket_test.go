@@ -297,3 +297,55 @@ func Test_bitbucket_GetAuthor(t *testing.T) {
 		})
 	}
 }
+
+func Test_bitbucket_AddDeployKey(t *testing.T) {
+	tests := map[string]struct {
+		orgRepo  string
+		wantErr  string
+		beforeFn func(*bbmocks.MockbbDeployKeys)
+	}{
+		"Should fail if orgRepo is invalid": {
+			orgRepo: "invalid",
+			wantErr: "failed parsing organization and repo from 'invalid'",
+		},
+		"Should fail if Create fails": {
+			orgRepo: "owner/repo",
+			wantErr: "failed adding deploy key to the repository \"repo\" under \"owner\": 404 Not Found",
+			beforeFn: func(c *bbmocks.MockbbDeployKeys) {
+				c.EXPECT().Create(gomock.Any()).
+					Times(1).
+					Return(nil, &bb.UnexpectedResponseStatusError{Status: "404 Not Found"})
+			},
+		},
+		"Should add a deploy key": {
+			orgRepo: "owner/repo",
+			beforeFn: func(c *bbmocks.MockbbDeployKeys) {
+				opts := &bb.DeployKeyOptions{
+					Owner:    "owner",
+					RepoSlug: "repo",
+					Label:    "title",
+					Key:      "ssh-ed25519 AAAA",
+				}
+				c.EXPECT().Create(opts.WithContext(context.Background())).
+					Times(1).
+					Return(&bb.DeployKey{}, nil)
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockDeployKeysClient := bbmocks.NewMockbbDeployKeys(gomock.NewController(t))
+			if tt.beforeFn != nil {
+				tt.beforeFn(mockDeployKeysClient)
+			}
+
+			g := &bitbucket{
+				DeployKeys: mockDeployKeysClient,
+			}
+			err := g.AddDeployKey(context.Background(), tt.orgRepo, "title", "ssh-ed25519 AAAA")
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ type (
 		CreateRepo(opt gt.CreateRepoOption) (*gt.Repository, *gt.Response, error)
 		GetRepo(owner, reponame string) (*gt.Repository, *gt.Response, error)
 		GetMyUserInfo() (*gt.User, *gt.Response, error)
+		CreateDeployKey(user, repo string, opt gt.CreateKeyOption) (*gt.DeployKey, *gt.Response, error)
 	}
 
 	gitea struct {
@@ -114,6 +115,28 @@ func (g *gitea) GetAuthor(_ context.Context) (username, email string, err error)
 	return
 }
 
+func (g *gitea) AddDeployKey(_ context.Context, orgRepo, title, key string) error {
+	opts, err := getDefaultRepoOptions(orgRepo)
+	if err != nil {
+		return err
+	}
+
+	_, res, err := g.client.CreateDeployKey(opts.Owner, opts.Name, gt.CreateKeyOption{
+		Title:    title,
+		Key:      key,
+		ReadOnly: false,
+	})
+	if err != nil {
+		if res != nil && res.StatusCode == 404 {
+			return fmt.Errorf("repo %s not found: %w", orgRepo, err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
 func (g *gitea) getAuthenticatedUser() (*gt.User, error) {
 	authUser, res, err := g.client.GetMyUserInfo()
 	if err != nil {
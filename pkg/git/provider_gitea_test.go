@@ -258,3 +258,54 @@ func Test_gitea_GetAuthor(t *testing.T) {
 		})
 	}
 }
+
+func Test_gitea_AddDeployKey(t *testing.T) {
+	tests := map[string]struct {
+		orgRepo  string
+		wantErr  string
+		beforeFn func(*gtmocks.MockClient)
+	}{
+		"Should fail if orgRepo is invalid": {
+			orgRepo: "invalid",
+			wantErr: "failed parsing organization and repo from 'invalid'",
+		},
+		"Should fail if CreateDeployKey fails with 404": {
+			orgRepo: "owner/repo",
+			wantErr: "repo owner/repo not found: some error",
+			beforeFn: func(mc *gtmocks.MockClient) {
+				res := &gt.Response{
+					Response: &http.Response{
+						StatusCode: 404,
+					},
+				}
+				mc.EXPECT().CreateDeployKey("owner", "repo", gomock.Any()).Times(1).Return(nil, res, errors.New("some error"))
+			},
+		},
+		"Should add a deploy key with write access": {
+			orgRepo: "owner/repo",
+			beforeFn: func(mc *gtmocks.MockClient) {
+				mc.EXPECT().CreateDeployKey("owner", "repo", gt.CreateKeyOption{
+					Title:    "title",
+					Key:      "ssh-ed25519 AAAA",
+					ReadOnly: false,
+				}).Times(1).Return(&gt.DeployKey{}, nil, nil)
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockClient := gtmocks.NewMockClient(gomock.NewController(t))
+			if tt.beforeFn != nil {
+				tt.beforeFn(mockClient)
+			}
+
+			g := &gitea{
+				client: mockClient,
+			}
+			err := g.AddDeployKey(context.Background(), tt.orgRepo, "title", "ssh-ed25519 AAAA")
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
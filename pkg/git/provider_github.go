@@ -134,6 +134,28 @@ func (g *github) GetAuthor(ctx context.Context) (username, email string, err err
 	return
 }
 
+func (g *github) AddDeployKey(ctx context.Context, orgRepo, title, key string) error {
+	opts, err := getDefaultRepoOptions(orgRepo)
+	if err != nil {
+		return err
+	}
+
+	_, res, err := g.Repositories.CreateKey(ctx, opts.Owner, opts.Name, &gh.Key{
+		Title:    gh.String(title),
+		Key:      gh.String(key),
+		ReadOnly: gh.Bool(false),
+	})
+	if err != nil {
+		if res != nil && res.StatusCode == 404 {
+			return fmt.Errorf("repo %s not found: %w", orgRepo, err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
 func (g *github) getAuthenticatedUser(ctx context.Context) (*gh.User, error) {
 	authUser, res, err := g.Users.Get(ctx, "")
 	if err != nil {
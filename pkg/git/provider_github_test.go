@@ -445,3 +445,54 @@ func Test_github_getEmail(t *testing.T) {
 		})
 	}
 }
+
+func Test_github_AddDeployKey(t *testing.T) {
+	tests := map[string]struct {
+		orgRepo  string
+		wantErr  string
+		beforeFn func(*mocks.MockRepositories)
+	}{
+		"Should fail if orgRepo is invalid": {
+			orgRepo: "invalid",
+			wantErr: "failed parsing organization and repo from 'invalid'",
+		},
+		"Should fail if CreateKey fails with 404": {
+			orgRepo: "owner/repo",
+			wantErr: "repo owner/repo not found: some error",
+			beforeFn: func(mr *mocks.MockRepositories) {
+				res := &gh.Response{
+					Response: &http.Response{
+						StatusCode: 404,
+					},
+				}
+				mr.EXPECT().CreateKey(context.Background(), "owner", "repo", gomock.Any()).Times(1).Return(nil, res, errors.New("some error"))
+			},
+		},
+		"Should add a deploy key with write access": {
+			orgRepo: "owner/repo",
+			beforeFn: func(mr *mocks.MockRepositories) {
+				mr.EXPECT().CreateKey(context.Background(), "owner", "repo", &gh.Key{
+					Title:    gh.String("title"),
+					Key:      gh.String("ssh-ed25519 AAAA"),
+					ReadOnly: gh.Bool(false),
+				}).Times(1).Return(&gh.Key{}, nil, nil)
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := mocks.NewMockRepositories(gomock.NewController(t))
+			if tt.beforeFn != nil {
+				tt.beforeFn(mockRepo)
+			}
+
+			g := &github{
+				Repositories: mockRepo,
+			}
+			err := g.AddDeployKey(context.Background(), tt.orgRepo, "title", "ssh-ed25519 AAAA")
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -17,12 +17,14 @@ type (
 		CreateProject(opt *gl.CreateProjectOptions, options ...gl.RequestOptionFunc) (*gl.Project, *gl.Response, error)
 		GetProject(pid interface{}, opt *gl.GetProjectOptions, options ...gl.RequestOptionFunc) (*gl.Project, *gl.Response, error)
 		GetGroup(gid interface{}, opt *gl.GetGroupOptions, options ...gl.RequestOptionFunc) (*gl.Group, *gl.Response, error)
+		AddDeployKey(pid interface{}, opt *gl.AddDeployKeyOptions, options ...gl.RequestOptionFunc) (*gl.ProjectDeployKey, *gl.Response, error)
 	}
 
 	clientImpl struct {
 		gl.ProjectsServiceInterface
 		gl.UsersServiceInterface
 		gl.GroupsServiceInterface
+		gl.DeployKeysServiceInterface
 	}
 
 	gitlab struct {
@@ -52,9 +54,10 @@ func newGitlab(opts *ProviderOptions) (Provider, error) {
 	g := &gitlab{
 		opts: opts,
 		client: &clientImpl{
-			ProjectsServiceInterface: c.Projects,
-			UsersServiceInterface:    c.Users,
-			GroupsServiceInterface:   c.Groups,
+			ProjectsServiceInterface:   c.Projects,
+			UsersServiceInterface:      c.Users,
+			GroupsServiceInterface:     c.Groups,
+			DeployKeysServiceInterface: c.DeployKeys,
 		},
 	}
 
@@ -135,6 +138,23 @@ func (g *gitlab) GetAuthor(_ context.Context) (username, email string, err error
 	return
 }
 
+func (g *gitlab) AddDeployKey(_ context.Context, orgRepo, title, key string) error {
+	_, res, err := g.client.AddDeployKey(orgRepo, &gl.AddDeployKeyOptions{
+		Title:   gl.Ptr(title),
+		Key:     gl.Ptr(key),
+		CanPush: gl.Ptr(true),
+	})
+	if err != nil {
+		if res != nil && res.StatusCode == 404 {
+			return fmt.Errorf("project \"%s\" not found: %w", orgRepo, err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
 func (g *gitlab) getAuthenticatedUser() (*gl.User, error) {
 	authUser, res, err := g.client.CurrentUser()
 	if err != nil {
@@ -332,3 +332,50 @@ func Test_gitlab_GetAuthor(t *testing.T) {
 		})
 	}
 }
+
+func Test_gitlab_AddDeployKey(t *testing.T) {
+	tests := map[string]struct {
+		orgRepo  string
+		wantErr  string
+		beforeFn func(*glmocks.MockGitlabClient)
+	}{
+		"Should fail if AddDeployKey fails with 404": {
+			orgRepo: "owner/repo",
+			wantErr: "project \"owner/repo\" not found: some error",
+			beforeFn: func(mc *glmocks.MockGitlabClient) {
+				res := &gl.Response{
+					Response: &http.Response{
+						StatusCode: 404,
+					},
+				}
+				mc.EXPECT().AddDeployKey("owner/repo", gomock.Any()).Times(1).Return(nil, res, errors.New("some error"))
+			},
+		},
+		"Should add a deploy key that can push": {
+			orgRepo: "owner/repo",
+			beforeFn: func(mc *glmocks.MockGitlabClient) {
+				mc.EXPECT().AddDeployKey("owner/repo", &gl.AddDeployKeyOptions{
+					Title:   gl.Ptr("title"),
+					Key:     gl.Ptr("ssh-ed25519 AAAA"),
+					CanPush: gl.Ptr(true),
+				}).Times(1).Return(&gl.ProjectDeployKey{}, nil, nil)
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockClient := glmocks.NewMockGitlabClient(gomock.NewController(t))
+			if tt.beforeFn != nil {
+				tt.beforeFn(mockClient)
+			}
+
+			g := &gitlab{
+				client: mockClient,
+			}
+			err := g.AddDeployKey(context.Background(), tt.orgRepo, "title", "ssh-ed25519 AAAA")
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package git
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
@@ -25,10 +28,12 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
 )
 
 //go:generate mockgen -destination=./mocks/repository.go -package=mocks -source=./repository.go Repository
@@ -42,6 +47,8 @@ type (
 		Persist(ctx context.Context, opts *PushOptions) (string, error)
 		// CurrentBranch returns the name of the current branch
 		CurrentBranch() (string, error)
+		// CurrentRevision returns the commit hash of the current HEAD
+		CurrentRevision() (string, error)
 	}
 
 	AddFlagsOptions struct {
@@ -152,6 +159,10 @@ var (
 	worktree = func(r gogit.Repository) (gogit.Worktree, error) {
 		return r.Worktree()
 	}
+
+	generateSSHKey = func(path string) (string, error) {
+		return generateSSHKeyPair(path)
+	}
 )
 
 func AddFlags(cmd *cobra.Command, opts *AddFlagsOptions) *CloneOptions {
@@ -169,10 +180,12 @@ func AddFlags(cmd *cobra.Command, opts *AddFlagsOptions) *CloneOptions {
 	cmd.PersistentFlags().StringVar(&co.Auth.Password, opts.Prefix+"git-token", "", fmt.Sprintf("Your git provider api token [%sGIT_TOKEN]", envPrefix))
 	cmd.PersistentFlags().StringVar(&co.Auth.Username, opts.Prefix+"git-user", "", fmt.Sprintf("Your git provider user name [%sGIT_USER] (not required in GitHub)", envPrefix))
 	cmd.PersistentFlags().StringVar(&co.Auth.CertFile, opts.Prefix+"git-server-crt", "", fmt.Sprint("Git Server certificate file", envPrefix))
+	cmd.PersistentFlags().StringVar(&co.Auth.SSHKeyFile, opts.Prefix+"git-ssh-key", "", fmt.Sprintf("A private ssh key file, if set will clone and push over ssh instead of https [%sGIT_SSH_KEY]", envPrefix))
 	cmd.PersistentFlags().StringVar(&co.Repo, opts.Prefix+"repo", "", fmt.Sprintf("Repository URL [%sGIT_REPO]", envPrefix))
 
 	util.Die(viper.BindEnv(opts.Prefix+"git-token", envPrefix+"GIT_TOKEN"))
 	util.Die(viper.BindEnv(opts.Prefix+"git-user", envPrefix+"GIT_USER"))
+	util.Die(viper.BindEnv(opts.Prefix+"git-ssh-key", envPrefix+"GIT_SSH_KEY"))
 	util.Die(viper.BindEnv(opts.Prefix+"repo", envPrefix+"GIT_REPO"))
 
 	if opts.Prefix == "" {
@@ -190,7 +203,8 @@ func AddFlags(cmd *cobra.Command, opts *AddFlagsOptions) *CloneOptions {
 	}
 
 	if !opts.Optional {
-		util.Die(cmd.MarkPersistentFlagRequired(opts.Prefix + "git-token"))
+		// an ssh key is enough to clone and push, without a git token
+		cmd.MarkFlagsOneRequired(opts.Prefix+"git-token", opts.Prefix+"git-ssh-key")
 		util.Die(cmd.MarkPersistentFlagRequired(opts.Prefix + "repo"))
 	}
 
@@ -206,6 +220,9 @@ func (o *CloneOptions) Parse() {
 
 	host, orgRepo, o.path, o.revision, _, suffix, _ = util.ParseGitUrl(o.Repo)
 	o.url = host + orgRepo + suffix
+	if o.Auth.SSHKeyFile != "" {
+		o.url = getSSHURL(host, orgRepo, suffix)
+	}
 
 	if o.Auth.Username == "" {
 		o.Auth.Username = store.Default.GitHubUsername
@@ -277,6 +294,50 @@ var validateRepoWritePermission = func(ctx context.Context, r *repo) error {
 	return nil
 }
 
+// CreateDeployKey generates a new ed25519 key pair in the ssh key file, and adds its
+// public key as a deploy key of the repository. If the repository does not exist
+// yet and CreateIfNotExist is set, it will be created first
+func (o *CloneOptions) CreateDeployKey(ctx context.Context, title string) error {
+	if o == nil {
+		return ErrNilOpts
+	}
+
+	if o.Auth.SSHKeyFile == "" {
+		return errors.New("an ssh key file is required in order to create a deploy key")
+	}
+
+	if _, err := os.Stat(o.Auth.SSHKeyFile); err == nil {
+		return fmt.Errorf("ssh key file '%s' already exists", o.Auth.SSHKeyFile)
+	}
+
+	provider, err := getProvider(o.Provider, o.Repo, &o.Auth)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := generateSSHKey(o.Auth.SSHKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to generate ssh key: %w", err)
+	}
+
+	_, orgRepo, _, _, _, _, _ := util.ParseGitUrl(o.Repo)
+	err = provider.AddDeployKey(ctx, orgRepo, title, publicKey)
+	if err != nil && o.CreateIfNotExist {
+		log.G(ctx).Infof("failed to add deploy key to '%s', trying to create the repository...", o.Repo)
+		if _, createErr := createRepo(ctx, o); createErr != nil {
+			return fmt.Errorf("failed to add deploy key: %w", err)
+		}
+
+		err = provider.AddDeployKey(ctx, orgRepo, title, publicKey)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to add deploy key: %w", err)
+	}
+
+	return nil
+}
+
 func (o *CloneOptions) URL() string {
 	return o.url
 }
@@ -304,6 +365,11 @@ func (r *repo) Persist(ctx context.Context, opts *PushOptions) (string, error) {
 		return "", fmt.Errorf("failed reading git certificate file: %w", err)
 	}
 
+	auth, err := getAuth(r.auth)
+	if err != nil {
+		return "", fmt.Errorf("failed reading git ssh key file: %w", err)
+	}
+
 	h, err := r.commit(ctx, opts)
 	if err != nil {
 		return "", err
@@ -311,7 +377,7 @@ func (r *repo) Persist(ctx context.Context, opts *PushOptions) (string, error) {
 
 	for try := 0; try < pushRetries; try++ {
 		err = r.PushContext(ctx, &gg.PushOptions{
-			Auth:     getAuth(r.auth),
+			Auth:     auth,
 			Progress: progress,
 			CABundle: cert,
 		})
@@ -339,6 +405,15 @@ func (r *repo) CurrentBranch() (string, error) {
 	return ref.Name().Short(), nil
 }
 
+func (r *repo) CurrentRevision() (string, error) {
+	ref, err := r.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve ref: %w", err)
+	}
+
+	return ref.Hash().String(), nil
+}
+
 func (r *repo) commit(ctx context.Context, opts *PushOptions) (*plumbing.Hash, error) {
 	var h plumbing.Hash
 
@@ -441,9 +516,14 @@ var clone = func(ctx context.Context, opts *CloneOptions) (*repo, error) {
 		return nil, fmt.Errorf("failed reading git certificate file: %w", err)
 	}
 
+	auth, err := getAuth(opts.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading git ssh key file: %w", err)
+	}
+
 	cloneOpts := &gg.CloneOptions{
 		URL:      opts.url,
-		Auth:     getAuth(opts.Auth),
+		Auth:     auth,
 		Depth:    1,
 		Progress: progress,
 		CABundle: cert,
@@ -727,15 +807,65 @@ func (r *repo) initBranch(ctx context.Context, branchName string) error {
 	})
 }
 
-func getAuth(auth Auth) transport.AuthMethod {
+func getAuth(auth Auth) (transport.AuthMethod, error) {
+	if auth.SSHKeyFile != "" {
+		return gitssh.NewPublicKeysFromFile("git", auth.SSHKeyFile, "")
+	}
+
 	if auth.Password == "" {
-		return nil
+		return nil, nil
 	}
 
 	return &http.BasicAuth{
 		Username: auth.Username,
 		Password: auth.Password,
+	}, nil
+}
+
+// getSSHURL returns the scp-like ssh url of an http(s) repository url
+func getSSHURL(host, orgRepo, suffix string) string {
+	u, err := url.Parse(host)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		// already an ssh url
+		return host + orgRepo + suffix
+	}
+
+	if strings.Contains(u.Hostname(), AzureHostName) {
+		return fmt.Sprintf("git@ssh.%s:v3%s%s%s", u.Hostname(), strings.TrimSuffix(u.Path, "_git/"), orgRepo, suffix)
+	}
+
+	// the port of the http(s) url is never the ssh port of the server, so it is dropped
+	return fmt.Sprintf("git@%s:%s%s", u.Hostname(), orgRepo, suffix)
+}
+
+// generateSSHKeyPair writes a new ed25519 private key to path, and its public key
+// to path.pub. Returns the public key in authorized_keys format
+func generateSSHKeyPair(path string) (string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
 	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		return "", err
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	publicKey := ssh.MarshalAuthorizedKey(sshPub)
+	if err = os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return "", err
+	}
+
+	if err = os.WriteFile(path+".pub", publicKey, 0644); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(publicKey)), nil
 }
 
 // a hack to handle case where bitbucket-server returns http 200 when repo not found
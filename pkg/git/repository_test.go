@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
@@ -19,11 +21,13 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/golang/mock/gomock"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
 )
 
 type mockProvider struct {
@@ -32,6 +36,8 @@ type mockProvider struct {
 	getDefaultBranch func(orgRepo string) (string, error)
 
 	getAuthor func() (string, string, error)
+
+	addDeployKey func(orgRepo, title, key string) error
 }
 
 func (p *mockProvider) CreateRepository(_ context.Context, orgRepo string) (defaultBranch string, err error) {
@@ -50,6 +56,10 @@ func (p *mockProvider) GetAuthor(ctx context.Context) (username, email string, e
 	return "username", "user@email.com", nil
 }
 
+func (p *mockProvider) AddDeployKey(_ context.Context, orgRepo, title, key string) error {
+	return p.addDeployKey(orgRepo, title, key)
+}
+
 func Test_repo_addRemote(t *testing.T) {
 	type args struct {
 		name string
@@ -106,8 +116,9 @@ func Test_repo_addRemote(t *testing.T) {
 
 func Test_getAuth(t *testing.T) {
 	tests := map[string]struct {
-		auth Auth
-		want transport.AuthMethod
+		auth    Auth
+		want    transport.AuthMethod
+		wantErr string
 	}{
 		"Should use the supplied username": {
 			auth: Auth{
@@ -123,16 +134,203 @@ func Test_getAuth(t *testing.T) {
 			auth: Auth{},
 			want: nil,
 		},
+		"Should fail if the ssh key file does not exist": {
+			auth: Auth{
+				Password:   "123",
+				SSHKeyFile: "/does/not/exist",
+			},
+			wantErr: "open /does/not/exist: no such file or directory",
+		},
 	}
 	for tname, tt := range tests {
 		t.Run(tname, func(t *testing.T) {
-			if got := getAuth(tt.auth); !reflect.DeepEqual(got, tt.want) {
+			got, err := getAuth(tt.auth)
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("getAuth() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func Test_getAuth_sshKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	_, err := generateSSHKeyPair(keyFile)
+	assert.NoError(t, err)
+
+	got, err := getAuth(Auth{
+		Password:   "123",
+		SSHKeyFile: keyFile,
+	})
+	assert.NoError(t, err)
+	publicKeys, ok := got.(*gitssh.PublicKeys)
+	assert.True(t, ok)
+	assert.Equal(t, "git", publicKeys.User)
+}
+
+func Test_getSSHURL(t *testing.T) {
+	tests := map[string]struct {
+		host    string
+		orgRepo string
+		suffix  string
+		want    string
+	}{
+		"Should convert an https url": {
+			host:    "https://github.com/",
+			orgRepo: "owner/name",
+			suffix:  ".git",
+			want:    "git@github.com:owner/name.git",
+		},
+		"Should keep an ssh url": {
+			host:    "git@github.com:",
+			orgRepo: "owner/name",
+			suffix:  ".git",
+			want:    "git@github.com:owner/name.git",
+		},
+		"Should drop the http port of the url": {
+			host:    "https://git.example.com:8443/",
+			orgRepo: "owner/name",
+			suffix:  ".git",
+			want:    "git@git.example.com:owner/name.git",
+		},
+		"Should convert an azure devops url": {
+			host:    "https://dev.azure.com/org/project/_git/",
+			orgRepo: "name",
+			want:    "git@ssh.dev.azure.com:v3/org/project/name",
+		},
+		"Should keep the suffix of an azure devops url": {
+			host:    "https://dev.azure.com/org/project/_git/",
+			orgRepo: "name",
+			suffix:  ".git",
+			want:    "git@ssh.dev.azure.com:v3/org/project/name.git",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			assert.Equal(t, tt.want, getSSHURL(tt.host, tt.orgRepo, tt.suffix))
+		})
+	}
+}
+
+func Test_generateSSHKeyPair(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	publicKey, err := generateSSHKeyPair(keyFile)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(publicKey, "ssh-ed25519 "))
+
+	privateKey, err := os.ReadFile(keyFile)
+	assert.NoError(t, err)
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	assert.NoError(t, err)
+	assert.Equal(t, publicKey, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))))
+
+	pubFile, err := os.ReadFile(keyFile + ".pub")
+	assert.NoError(t, err)
+	assert.Equal(t, publicKey, strings.TrimSpace(string(pubFile)))
+
+	info, err := os.Stat(keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestCloneOptions_CreateDeployKey(t *testing.T) {
+	tests := map[string]struct {
+		opts         *CloneOptions
+		keyExists    bool
+		addDeployKey func(calls int) error
+		createRepo   func() (string, error)
+		wantCalls    int
+		wantErr      string
+	}{
+		"Should fail if the ssh key file already exists": {
+			opts:      &CloneOptions{Repo: "https://github.com/owner/name"},
+			keyExists: true,
+			wantErr:   "ssh key file 'id_ed25519' already exists",
+		},
+		"Should add the deploy key": {
+			opts: &CloneOptions{Repo: "https://github.com/owner/name"},
+			addDeployKey: func(_ int) error {
+				return nil
+			},
+			wantCalls: 1,
+		},
+		"Should create the repository and try again": {
+			opts: &CloneOptions{Repo: "https://github.com/owner/name", CreateIfNotExist: true},
+			addDeployKey: func(calls int) error {
+				if calls == 1 {
+					return errors.New("not found")
+				}
+
+				return nil
+			},
+			createRepo: func() (string, error) {
+				return "main", nil
+			},
+			wantCalls: 2,
+		},
+		"Should fail if the repository could not be created": {
+			opts: &CloneOptions{Repo: "https://github.com/owner/name", CreateIfNotExist: true},
+			addDeployKey: func(_ int) error {
+				return errors.New("not found")
+			},
+			createRepo: func() (string, error) {
+				return "", errors.New("some error")
+			},
+			wantCalls: 1,
+			wantErr:   "failed to add deploy key: not found",
+		},
+		"Should fail if the deploy key could not be added": {
+			opts: &CloneOptions{Repo: "https://github.com/owner/name"},
+			addDeployKey: func(_ int) error {
+				return errors.New("some error")
+			},
+			wantCalls: 1,
+			wantErr:   "failed to add deploy key: some error",
+		},
+	}
+	origGetProvider, origCreateRepo, origGenerateSSHKey := getProvider, createRepo, generateSSHKey
+	defer func() {
+		getProvider, createRepo, generateSSHKey = origGetProvider, origCreateRepo, origGenerateSSHKey
+	}()
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			tt.opts.Auth.SSHKeyFile = "id_ed25519"
+			if tt.keyExists {
+				assert.NoError(t, os.WriteFile(tt.opts.Auth.SSHKeyFile, []byte{}, 0600))
+			}
+
+			calls := 0
+			getProvider = func(_, _ string, _ *Auth) (Provider, error) {
+				return &mockProvider{addDeployKey: func(orgRepo, title, key string) error {
+					calls++
+					assert.Equal(t, "owner/name", orgRepo)
+					assert.Equal(t, "title", title)
+					assert.Equal(t, "ssh-ed25519 AAAA", key)
+					return tt.addDeployKey(calls)
+				}}, nil
+			}
+			createRepo = func(_ context.Context, _ *CloneOptions) (string, error) {
+				return tt.createRepo()
+			}
+			generateSSHKey = func(_ string) (string, error) {
+				return "ssh-ed25519 AAAA", nil
+			}
+
+			err := tt.opts.CreateDeployKey(context.Background(), "title")
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
+
 var globalGitConfig = &config.Config{
 	User: struct {
 		Name  string
@@ -1222,7 +1420,6 @@ func TestAddFlags(t *testing.T) {
 					name:      "git-token",
 					shorthand: "t",
 					usage:     "Your git provider api token [GIT_TOKEN]",
-					required:  true,
 				},
 				{
 					name:     "repo",
@@ -1253,9 +1450,8 @@ func TestAddFlags(t *testing.T) {
 			},
 			wantedFlags: []flag{
 				{
-					name:     "prefix-git-token",
-					usage:    "Your git provider api token [PREFIX_GIT_TOKEN]",
-					required: true,
+					name:  "prefix-git-token",
+					usage: "Your git provider api token [PREFIX_GIT_TOKEN]",
 				},
 				{
 					name:     "prefix-repo",
@@ -1270,9 +1466,8 @@ func TestAddFlags(t *testing.T) {
 			},
 			wantedFlags: []flag{
 				{
-					name:     "prefix-git-token",
-					usage:    "Your git provider api token [PREFIX_GIT_TOKEN]",
-					required: true,
+					name:  "prefix-git-token",
+					usage: "Your git provider api token [PREFIX_GIT_TOKEN]",
 				},
 				{
 					name:     "prefix-repo",
@@ -1317,6 +1512,40 @@ func TestAddFlags(t *testing.T) {
 	}
 }
 
+func TestAddFlags_auth(t *testing.T) {
+	tests := map[string]struct {
+		args    []string
+		wantErr string
+	}{
+		"Should require a git token or an ssh key": {
+			args:    []string{"--repo", "https://github.com/owner/name"},
+			wantErr: "at least one of the flags in the group [git-token git-ssh-key] is required",
+		},
+		"Should accept a git token": {
+			args: []string{"--repo", "https://github.com/owner/name", "--git-token", "token"},
+		},
+		"Should accept an ssh key without a git token": {
+			args: []string{"--repo", "https://github.com/owner/name", "--git-ssh-key", "id_ed25519"},
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			viper.Reset()
+			cmd := &cobra.Command{RunE: func(_ *cobra.Command, _ []string) error { return nil }}
+			_ = AddFlags(cmd, &AddFlagsOptions{FS: memfs.New()})
+			cmd.SetArgs(tt.args)
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
 func Test_createRepo(t *testing.T) {
 	tests := map[string]struct {
 		opts    *CloneOptions
@@ -1359,7 +1588,7 @@ func Test_createRepo(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			mockProvider := &mockProvider{func(orgRepo string) (defaultBranch string, err error) {
 				return "main", nil
-			}, nil, nil}
+			}, nil, nil, nil}
 			getProvider = func(providerType, repoURL string, auth *Auth) (Provider, error) { return mockProvider, nil }
 			got, err := createRepo(context.Background(), tt.opts)
 			if err != nil || tt.wantErr != "" {
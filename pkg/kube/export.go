@@ -0,0 +1,88 @@
+package kube
+
+import (
+	"sort"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/util"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// annotations that are set by the cluster, or by the tool that applied the object
+var exportIgnoredAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+}
+
+// ExportObjects returns the manifests of objs, sorted by kind, namespace and name, without
+// any of the fields that are set by the cluster. Objects that are owned by another object
+// are skipped, because they are created by a controller
+func ExportObjects(objs []*unstructured.Unstructured) ([]byte, error) {
+	exported := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if len(obj.GetOwnerReferences()) > 0 {
+			continue
+		}
+
+		exported = append(exported, cleanObject(obj))
+	}
+
+	sort.Slice(exported, func(i, j int) bool {
+		a, b := exported[i], exported[j]
+		if a.GetKind() != b.GetKind() {
+			return a.GetKind() < b.GetKind()
+		}
+
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+
+		return a.GetName() < b.GetName()
+	})
+
+	manifests := make([][]byte, 0, len(exported))
+	for _, obj := range exported {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		manifests = append(manifests, data)
+	}
+
+	return util.JoinManifests(manifests...), nil
+}
+
+func cleanObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	for _, field := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "managedFields", "selfLink"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+
+	unstructured.RemoveNestedField(obj.Object, "status")
+	unstructured.RemoveNestedField(obj.Object, "spec", "template", "metadata", "creationTimestamp")
+
+	annotations := obj.GetAnnotations()
+	for _, annotation := range exportIgnoredAnnotations {
+		delete(annotations, annotation)
+	}
+
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+	} else {
+		obj.SetAnnotations(annotations)
+	}
+
+	switch obj.GetKind() {
+	case "Service":
+		// allocated by the cluster
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+	case "ServiceAccount":
+		// the legacy token secrets are created by the cluster
+		unstructured.RemoveNestedField(obj.Object, "secrets")
+	}
+
+	return obj
+}
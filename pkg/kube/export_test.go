@@ -0,0 +1,129 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func toUnstructured(t *testing.T, manifest string) *unstructured.Unstructured {
+	obj := map[string]interface{}{}
+	assert.NoError(t, yaml.Unmarshal([]byte(manifest), &obj))
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func TestExportObjects(t *testing.T) {
+	objs := []*unstructured.Unstructured{
+		toUnstructured(t, `apiVersion: v1
+kind: Service
+metadata:
+  name: argocd-server
+  namespace: argocd
+  uid: 1234
+  resourceVersion: "1"
+  creationTimestamp: "2024-01-01T00:00:00Z"
+  labels:
+    app.kubernetes.io/part-of: argocd
+spec:
+  clusterIP: 10.0.0.1
+  clusterIPs:
+  - 10.0.0.1
+  ports:
+  - port: 80
+status:
+  loadBalancer: {}
+`),
+		toUnstructured(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: argocd-server
+  namespace: argocd
+  generation: 3
+  annotations:
+    deployment.kubernetes.io/revision: "3"
+    kubectl.kubernetes.io/last-applied-configuration: "{}"
+  managedFields:
+  - manager: kubectl
+spec:
+  template:
+    metadata:
+      creationTimestamp: null
+      labels:
+        app: server
+`),
+		toUnstructured(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: argocd-cm
+  namespace: argocd
+  annotations:
+    meta.helm.sh/release-name: argocd
+    kubectl.kubernetes.io/last-applied-configuration: "{}"
+data:
+  key: value
+`),
+		toUnstructured(t, `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: argocd-server
+  namespace: argocd
+secrets:
+- name: argocd-server-token
+`),
+		toUnstructured(t, `apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: argocd-server-1234
+  namespace: argocd
+  ownerReferences:
+  - kind: Deployment
+    name: argocd-server
+`),
+	}
+
+	got, err := ExportObjects(objs)
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+data:
+  key: value
+kind: ConfigMap
+metadata:
+  annotations:
+    meta.helm.sh/release-name: argocd
+  name: argocd-cm
+  namespace: argocd
+
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: argocd-server
+  namespace: argocd
+spec:
+  template:
+    metadata:
+      labels:
+        app: server
+
+---
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    app.kubernetes.io/part-of: argocd
+  name: argocd-server
+  namespace: argocd
+spec:
+  ports:
+  - port: 80
+
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: argocd-server
+  namespace: argocd
+`, string(got))
+}
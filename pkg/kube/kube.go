@@ -14,9 +14,11 @@ import (
 	"github.com/spf13/pflag"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
@@ -68,6 +70,13 @@ type (
 		// Delete deletes a specific resource by namespace/name
 		DeleteResource(context.Context, *DeleteResourceOptions) error
 
+		// List lists the resources by their type(s) and labelSelector, in all namespaces
+		List(context.Context, *ListOptions) ([]ObjectRef, error)
+
+		// Export returns the manifests of the resources by their type(s) and labelSelector,
+		// without any of the fields that are set by the cluster
+		Export(context.Context, *ListOptions) ([]byte, error)
+
 		// Wait waits for all of the provided `Resources` to be ready by calling
 		// the `WaitFunc` of each resource until all of them returns `true`
 		Wait(context.Context, *WaitOptions) error
@@ -92,6 +101,20 @@ type (
 		WaitForDeletion bool
 	}
 
+	ListOptions struct {
+		LabelSelector string
+		ResourceTypes []string
+		// Namespace limits the namespaced resources to a single namespace, if set
+		Namespace string
+	}
+
+	// ObjectRef identifies a live object in the cluster
+	ObjectRef struct {
+		Kind      string
+		Namespace string
+		Name      string
+	}
+
 	DeleteResourceOptions struct {
 		Namespace string
 		Name      string
@@ -132,6 +155,15 @@ func AddFlags(flags *pflag.FlagSet) Factory {
 	return &factory{f: cmdutil.NewFactory(mvFlags)}
 }
 
+// NewFactoryForContext returns a Factory for the provided kubernetes context, from the
+// default kubeconfig
+func NewFactoryForContext(contextName string) Factory {
+	confFlags := genericclioptions.NewConfigFlags(true)
+	confFlags.Context = &contextName
+
+	return &factory{f: cmdutil.NewFactory(cmdutil.NewMatchVersionFlags(confFlags))}
+}
+
 func DefaultIOStreams() genericclioptions.IOStreams {
 	return genericclioptions.IOStreams{
 		In:     os.Stdin,
@@ -151,6 +183,15 @@ func CurrentContext() (string, error) {
 	return conf.CurrentContext, nil
 }
 
+// ContextRESTConfig returns the rest config of the provided kubernetes context, from the
+// default kubeconfig
+func ContextRESTConfig(contextName string) (*restclient.Config, error) {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{CurrentContext: contextName},
+	).ClientConfig()
+}
+
 func GenerateNamespace(namespace string, labels map[string]string) *corev1.Namespace {
 	if labels == nil {
 		labels = map[string]string{}
@@ -275,6 +316,66 @@ func (f *factory) DeleteResource(ctx context.Context, opts *DeleteResourceOption
 	return nil
 }
 
+func (f *factory) List(_ context.Context, opts *ListOptions) ([]ObjectRef, error) {
+	infos, err := f.listInfos(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[ObjectRef]bool{}
+	refs := make([]ObjectRef, 0, len(infos))
+	for _, info := range infos {
+		ref := ObjectRef{
+			Kind:      info.Mapping.GroupVersionKind.Kind,
+			Namespace: info.Namespace,
+			Name:      info.Name,
+		}
+		if seen[ref] {
+			continue
+		}
+
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+func (f *factory) Export(_ context.Context, opts *ListOptions) ([]byte, error) {
+	infos, err := f.listInfos(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]*unstructured.Unstructured, 0, len(infos))
+	for _, info := range infos {
+		u, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		objs = append(objs, u)
+	}
+
+	return ExportObjects(objs)
+}
+
+func (f *factory) listInfos(opts *ListOptions) ([]*resource.Info, error) {
+	b := f.f.NewBuilder().
+		Unstructured().
+		LabelSelectorParam(opts.LabelSelector).
+		ResourceTypeOrNameArgs(true, strings.Join(opts.ResourceTypes, ",")).
+		ContinueOnError().
+		Flatten()
+	if opts.Namespace != "" {
+		b = b.NamespaceParam(opts.Namespace).DefaultNamespace()
+	} else {
+		b = b.AllNamespaces(true)
+	}
+
+	return b.Do().Infos()
+}
+
 func (f *factory) Wait(ctx context.Context, opts *WaitOptions) error {
 	itr := 0
 	resources := map[*Resource]bool{}
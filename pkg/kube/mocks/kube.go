@@ -79,6 +79,21 @@ func (mr *MockFactoryMockRecorder) DeleteResource(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteResource", reflect.TypeOf((*MockFactory)(nil).DeleteResource), arg0, arg1)
 }
 
+// Export mocks base method.
+func (m *MockFactory) Export(arg0 context.Context, arg1 *kube.ListOptions) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", arg0, arg1)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockFactoryMockRecorder) Export(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockFactory)(nil).Export), arg0, arg1)
+}
+
 // KubernetesClientSet mocks base method.
 func (m *MockFactory) KubernetesClientSet() (kubernetes.Interface, error) {
 	m.ctrl.T.Helper()
@@ -108,6 +123,21 @@ func (mr *MockFactoryMockRecorder) KubernetesClientSetOrDie() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KubernetesClientSetOrDie", reflect.TypeOf((*MockFactory)(nil).KubernetesClientSetOrDie))
 }
 
+// List mocks base method.
+func (m *MockFactory) List(arg0 context.Context, arg1 *kube.ListOptions) ([]kube.ObjectRef, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1)
+	ret0, _ := ret[0].([]kube.ObjectRef)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockFactoryMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockFactory)(nil).List), arg0, arg1)
+}
+
 // ToRESTConfig mocks base method.
 func (m *MockFactory) ToRESTConfig() (*rest.Config, error) {
 	m.ctrl.T.Helper()
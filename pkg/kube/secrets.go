@@ -0,0 +1,234 @@
+package kube
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type (
+	// SealedSecret is a bitnami sealed-secrets SealedSecret, which can only be
+	// decrypted by the sealed-secrets controller in the cluster
+	SealedSecret struct {
+		metav1.TypeMeta   `json:",inline"`
+		metav1.ObjectMeta `json:"metadata"`
+		Spec              SealedSecretSpec `json:"spec"`
+	}
+
+	SealedSecretSpec struct {
+		EncryptedData map[string]string  `json:"encryptedData"`
+		Template      SecretTemplateSpec `json:"template"`
+	}
+
+	SecretTemplateSpec struct {
+		metav1.ObjectMeta `json:"metadata"`
+		Type              corev1.SecretType `json:"type,omitempty"`
+	}
+
+	// ExternalSecret is an external-secrets ExternalSecret, which creates a secret
+	// from the data in a SecretStore
+	ExternalSecret struct {
+		metav1.TypeMeta   `json:",inline"`
+		metav1.ObjectMeta `json:"metadata"`
+		Spec              ExternalSecretSpec `json:"spec"`
+	}
+
+	ExternalSecretSpec struct {
+		SecretStoreRef SecretStoreRef       `json:"secretStoreRef"`
+		Target         ExternalSecretTarget `json:"target"`
+		Data           []ExternalSecretData `json:"data"`
+	}
+
+	SecretStoreRef struct {
+		Kind string `json:"kind,omitempty"`
+		Name string `json:"name"`
+	}
+
+	ExternalSecretTarget struct {
+		Name     string                 `json:"name"`
+		Template ExternalSecretTemplate `json:"template"`
+	}
+
+	ExternalSecretTemplate struct {
+		Type          corev1.SecretType              `json:"type,omitempty"`
+		EngineVersion string                         `json:"engineVersion,omitempty"`
+		Metadata      ExternalSecretTemplateMetadata `json:"metadata"`
+		Data          map[string]string              `json:"data,omitempty"`
+	}
+
+	ExternalSecretTemplateMetadata struct {
+		Labels      map[string]string `json:"labels,omitempty"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+	}
+
+	ExternalSecretData struct {
+		SecretKey string                  `json:"secretKey"`
+		RemoteRef ExternalSecretRemoteRef `json:"remoteRef"`
+	}
+
+	ExternalSecretRemoteRef struct {
+		Key string `json:"key"`
+	}
+)
+
+const sealedSecretSessionKeyBytes = 32
+
+// SealSecret encrypts the data of secret with the public key in the PEM encoded cert,
+// the same way kubeseal does in its default (strict) scope. The result can only be
+// decrypted by the sealed-secrets controller, and only into a secret with the same
+// name and namespace
+func SealSecret(secret *corev1.Secret, cert []byte) (*SealedSecret, error) {
+	block, _ := pem.Decode(cert)
+	if block == nil {
+		return nil, errors.New("failed to decode sealing certificate, expected a PEM block")
+	}
+
+	c, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sealing certificate: %w", err)
+	}
+
+	pubKey, ok := c.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("sealing certificate does not contain an rsa public key")
+	}
+
+	data := map[string][]byte{}
+	for k, v := range secret.Data {
+		data[k] = v
+	}
+
+	for k, v := range secret.StringData {
+		data[k] = []byte(v)
+	}
+
+	label := []byte(secret.Namespace + "/" + secret.Name)
+	encryptedData := make(map[string]string, len(data))
+	for k, v := range data {
+		ciphertext, err := hybridEncrypt(rand.Reader, pubKey, v, label)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt '%s': %w", k, err)
+		}
+
+		encryptedData[k] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	return &SealedSecret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "bitnami.com/v1alpha1",
+			Kind:       "SealedSecret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secret.Name,
+			Namespace: secret.Namespace,
+		},
+		Spec: SealedSecretSpec{
+			EncryptedData: encryptedData,
+			Template: SecretTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        secret.Name,
+					Namespace:   secret.Namespace,
+					Labels:      secret.Labels,
+					Annotations: secret.Annotations,
+				},
+				Type: secret.Type,
+			},
+		},
+	}, nil
+}
+
+// GenerateExternalSecret returns an ExternalSecret that creates secret. The keys in
+// remoteKeys are read from the store, and the rest of the secret string data is
+// kept as is in the target template
+func GenerateExternalSecret(secret *corev1.Secret, store SecretStoreRef, remoteKeys map[string]string) *ExternalSecret {
+	templateData := make(map[string]string, len(secret.StringData))
+	for k, v := range secret.StringData {
+		templateData[k] = v
+	}
+
+	data := make([]ExternalSecretData, 0, len(remoteKeys))
+	for k, remoteKey := range remoteKeys {
+		templateData[k] = fmt.Sprintf("{{ .%s }}", k)
+		data = append(data, ExternalSecretData{
+			SecretKey: k,
+			RemoteRef: ExternalSecretRemoteRef{Key: remoteKey},
+		})
+	}
+
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].SecretKey < data[j].SecretKey
+	})
+
+	return &ExternalSecret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "external-secrets.io/v1",
+			Kind:       "ExternalSecret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secret.Name,
+			Namespace: secret.Namespace,
+		},
+		Spec: ExternalSecretSpec{
+			SecretStoreRef: store,
+			Target: ExternalSecretTarget{
+				Name: secret.Name,
+				Template: ExternalSecretTemplate{
+					Type:          secret.Type,
+					EngineVersion: "v2",
+					Metadata: ExternalSecretTemplateMetadata{
+						Labels:      secret.Labels,
+						Annotations: secret.Annotations,
+					},
+					Data: templateData,
+				},
+			},
+			Data: data,
+		},
+	}
+}
+
+// hybridEncrypt encrypts plaintext with a random AES-GCM session key, which is itself
+// encrypted with RSA-OAEP, using label. This is the format the sealed-secrets
+// controller expects
+func hybridEncrypt(rnd io.Reader, pubKey *rsa.PublicKey, plaintext, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, sealedSecretSessionKeyBytes)
+	if _, err := io.ReadFull(rnd, sessionKey); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aed, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaCiphertext, err := rsa.EncryptOAEP(sha256.New(), rnd, pubKey, sessionKey, label)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := make([]byte, 2)
+	binary.BigEndian.PutUint16(ciphertext, uint16(len(rsaCiphertext)))
+	ciphertext = append(ciphertext, rsaCiphertext...)
+
+	// the session key is only used once, so a zero nonce is safe
+	zeroNonce := make([]byte, aed.NonceSize())
+	return aed.Seal(ciphertext, zeroNonce, plaintext, nil), nil
+}
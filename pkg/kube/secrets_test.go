@@ -0,0 +1,136 @@
+package kube
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "name",
+			Namespace: "namespace",
+			Labels: map[string]string{
+				"key": "value",
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			"url":      "https://github.com/",
+			"password": "token",
+		},
+	}
+}
+
+func generateSealingCert(t *testing.T) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sealed-secret"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func hybridDecrypt(t *testing.T, key *rsa.PrivateKey, ciphertext, label []byte) []byte {
+	rsaLen := int(binary.BigEndian.Uint16(ciphertext))
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, ciphertext[2:2+rsaLen], label)
+	assert.NoError(t, err)
+
+	block, err := aes.NewCipher(sessionKey)
+	assert.NoError(t, err)
+	aed, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+
+	plaintext, err := aed.Open(nil, make([]byte, aed.NonceSize()), ciphertext[2+rsaLen:], nil)
+	assert.NoError(t, err)
+	return plaintext
+}
+
+func TestSealSecret(t *testing.T) {
+	key, cert := generateSealingCert(t)
+	tests := map[string]struct {
+		cert     []byte
+		wantErr  string
+		assertFn func(t *testing.T, sealed *SealedSecret)
+	}{
+		"Should fail if cert is not PEM encoded": {
+			cert:    []byte("not a cert"),
+			wantErr: "failed to decode sealing certificate, expected a PEM block",
+		},
+		"Should fail if cert is invalid": {
+			cert:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("invalid")}),
+			wantErr: "failed to parse sealing certificate: x509: malformed certificate",
+		},
+		"Should seal the secret data": {
+			cert: cert,
+			assertFn: func(t *testing.T, sealed *SealedSecret) {
+				assert.Equal(t, "SealedSecret", sealed.Kind)
+				assert.Equal(t, "bitnami.com/v1alpha1", sealed.APIVersion)
+				assert.Equal(t, "name", sealed.Name)
+				assert.Equal(t, "namespace", sealed.Namespace)
+				assert.Equal(t, "name", sealed.Spec.Template.Name)
+				assert.Equal(t, "value", sealed.Spec.Template.Labels["key"])
+				assert.Equal(t, corev1.SecretTypeOpaque, sealed.Spec.Template.Type)
+				assert.Len(t, sealed.Spec.EncryptedData, 2)
+				for k, v := range map[string]string{"url": "https://github.com/", "password": "token"} {
+					ciphertext, err := base64.StdEncoding.DecodeString(sealed.Spec.EncryptedData[k])
+					assert.NoError(t, err)
+					assert.Equal(t, v, string(hybridDecrypt(t, key, ciphertext, []byte("namespace/name"))))
+				}
+			},
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			sealed, err := SealSecret(testSecret(), tt.cert)
+			if err != nil || tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			tt.assertFn(t, sealed)
+		})
+	}
+}
+
+func TestGenerateExternalSecret(t *testing.T) {
+	es := GenerateExternalSecret(testSecret(), SecretStoreRef{Kind: "ClusterSecretStore", Name: "store"}, map[string]string{
+		"password": "git-token",
+	})
+	assert.Equal(t, "ExternalSecret", es.Kind)
+	assert.Equal(t, "external-secrets.io/v1", es.APIVersion)
+	assert.Equal(t, "name", es.Name)
+	assert.Equal(t, "namespace", es.Namespace)
+	assert.Equal(t, SecretStoreRef{Kind: "ClusterSecretStore", Name: "store"}, es.Spec.SecretStoreRef)
+	assert.Equal(t, "name", es.Spec.Target.Name)
+	assert.Equal(t, "value", es.Spec.Target.Template.Metadata.Labels["key"])
+	assert.Equal(t, map[string]string{
+		"url":      "https://github.com/",
+		"password": "{{ .password }}",
+	}, es.Spec.Target.Template.Data)
+	assert.Equal(t, []ExternalSecretData{
+		{SecretKey: "password", RemoteRef: ExternalSecretRemoteRef{Key: "git-token"}},
+	}, es.Spec.Data)
+}
@@ -0,0 +1,107 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	CustomResourceDefinitionGVR = schema.GroupVersionResource{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1",
+		Resource: "customresourcedefinitions",
+	}
+
+	// used for mocking
+	getDynamicClient = func(f Factory) (dynamic.Interface, error) {
+		config, err := f.ToRESTConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		return dynamic.NewForConfig(config)
+	}
+)
+
+// WaitForCreation wraps a 'WaitFunc', so a resource that was not created yet is reported as not
+// ready, instead of failing the check.
+func WaitForCreation(waitFunc WaitFunc) WaitFunc {
+	return func(ctx context.Context, f Factory, ns, name string) (bool, error) {
+		ready, err := waitFunc(ctx, f, ns, name)
+		if kerrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return ready, err
+	}
+}
+
+// WaitStatefulSetReady can be used as a generic 'WaitFunc' for statefulset.
+func WaitStatefulSetReady(ctx context.Context, f Factory, ns, name string) (bool, error) {
+	cs, err := f.KubernetesClientSet()
+	if err != nil {
+		return false, err
+	}
+
+	s, err := cs.AppsV1().StatefulSets(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+
+	return s.Status.ObservedGeneration >= s.Generation && s.Status.ReadyReplicas >= replicas, nil
+}
+
+// WaitCRDEstablished can be used as a 'WaitFunc' for a CustomResourceDefinition, by its full
+// name (e.g. applications.argoproj.io). The namespace is ignored.
+func WaitCRDEstablished(ctx context.Context, f Factory, _, name string) (bool, error) {
+	return GetConditionWaitFunc(CustomResourceDefinitionGVR, "Established")(ctx, f, "", name)
+}
+
+// GetReadyConditionWaitFunc returns a 'WaitFunc' that will return true when the resource
+// has a "Ready" condition with a "True" status.
+func GetReadyConditionWaitFunc(gvr schema.GroupVersionResource) WaitFunc {
+	return GetConditionWaitFunc(gvr, "Ready")
+}
+
+// GetConditionWaitFunc returns a 'WaitFunc' that will return true when the resource has a
+// condition of type conditionType with a "True" status.
+func GetConditionWaitFunc(gvr schema.GroupVersionResource, conditionType string) WaitFunc {
+	return func(ctx context.Context, f Factory, ns, name string) (bool, error) {
+		cs, err := getDynamicClient(f)
+		if err != nil {
+			return false, err
+		}
+
+		obj, err := cs.Resource(gvr).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		if err != nil {
+			return false, fmt.Errorf("failed to get conditions of %s '%s': %w", gvr.Resource, name, err)
+		}
+
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["type"] != conditionType {
+				continue
+			}
+
+			return condition["status"] == string(metav1.ConditionTrue), nil
+		}
+
+		return false, nil
+	}
+}
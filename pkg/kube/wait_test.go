@@ -0,0 +1,189 @@
+package kube
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeFactory struct {
+	Factory
+	cs kubernetes.Interface
+}
+
+func (f *fakeFactory) KubernetesClientSet() (kubernetes.Interface, error) {
+	return f.cs, nil
+}
+
+func TestWaitStatefulSetReady(t *testing.T) {
+	replicas := int32(2)
+	tests := map[string]struct {
+		sts     *appsv1.StatefulSet
+		want    bool
+		wantErr string
+	}{
+		"should return true when all replicas are ready": {
+			sts: &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "name", Namespace: "ns", Generation: 2},
+				Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+				Status:     appsv1.StatefulSetStatus{ObservedGeneration: 2, ReadyReplicas: 2},
+			},
+			want: true,
+		},
+		"should return false when not all replicas are ready": {
+			sts: &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "name", Namespace: "ns", Generation: 2},
+				Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+				Status:     appsv1.StatefulSetStatus{ObservedGeneration: 2, ReadyReplicas: 1},
+			},
+			want: false,
+		},
+		"should return false when the generation was not observed yet": {
+			sts: &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "name", Namespace: "ns", Generation: 3},
+				Status:     appsv1.StatefulSetStatus{ObservedGeneration: 2, ReadyReplicas: 1},
+			},
+			want: false,
+		},
+		"should fail when the statefulset does not exist": {
+			sts: &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns"},
+			},
+			wantErr: `statefulsets.apps "name" not found`,
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			f := &fakeFactory{cs: kubefake.NewSimpleClientset(tt.sts)}
+			got, err := WaitStatefulSetReady(context.Background(), f, "ns", "name")
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWaitCRDEstablished(t *testing.T) {
+	tests := map[string]struct {
+		conditions []interface{}
+		want       bool
+	}{
+		"should return true when the crd is established": {
+			conditions: []interface{}{
+				map[string]interface{}{"type": "NamesAccepted", "status": "True"},
+				map[string]interface{}{"type": "Established", "status": "True"},
+			},
+			want: true,
+		},
+		"should return false when the crd is not established yet": {
+			conditions: []interface{}{
+				map[string]interface{}{"type": "Established", "status": "False"},
+			},
+			want: false,
+		},
+		"should return false when there are no conditions": {
+			want: false,
+		},
+	}
+	origGetDynamicClient := getDynamicClient
+	defer func() { getDynamicClient = origGetDynamicClient }()
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			crd := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "apiextensions.k8s.io/v1",
+				"kind":       "CustomResourceDefinition",
+				"metadata": map[string]interface{}{
+					"name": "applications.argoproj.io",
+				},
+			}}
+			if tt.conditions != nil {
+				crd.Object["status"] = map[string]interface{}{"conditions": tt.conditions}
+			}
+
+			getDynamicClient = func(_ Factory) (dynamic.Interface, error) {
+				return dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), crd), nil
+			}
+			got, err := WaitCRDEstablished(context.Background(), &fakeFactory{}, "", "applications.argoproj.io")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWaitForCreation(t *testing.T) {
+	tests := map[string]struct {
+		deployment *appsv1.Deployment
+		want       bool
+	}{
+		"should return false when the resource does not exist yet": {
+			deployment: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns"}},
+			want:       false,
+		},
+		"should return the result of the wrapped func": {
+			deployment: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "name", Namespace: "ns"},
+				Spec:       appsv1.DeploymentSpec{Replicas: new(int32)},
+			},
+			want: true,
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			f := &fakeFactory{cs: kubefake.NewSimpleClientset(tt.deployment)}
+			got, err := WaitForCreation(WaitDeploymentReady)(context.Background(), f, "ns", "name")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFactory_Wait(t *testing.T) {
+	notFound := func(_ context.Context, _ Factory, _, name string) (bool, error) {
+		return false, kerrors.NewNotFound(schema.GroupResource{Resource: "deployments"}, name)
+	}
+	tests := map[string]struct {
+		waitFunc WaitFunc
+		wantErr  string
+	}{
+		"should not wait for a resource that fails the check": {
+			waitFunc: notFound,
+		},
+		"should wait for a resource that was not created yet": {
+			waitFunc: WaitForCreation(notFound),
+			wantErr:  "context deadline exceeded",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			err := (&factory{}).Wait(context.Background(), &WaitOptions{
+				Interval: time.Millisecond,
+				Timeout:  10 * time.Millisecond,
+				Resources: []Resource{
+					{Name: "name", Namespace: "ns", WaitFunc: tt.waitFunc},
+				},
+			})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}